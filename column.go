@@ -1,8 +1,13 @@
 package pixidb
 
 import (
-	"encoding/binary"
+	"bytes"
+	"cmp"
+	"fmt"
 	"math"
+	"strconv"
+	"time"
+	"unicode"
 )
 
 // Type representing the PixiDB 'types' of values that can be stored
@@ -20,8 +25,25 @@ const (
 	ColumnTypeUint64
 	ColumnTypeFloat32
 	ColumnTypeFloat64
+	ColumnTypeTimestamp
+	ColumnTypeComplex64
+	ColumnTypeComplex128
 )
 
+// The lowercase name of the column type, such as 'int16' or 'timestamp'.
+func (c ColumnType) String() string {
+	switch c {
+	case ColumnTypeTimestamp:
+		return "timestamp"
+	case ColumnTypeInt8, ColumnTypeUint8, ColumnTypeInt16, ColumnTypeUint16, ColumnTypeInt32,
+		ColumnTypeUint32, ColumnTypeInt64, ColumnTypeUint64, ColumnTypeFloat32, ColumnTypeFloat64,
+		ColumnTypeComplex64, ColumnTypeComplex128:
+		return c.goType()
+	default:
+		return fmt.Sprintf("ColumnType(%d)", int(c))
+	}
+}
+
 // The size in bytes of this particular column type.
 func (c ColumnType) Size() int {
 	switch c {
@@ -44,50 +66,421 @@ func (c ColumnType) Size() int {
 	case ColumnTypeUint64:
 		fallthrough
 	case ColumnTypeFloat64:
+		fallthrough
+	case ColumnTypeTimestamp:
+		fallthrough
+	case ColumnTypeComplex64:
 		return 8
+	case ColumnTypeComplex128:
+		return 16
 	}
 	return 0
 }
 
+// The name of the standard Go type that values of this column type are encoded from.
+func (c ColumnType) goType() string {
+	switch c {
+	case ColumnTypeInt8:
+		return "int8"
+	case ColumnTypeUint8:
+		return "uint8"
+	case ColumnTypeInt16:
+		return "int16"
+	case ColumnTypeUint16:
+		return "uint16"
+	case ColumnTypeInt32:
+		return "int32"
+	case ColumnTypeUint32:
+		return "uint32"
+	case ColumnTypeInt64:
+		return "int64"
+	case ColumnTypeUint64:
+		return "uint64"
+	case ColumnTypeFloat32:
+		return "float32"
+	case ColumnTypeFloat64:
+		return "float64"
+	case ColumnTypeTimestamp:
+		return "time.Time"
+	case ColumnTypeComplex64:
+		return "complex64"
+	case ColumnTypeComplex128:
+		return "complex128"
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
 // Given a standard Go value, encodes it according to the type of the column. The column
-// type must match the type of the Go value.
+// type must match the type of the Go value, otherwise this panics. Use TryEncodeValue when the
+// value comes from outside the program.
 func (c ColumnType) EncodeValue(val any) Value {
-	retArr := make([]byte, c.Size())
+	encoded, err := c.TryEncodeValue(val)
+	if err != nil {
+		panic("pixidb: " + err.Error())
+	}
+	return encoded
+}
+
+// Given a standard Go value, encodes it according to the type of the column, returning a
+// ValueTypeError if the type of the Go value does not match the column type.
+func (c ColumnType) TryEncodeValue(val any) (Value, error) {
+	return NewValue(c, val)
+}
+
+// Decodes a value of this column type into the standard Go value it was encoded from, the
+// inverse of EncodeValue.
+func (c ColumnType) DecodeValue(val Value) any {
 	switch c {
 	case ColumnTypeInt8:
-		retArr[0] = byte(val.(int8))
+		return val.AsInt8()
 	case ColumnTypeUint8:
-		retArr[1] = byte(val.(uint8))
+		return val.AsUint8()
 	case ColumnTypeInt16:
-		binary.BigEndian.PutUint16(retArr, uint16(val.(int16)))
+		return val.AsInt16()
 	case ColumnTypeUint16:
-		binary.BigEndian.PutUint16(retArr, val.(uint16))
+		return val.AsUint16()
 	case ColumnTypeInt32:
-		binary.BigEndian.PutUint32(retArr, uint32(val.(int32)))
+		return val.AsInt32()
 	case ColumnTypeUint32:
-		binary.BigEndian.PutUint32(retArr, val.(uint32))
+		return val.AsUint32()
 	case ColumnTypeInt64:
-		binary.BigEndian.PutUint64(retArr, uint64(val.(int64)))
+		return val.AsInt64()
+	case ColumnTypeUint64:
+		return val.AsUint64()
+	case ColumnTypeFloat32:
+		return val.AsFloat32()
+	case ColumnTypeFloat64:
+		return val.AsFloat64()
+	case ColumnTypeTimestamp:
+		return val.AsTime()
+	case ColumnTypeComplex64:
+		return val.AsComplex64()
+	case ColumnTypeComplex128:
+		return val.AsComplex128()
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Decodes a value of this column type, converting it to a float64 regardless of the
+// underlying type. Timestamps convert to nanoseconds since the Unix epoch, and complex numbers to
+// their real part. Large 64-bit integers may lose precision in the conversion.
+func (c ColumnType) DecodeFloat64(val Value) float64 {
+	switch c {
+	case ColumnTypeInt8:
+		return float64(val.AsInt8())
+	case ColumnTypeUint8:
+		return float64(val.AsUint8())
+	case ColumnTypeInt16:
+		return float64(val.AsInt16())
+	case ColumnTypeUint16:
+		return float64(val.AsUint16())
+	case ColumnTypeInt32:
+		return float64(val.AsInt32())
+	case ColumnTypeUint32:
+		return float64(val.AsUint32())
+	case ColumnTypeInt64, ColumnTypeTimestamp:
+		return float64(val.AsInt64())
+	case ColumnTypeUint64:
+		return float64(val.AsUint64())
+	case ColumnTypeFloat32:
+		return float64(val.AsFloat32())
+	case ColumnTypeFloat64:
+		return val.AsFloat64()
+	case ColumnTypeComplex64:
+		return float64(real(val.AsComplex64()))
+	case ColumnTypeComplex128:
+		return real(val.AsComplex128())
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Encodes a float64 as a value of this column type, the reverse of DecodeFloat64. Integer types
+// round to the nearest integer and clamp to their range, with NaN encoding as zero. Timestamps
+// take the float as nanoseconds since the Unix epoch, and complex numbers as their real part.
+func (c ColumnType) EncodeFloat64(f float64) Value {
+	switch c {
+	case ColumnTypeInt8:
+		return NewInt8Value(roundToInt[int8](f, math.MinInt8, math.MaxInt8))
+	case ColumnTypeUint8:
+		return NewUint8Value(roundToInt[uint8](f, 0, math.MaxUint8))
+	case ColumnTypeInt16:
+		return NewInt16Value(roundToInt[int16](f, math.MinInt16, math.MaxInt16))
+	case ColumnTypeUint16:
+		return NewUint16Value(roundToInt[uint16](f, 0, math.MaxUint16))
+	case ColumnTypeInt32:
+		return NewInt32Value(roundToInt[int32](f, math.MinInt32, math.MaxInt32))
+	case ColumnTypeUint32:
+		return NewUint32Value(roundToInt[uint32](f, 0, math.MaxUint32))
+	case ColumnTypeInt64, ColumnTypeTimestamp:
+		return NewInt64Value(roundToInt[int64](f, math.MinInt64, math.MaxInt64))
+	case ColumnTypeUint64:
+		return NewUint64Value(roundToInt[uint64](f, 0, math.MaxUint64))
+	case ColumnTypeFloat32:
+		return NewFloat32Value(float32(f))
+	case ColumnTypeFloat64:
+		return NewFloat64Value(f)
+	case ColumnTypeComplex64:
+		return NewComplex64Value(complex(float32(f), 0))
+	case ColumnTypeComplex128:
+		return NewComplex128Value(complex(f, 0))
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Round the float to the nearest integer within the range of the integer type, or zero if NaN.
+func roundToInt[T integer](f float64, lo T, hi T) T {
+	r := math.Round(f)
+	switch {
+	case math.IsNaN(r):
+		return 0
+	case r <= float64(lo):
+		return lo
+	case r >= float64(hi):
+		return hi
+	}
+	return T(r)
+}
+
+// Compares two values of this column type by their decoded numbers, returning -1 if a is less
+// than b, 0 if they are equal, and 1 if a is greater than b. Signed and unsigned integers are
+// ordered by their own interpretation. For floats, NaN is ordered before every other value and
+// is equal to itself, and negative zero is equal to zero. Complex numbers are ordered by their
+// real part, then by their imaginary part.
+func (c ColumnType) Compare(a Value, b Value) int {
+	switch c {
+	case ColumnTypeInt8:
+		return cmp.Compare(a.AsInt8(), b.AsInt8())
+	case ColumnTypeUint8:
+		return cmp.Compare(a.AsUint8(), b.AsUint8())
+	case ColumnTypeInt16:
+		return cmp.Compare(a.AsInt16(), b.AsInt16())
+	case ColumnTypeUint16:
+		return cmp.Compare(a.AsUint16(), b.AsUint16())
+	case ColumnTypeInt32:
+		return cmp.Compare(a.AsInt32(), b.AsInt32())
+	case ColumnTypeUint32:
+		return cmp.Compare(a.AsUint32(), b.AsUint32())
+	case ColumnTypeInt64, ColumnTypeTimestamp:
+		return cmp.Compare(a.AsInt64(), b.AsInt64())
 	case ColumnTypeUint64:
-		binary.BigEndian.PutUint64(retArr, val.(uint64))
+		return cmp.Compare(a.AsUint64(), b.AsUint64())
 	case ColumnTypeFloat32:
-		binary.BigEndian.PutUint32(retArr, math.Float32bits(val.(float32)))
+		return cmp.Compare(a.AsFloat32(), b.AsFloat32())
 	case ColumnTypeFloat64:
-		binary.BigEndian.PutUint64(retArr, math.Float64bits(val.(float64)))
+		return cmp.Compare(a.AsFloat64(), b.AsFloat64())
+	case ColumnTypeComplex64:
+		return compareComplex(complex128(a.AsComplex64()), complex128(b.AsComplex64()))
+	case ColumnTypeComplex128:
+		return compareComplex(a.AsComplex128(), b.AsComplex128())
 	default:
 		panic("pixidb: invalid column type specification")
 	}
-	return retArr
+}
+
+// Adds two values of this column type, returning the encoded sum. Integer arithmetic wraps
+// around on overflow like Go's fixed-size integers, and float arithmetic rounds to the
+// precision of the column type. Timestamps are treated as integer nanoseconds.
+func (c ColumnType) Add(a Value, b Value) Value {
+	return c.arith(arithAdd, a, b)
+}
+
+// Subtracts b from a for values of this column type, wrapping or rounding like Add.
+func (c ColumnType) Sub(a Value, b Value) Value {
+	return c.arith(arithSub, a, b)
+}
+
+// Multiplies two values of this column type, wrapping or rounding like Add.
+func (c ColumnType) Mul(a Value, b Value) Value {
+	return c.arith(arithMul, a, b)
+}
+
+// Divides a by b for values of this column type. Integer division truncates toward zero and
+// panics if b is zero, while float division by zero gives an infinity or NaN.
+func (c ColumnType) Div(a Value, b Value) Value {
+	return c.arith(arithDiv, a, b)
+}
+
+func compareComplex(a complex128, b complex128) int {
+	if c := cmp.Compare(real(a), real(b)); c != 0 {
+		return c
+	}
+	return cmp.Compare(imag(a), imag(b))
+}
+
+type arithOp int
+
+const (
+	arithAdd arithOp = iota
+	arithSub
+	arithMul
+	arithDiv
+)
+
+type integer interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64
+}
+
+type number interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64 | ~float32 | ~float64 | ~complex64 | ~complex128
+}
+
+func applyArith[T number](op arithOp, a T, b T) T {
+	switch op {
+	case arithAdd:
+		return a + b
+	case arithSub:
+		return a - b
+	case arithMul:
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+func (c ColumnType) arith(op arithOp, a Value, b Value) Value {
+	switch c {
+	case ColumnTypeInt8:
+		return NewInt8Value(applyArith(op, a.AsInt8(), b.AsInt8()))
+	case ColumnTypeUint8:
+		return NewUint8Value(applyArith(op, a.AsUint8(), b.AsUint8()))
+	case ColumnTypeInt16:
+		return NewInt16Value(applyArith(op, a.AsInt16(), b.AsInt16()))
+	case ColumnTypeUint16:
+		return NewUint16Value(applyArith(op, a.AsUint16(), b.AsUint16()))
+	case ColumnTypeInt32:
+		return NewInt32Value(applyArith(op, a.AsInt32(), b.AsInt32()))
+	case ColumnTypeUint32:
+		return NewUint32Value(applyArith(op, a.AsUint32(), b.AsUint32()))
+	case ColumnTypeInt64, ColumnTypeTimestamp:
+		return NewInt64Value(applyArith(op, a.AsInt64(), b.AsInt64()))
+	case ColumnTypeUint64:
+		return NewUint64Value(applyArith(op, a.AsUint64(), b.AsUint64()))
+	case ColumnTypeFloat32:
+		return NewFloat32Value(applyArith(op, a.AsFloat32(), b.AsFloat32()))
+	case ColumnTypeFloat64:
+		return NewFloat64Value(applyArith(op, a.AsFloat64(), b.AsFloat64()))
+	case ColumnTypeComplex64:
+		return NewComplex64Value(applyArith(op, a.AsComplex64(), b.AsComplex64()))
+	case ColumnTypeComplex128:
+		return NewComplex128Value(applyArith(op, a.AsComplex128(), b.AsComplex128()))
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Decodes a value of this column type and formats it as text. Floating point values are
+// written with the fewest digits that still parse back to the exact same value.
+func (c ColumnType) FormatValue(val Value) string {
+	switch c {
+	case ColumnTypeInt8:
+		return strconv.FormatInt(int64(val.AsInt8()), 10)
+	case ColumnTypeUint8:
+		return strconv.FormatUint(uint64(val.AsUint8()), 10)
+	case ColumnTypeInt16:
+		return strconv.FormatInt(int64(val.AsInt16()), 10)
+	case ColumnTypeUint16:
+		return strconv.FormatUint(uint64(val.AsUint16()), 10)
+	case ColumnTypeInt32:
+		return strconv.FormatInt(int64(val.AsInt32()), 10)
+	case ColumnTypeUint32:
+		return strconv.FormatUint(uint64(val.AsUint32()), 10)
+	case ColumnTypeInt64:
+		return strconv.FormatInt(val.AsInt64(), 10)
+	case ColumnTypeUint64:
+		return strconv.FormatUint(val.AsUint64(), 10)
+	case ColumnTypeFloat32:
+		return strconv.FormatFloat(float64(val.AsFloat32()), 'g', -1, 32)
+	case ColumnTypeFloat64:
+		return strconv.FormatFloat(val.AsFloat64(), 'g', -1, 64)
+	case ColumnTypeTimestamp:
+		return val.AsTime().Format(time.RFC3339Nano)
+	case ColumnTypeComplex64:
+		return strconv.FormatComplex(complex128(val.AsComplex64()), 'g', -1, 64)
+	case ColumnTypeComplex128:
+		return strconv.FormatComplex(val.AsComplex128(), 'g', -1, 128)
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Parses text holding a value of this column type, such as that produced by FormatValue, and
+// encodes it. Errors if the text is not a number representable by the column type, or for
+// timestamps, not an RFC 3339 time.
+func (c ColumnType) ParseValue(text string) (Value, error) {
+	switch c {
+	case ColumnTypeInt8, ColumnTypeInt16, ColumnTypeInt32, ColumnTypeInt64:
+		val, err := strconv.ParseInt(text, 10, c.Size()*8)
+		if err != nil {
+			return nil, err
+		}
+		return c.encodeBits(uint64(val)), nil
+	case ColumnTypeUint8, ColumnTypeUint16, ColumnTypeUint32, ColumnTypeUint64:
+		val, err := strconv.ParseUint(text, 10, c.Size()*8)
+		if err != nil {
+			return nil, err
+		}
+		return c.encodeBits(val), nil
+	case ColumnTypeFloat32:
+		val, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, err
+		}
+		return NewFloat32Value(float32(val)), nil
+	case ColumnTypeFloat64:
+		val, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return NewFloat64Value(val), nil
+	case ColumnTypeTimestamp:
+		val, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, err
+		}
+		return NewTimeValue(val), nil
+	case ColumnTypeComplex64:
+		val, err := strconv.ParseComplex(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return NewComplex64Value(complex64(val)), nil
+	case ColumnTypeComplex128:
+		val, err := strconv.ParseComplex(text, 128)
+		if err != nil {
+			return nil, err
+		}
+		return NewComplex128Value(val), nil
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+}
+
+// Encode the low bits of an integer into a value the size of the column type.
+func (c ColumnType) encodeBits(bits uint64) Value {
+	val := make([]byte, c.Size())
+	for i := len(val) - 1; i >= 0; i-- {
+		val[i] = byte(bits)
+		bits >>= 8
+	}
+	return val
 }
 
 // The metadata that describes a column of data in the table. Each column has a name used to refer to it
 // in queries. The type describes the range of values able to be stored in the column (and their in-memory size),
 // and the default value will prepopulate the column's slot in every row when the table is created. There are
-// no nullable columns in PixiDB.
+// no nullable columns in PixiDB, but a column may optionally declare a no-data sentinel value that
+// marks pixels for which no measurement exists. Columns may also be compressed on disk, see
+// WithCompression.
 type Column struct {
-	Name    string
-	Type    ColumnType
-	Default Value
+	Name       string
+	Type       ColumnType
+	Default    Value
+	NoData     Value `json:",omitempty"`
+	Compressed bool  `json:",omitempty"`
 }
 
 // Create a new column description with the given name, type, and encoded default value for the type.
@@ -148,6 +541,21 @@ func NewColumnUint64(name string, defval uint64) Column {
 	return NewColumnUnencoded(name, ColumnTypeUint64, defval)
 }
 
+// Whether the name can be given to a column of a store. Names must start with a letter or an
+// underscore, followed by any number of letters, digits, underscores, hyphens and periods, so that
+// they can be written unquoted in queries and as variable names in exported files.
+func ValidColumnName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
 // Create a new Float32-sized column with the given name and default value.
 func NewColumnFloat32(name string, defval float32) Column {
 	return NewColumnUnencoded(name, ColumnTypeFloat32, defval)
@@ -158,6 +566,46 @@ func NewColumnFloat64(name string, defval float64) Column {
 	return NewColumnUnencoded(name, ColumnTypeFloat64, defval)
 }
 
+// Create a new Complex64-sized column with the given name and default value.
+func NewColumnComplex64(name string, defval complex64) Column {
+	return NewColumnUnencoded(name, ColumnTypeComplex64, defval)
+}
+
+// Create a new Complex128-sized column with the given name and default value.
+func NewColumnComplex128(name string, defval complex128) Column {
+	return NewColumnUnencoded(name, ColumnTypeComplex128, defval)
+}
+
+// Create a new timestamp column with the given name and default time.
+func NewColumnTime(name string, defval time.Time) Column {
+	return NewColumnUnencoded(name, ColumnTypeTimestamp, defval)
+}
+
+// Create a copy of the column description that treats the given encoded value as the no-data
+// sentinel for the column.
+func (c Column) WithNoData(val Value) Column {
+	if len(val) != c.Size() {
+		panic("pixidb: no-data value size does not match specified column size")
+	}
+	c.NoData = val
+	return c
+}
+
+// Create a copy of the column description whose values are compressed in the data file. On each
+// page, the values of the compressed columns are stored together column by column and LZ4
+// compressed, while the other columns stay row-aligned, so homogeneous data such as masks takes
+// little space on disk. Compression costs time on every page read from or written to disk, and
+// pages hold slightly fewer rows.
+func (c Column) WithCompression() Column {
+	c.Compressed = true
+	return c
+}
+
+// Whether the value is the no-data sentinel of the column. Always false for columns without one.
+func (c Column) IsNoData(val Value) bool {
+	return c.NoData != nil && bytes.Equal(c.NoData, val)
+}
+
 // The number of bytes that values of this column will consume on disk.
 func (c Column) Size() int {
 	return c.Type.Size()
@@ -168,3 +616,9 @@ func (c Column) Size() int {
 func (c Column) EncodeValue(val any) Value {
 	return c.Type.EncodeValue(val)
 }
+
+// Encodes a Go value according to the type of the column, returning a ValueTypeError if the
+// type of the input Go value does not match the type of the column.
+func (c Column) TryEncodeValue(val any) (Value, error) {
+	return c.Type.TryEncodeValue(val)
+}
@@ -1,8 +1,12 @@
 package pixidb
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"math"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 )
@@ -45,3 +49,250 @@ func TestColumnConstructors(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnDecodeFloat64(t *testing.T) {
+	testCases := []struct {
+		ctype  ColumnType
+		value  Value
+		expect float64
+	}{
+		{ColumnTypeInt8, NewInt8Value(-3), -3},
+		{ColumnTypeUint8, NewUint8Value(200), 200},
+		{ColumnTypeInt16, NewInt16Value(-300), -300},
+		{ColumnTypeUint16, NewUint16Value(60000), 60000},
+		{ColumnTypeInt32, NewInt32Value(-70000), -70000},
+		{ColumnTypeUint32, NewUint32Value(4000000000), 4000000000},
+		{ColumnTypeInt64, NewInt64Value(-5000000000), -5000000000},
+		{ColumnTypeUint64, NewUint64Value(5000000000), 5000000000},
+		{ColumnTypeFloat32, NewFloat32Value(2.5), 2.5},
+		{ColumnTypeFloat64, NewFloat64Value(-0.125), -0.125},
+	}
+
+	for _, tc := range testCases {
+		if decoded := tc.ctype.DecodeFloat64(tc.value); decoded != tc.expect {
+			t.Errorf("expected %v to decode to %f, got %f", tc.ctype, tc.expect, decoded)
+		}
+	}
+}
+
+func TestColumnParseValue(t *testing.T) {
+	testCases := []struct {
+		ctype  ColumnType
+		text   string
+		expect Value
+	}{
+		{ColumnTypeInt8, "-3", NewInt8Value(-3)},
+		{ColumnTypeUint8, "200", NewUint8Value(200)},
+		{ColumnTypeInt16, "-300", NewInt16Value(-300)},
+		{ColumnTypeUint16, "60000", NewUint16Value(60000)},
+		{ColumnTypeInt32, "-70000", NewInt32Value(-70000)},
+		{ColumnTypeUint32, "4000000000", NewUint32Value(4000000000)},
+		{ColumnTypeInt64, "-5000000000", NewInt64Value(-5000000000)},
+		{ColumnTypeUint64, "18446744073709551615", NewUint64Value(math.MaxUint64)},
+		{ColumnTypeFloat32, "2.5", NewFloat32Value(2.5)},
+		{ColumnTypeFloat64, "-1.25e-3", NewFloat64Value(-1.25e-3)},
+	}
+
+	for _, tc := range testCases {
+		parsed, err := tc.ctype.ParseValue(tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(parsed, tc.expect) {
+			t.Errorf("expected %s to parse to %v, got %v", tc.text, tc.expect, parsed)
+		}
+		if formatted := tc.ctype.FormatValue(parsed); formatted != tc.text && tc.ctype != ColumnTypeFloat64 {
+			t.Errorf("expected %v to format back to %s, got %s", parsed, tc.text, formatted)
+		}
+	}
+
+	for _, bad := range []struct {
+		ctype ColumnType
+		text  string
+	}{{ColumnTypeInt8, "128"}, {ColumnTypeUint16, "-1"}, {ColumnTypeFloat32, "one"}, {ColumnTypeInt32, "1.5"}} {
+		if _, err := bad.ctype.ParseValue(bad.text); err == nil {
+			t.Errorf("expected error parsing %s as %v", bad.text, bad.ctype)
+		}
+	}
+}
+
+func TestColumnCompare(t *testing.T) {
+	nan64 := NewFloat64Value(math.NaN())
+	nan32 := NewFloat32Value(float32(math.NaN()))
+	testCases := []struct {
+		name   string
+		ctype  ColumnType
+		a      Value
+		b      Value
+		expect int
+	}{
+		{"int8 negative", ColumnTypeInt8, NewInt8Value(-1), NewInt8Value(1), -1},
+		{"uint8 high bit", ColumnTypeUint8, NewInt8Value(-1), NewInt8Value(1), 1},
+		{"int16 negative", ColumnTypeInt16, NewInt16Value(-300), NewInt16Value(2), -1},
+		{"uint16 high bit", ColumnTypeUint16, NewInt16Value(-300), NewInt16Value(2), 1},
+		{"int32 negative", ColumnTypeInt32, NewInt32Value(-7), NewInt32Value(-6), -1},
+		{"uint32 high bit", ColumnTypeUint32, NewInt32Value(-7), NewInt32Value(7), 1},
+		{"int64 negative", ColumnTypeInt64, NewInt64Value(math.MinInt64), NewInt64Value(0), -1},
+		{"uint64 high bit", ColumnTypeUint64, NewInt64Value(math.MinInt64), NewInt64Value(0), 1},
+		{"int64 equal", ColumnTypeInt64, NewInt64Value(42), NewInt64Value(42), 0},
+		{"float32 negative", ColumnTypeFloat32, NewFloat32Value(-2.5), NewFloat32Value(-1), -1},
+		{"float32 infinity", ColumnTypeFloat32, NewFloat32Value(float32(math.Inf(1))), NewFloat32Value(3e38), 1},
+		{"float32 nan first", ColumnTypeFloat32, nan32, NewFloat32Value(float32(math.Inf(-1))), -1},
+		{"float32 nan equal", ColumnTypeFloat32, nan32, nan32, 0},
+		{"float64 negative", ColumnTypeFloat64, NewFloat64Value(-1e-300), NewFloat64Value(0), -1},
+		{"float64 signed zero", ColumnTypeFloat64, NewFloat64Value(math.Copysign(0, -1)), NewFloat64Value(0), 0},
+		{"float64 nan first", ColumnTypeFloat64, NewFloat64Value(-1e308), nan64, 1},
+		{"float64 nan equal", ColumnTypeFloat64, nan64, nan64, 0},
+	}
+
+	for _, tc := range testCases {
+		if result := tc.ctype.Compare(tc.a, tc.b); result != tc.expect {
+			t.Errorf("%s: expected compare %d, got %d", tc.name, tc.expect, result)
+		}
+		if result := tc.ctype.Compare(tc.b, tc.a); result != -tc.expect {
+			t.Errorf("%s: expected reversed compare %d, got %d", tc.name, -tc.expect, result)
+		}
+	}
+}
+
+func TestColumnArithmetic(t *testing.T) {
+	add, sub, mul, div := ColumnType.Add, ColumnType.Sub, ColumnType.Mul, ColumnType.Div
+	testCases := []struct {
+		name   string
+		ctype  ColumnType
+		op     func(ColumnType, Value, Value) Value
+		a      Value
+		b      Value
+		expect Value
+	}{
+		{"int8 add wraps", ColumnTypeInt8, add, NewInt8Value(127), NewInt8Value(1), NewInt8Value(-128)},
+		{"uint8 sub wraps", ColumnTypeUint8, sub, NewUint8Value(0), NewUint8Value(1), NewUint8Value(255)},
+		{"int16 sub", ColumnTypeInt16, sub, NewInt16Value(-300), NewInt16Value(200), NewInt16Value(-500)},
+		{"uint16 mul wraps", ColumnTypeUint16, mul, NewUint16Value(300), NewUint16Value(300), NewUint16Value(24464)},
+		{"int32 mul wraps", ColumnTypeInt32, mul, NewInt32Value(math.MaxInt32), NewInt32Value(2), NewInt32Value(-2)},
+		{"int32 div truncates", ColumnTypeInt32, div, NewInt32Value(-7), NewInt32Value(2), NewInt32Value(-3)},
+		{"uint32 add wraps", ColumnTypeUint32, add, NewUint32Value(math.MaxUint32), NewUint32Value(2), NewUint32Value(1)},
+		{"int64 div overflow", ColumnTypeInt64, div, NewInt64Value(math.MinInt64), NewInt64Value(-1), NewInt64Value(math.MinInt64)},
+		{"uint64 mul", ColumnTypeUint64, mul, NewUint64Value(1 << 40), NewUint64Value(1 << 20), NewUint64Value(1 << 60)},
+		{"float32 rounds", ColumnTypeFloat32, add, NewFloat32Value(16777216), NewFloat32Value(1), NewFloat32Value(16777216)},
+		{"float32 div", ColumnTypeFloat32, div, NewFloat32Value(1), NewFloat32Value(3), NewFloat32Value(float32(1) / 3)},
+		{"float64 add", ColumnTypeFloat64, add, NewFloat64Value(0.1), NewFloat64Value(0.2), NewFloat64Value(0.30000000000000004)},
+		{"float64 div by zero", ColumnTypeFloat64, div, NewFloat64Value(-1), NewFloat64Value(0), NewFloat64Value(math.Inf(-1))},
+	}
+
+	for _, tc := range testCases {
+		result := tc.op(tc.ctype, tc.a, tc.b)
+		if !bytes.Equal(result, tc.expect) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.ctype.FormatValue(tc.expect), tc.ctype.FormatValue(result))
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected integer division by zero to panic")
+		}
+	}()
+	ColumnTypeInt32.Div(NewInt32Value(1), NewInt32Value(0))
+}
+
+func TestColumnTryEncodeValue(t *testing.T) {
+	column := NewColumnInt32("count", 0)
+	_, err := column.TryEncodeValue("12")
+	var typeErr ValueTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected value type error, got %v", err)
+	}
+	if typeErr.Expected != "int32" || typeErr.Actual != "string" {
+		t.Errorf("expected int32 and string in error, got %s and %s", typeErr.Expected, typeErr.Actual)
+	}
+
+	val, err := column.TryEncodeValue(int32(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.AsInt32() != 12 {
+		t.Errorf("expected 12, got %d", val.AsInt32())
+	}
+	if val := ColumnTypeUint8.EncodeValue(uint8(7)); !slices.Equal(val, []byte{7}) {
+		t.Errorf("expected uint8 encoding [7], got %v", val)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected EncodeValue to panic on a type mismatch")
+		}
+	}()
+	column.EncodeValue("12")
+}
+
+func TestValidColumnName(t *testing.T) {
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{"", false},
+		{" ", false},
+		{"\t\n", false},
+		{" depth", false},
+		{"sea level", false},
+		{"1st", false},
+		{"-flag", false},
+		{"depth,m", false},
+		{"depth", true},
+		{"_hidden", true},
+		{"band2", true},
+		{"sea-level.mean", true},
+		{"température", true},
+	}
+	for _, tc := range testCases {
+		if valid := ValidColumnName(tc.name); valid != tc.valid {
+			t.Errorf("expected column name %q valid to be %v, got %v", tc.name, tc.valid, valid)
+		}
+	}
+
+	dir, err := os.MkdirTemp(".", "pixidb_column_names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"", "   "} {
+		_, err := NewStore(filepath.Join(dir, "invalid"), 10, NewColumnInt32("count", 0), NewColumnInt32(name, 0))
+		var nameErr InvalidColumnNameError
+		if !errors.As(err, &nameErr) || nameErr.Column != name {
+			t.Errorf("expected invalid column name error for %q, got %v", name, err)
+		}
+	}
+	store, err := NewStore(filepath.Join(dir, "valid"), 10, NewColumnInt32("sea-level.mean", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+}
+
+func TestColumnTypeEncodeFloat64(t *testing.T) {
+	testCases := []struct {
+		ctype  ColumnType
+		input  float64
+		expect float64
+	}{
+		{ColumnTypeInt8, 3.6, 4},
+		{ColumnTypeInt8, -1000, math.MinInt8},
+		{ColumnTypeUint8, -5, 0},
+		{ColumnTypeUint16, 70000, math.MaxUint16},
+		{ColumnTypeInt32, -2.5, -3},
+		{ColumnTypeInt64, math.NaN(), 0},
+		{ColumnTypeUint64, 1e30, math.MaxUint64},
+		{ColumnTypeFloat32, 0.5, 0.5},
+		{ColumnTypeFloat64, -1.25, -1.25},
+		{ColumnTypeComplex128, 2, 2},
+	}
+	for _, tc := range testCases {
+		val := tc.ctype.EncodeFloat64(tc.input)
+		if len(val) != tc.ctype.Size() {
+			t.Errorf("%v: expected %d bytes, got %d", tc.ctype, tc.ctype.Size(), len(val))
+		}
+		if got := tc.ctype.DecodeFloat64(val); got != tc.expect {
+			t.Errorf("%v: expected %v to encode as %v, got %v", tc.ctype, tc.input, tc.expect, got)
+		}
+	}
+}
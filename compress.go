@@ -0,0 +1,123 @@
+package pixidb
+
+// How the compressed column group of a stored page is kept, as is when compressing it would not
+// make it any shorter.
+const (
+	columnGroupRaw byte = iota
+	columnGroupLZ4
+)
+
+// The bytes of each page of a store with compressed columns taken by the length of the stored
+// page and the flag saying how its compressed column group is kept, which leave less room for rows.
+const compressedPageOverhead = pageLengthSize + 1
+
+// Stores the pages of a store with compressed columns as the values of the uncompressed columns
+// of each row, row by row, followed by the values of the compressed columns, column by column
+// and LZ4 compressed together so that runs of similar values compress well.
+type columnGroupCodec struct {
+	rowSize     int
+	rowsPerPage int
+	layout      StoreLayout        // how the values are arranged on pages in the cache
+	plain       []ColumnProjection // the uncompressed columns, kept row-aligned
+	packed      []ColumnProjection // the compressed columns, kept column by column
+	plainSize   int                // the bytes of the uncompressed columns in each row
+	packedSize  int                // the bytes of the compressed columns in each row
+}
+
+// Whether any of the columns is compressed, in which case pages of the data file are encoded.
+func hasCompressedColumns(columns []Column) bool {
+	for _, c := range columns {
+		if c.Compressed {
+			return true
+		}
+	}
+	return false
+}
+
+// The codec for pages holding the given number of rows of the columns, or nil when none of the
+// columns are compressed and pages are stored as they are.
+func newColumnGroupCodec(columns []Column, rowsPerPage int, layout StoreLayout) pageCodec {
+	if !hasCompressedColumns(columns) {
+		return nil
+	}
+	codec := &columnGroupCodec{rowsPerPage: rowsPerPage, layout: layout}
+	for i, c := range columns {
+		proj := ColumnProjection{i, codec.rowSize, c.Size()}
+		codec.rowSize += c.Size()
+		if c.Compressed {
+			codec.packed = append(codec.packed, proj)
+			codec.packedSize += c.Size()
+		} else {
+			codec.plain = append(codec.plain, proj)
+			codec.plainSize += c.Size()
+		}
+	}
+	return codec
+}
+
+// The offset within a page in the cache of the value of the column in the row at the slot.
+func (c *columnGroupCodec) valueOffset(slot int, col ColumnProjection) int {
+	return layoutOffset(c.layout, c.rowsPerPage, c.rowSize, slot, col)
+}
+
+func (c *columnGroupCodec) encode(page []byte) []byte {
+	stored := make([]byte, 1, 1+c.rowsPerPage*c.rowSize)
+	for r := 0; r < c.rowsPerPage; r++ {
+		for _, col := range c.plain {
+			start := c.valueOffset(r, col)
+			stored = append(stored, page[start:start+col.size]...)
+		}
+	}
+
+	group := make([]byte, 0, c.rowsPerPage*c.packedSize)
+	for _, col := range c.packed {
+		for r := 0; r < c.rowsPerPage; r++ {
+			start := c.valueOffset(r, col)
+			group = append(group, page[start:start+col.size]...)
+		}
+	}
+	if compressed := lz4CompressBlock(group); len(compressed) < len(group) {
+		stored[0] = columnGroupLZ4
+		return append(stored, compressed...)
+	}
+	stored[0] = columnGroupRaw
+	return append(stored, group...)
+}
+
+func (c *columnGroupCodec) decode(stored []byte, page []byte) error {
+	plainEnd := 1 + c.rowsPerPage*c.plainSize
+	if len(stored) < plainEnd {
+		return ErrCompressedCorrupted
+	}
+	offset := 1
+	for r := 0; r < c.rowsPerPage; r++ {
+		for _, col := range c.plain {
+			start := c.valueOffset(r, col)
+			offset += copy(page[start:start+col.size], stored[offset:])
+		}
+	}
+
+	group := stored[plainEnd:]
+	switch stored[0] {
+	case columnGroupRaw:
+		if len(group) != c.rowsPerPage*c.packedSize {
+			return ErrCompressedCorrupted
+		}
+	case columnGroupLZ4:
+		decompressed := make([]byte, c.rowsPerPage*c.packedSize)
+		if err := lz4DecompressBlock(group, decompressed); err != nil {
+			return err
+		}
+		group = decompressed
+	default:
+		return ErrCompressedCorrupted
+	}
+	offset = 0
+	for _, col := range c.packed {
+		for r := 0; r < c.rowsPerPage; r++ {
+			start := c.valueOffset(r, col)
+			offset += copy(page[start:start+col.size], group[offset:])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package pixidb
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func mixedCompressionColumns() []Column {
+	return []Column{
+		NewColumnInt32("id", -1),
+		NewColumnUint8("mask", 0).WithCompression(),
+		NewColumnFloat64("field", 0).WithCompression(),
+		NewColumnInt16("hot", 3),
+	}
+}
+
+func mixedCompressionRow(r int) Row {
+	row := NewInt32Value(int32(r))
+	row = append(row, NewUint8Value(uint8(r/40%2))...)
+	row = append(row, NewFloat64Value(float64(r%7)*0.5)...)
+	return Row(append(row, NewInt16Value(int16(-r))...))
+}
+
+func TestStoreCompressedColumns(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_compressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mixed")
+	store, err := NewStorePageSize(path, 500, 256, mixedCompressionColumns()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (256 - compressedPageOverhead) / store.RowSize(); store.RowsPerPage() != expect {
+		t.Errorf("expected %d rows per page, got %d", expect, store.RowsPerPage())
+	}
+	compareRow(t, store, 42, Row(append(append(append(NewInt32Value(-1), 0), NewFloat64Value(0)...), NewInt16Value(3)...)))
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetRowAt(r, mixedCompressionRow(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the compressed columns of a page take less room than they would uncompressed
+	raw, err := os.ReadFile(filepath.Join(path, "mixed"+DataFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := int(binary.BigEndian.Uint32(raw[ChecksumSize:]))
+	if uncompressed := 1 + store.RowsPerPage()*store.RowSize(); stored >= uncompressed {
+		t.Errorf("expected the stored page to be shorter than %d bytes, got %d", uncompressed, stored)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for r := 0; r < reopened.Rows; r++ {
+		compareRow(t, reopened, r, mixedCompressionRow(r))
+	}
+	field, err := reopened.GetColumnValueAt("field", 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.AsFloat64() != 3 {
+		t.Errorf("expected field value 3 in row 13, got %f", field.AsFloat64())
+	}
+	if corrupted, err := reopened.Verify(); err != nil || len(corrupted) != 0 {
+		t.Errorf("expected the compressed store to verify, got %v, %v", corrupted, err)
+	}
+
+	corruptPage(t, reopened, 3)
+	reopened.file.ClearCache()
+	if corrupted, err := reopened.Verify(); err != nil || !slices.Equal(corrupted, []int{3}) {
+		t.Errorf("expected corruption of page 3 to be found, got %v, %v", corrupted, err)
+	}
+	var corruptErr PageCorruptedError
+	if _, err := reopened.GetRowAt(3 * reopened.RowsPerPage()); !errors.As(err, &corruptErr) || corruptErr.Page != 3 {
+		t.Errorf("expected a corrupted page error for page 3, got %v", err)
+	}
+
+	if _, err := reopened.Repair(RepairOptions{Mode: RepairResetDefault}); err != nil {
+		t.Fatal(err)
+	}
+	compareRow(t, reopened, 3*reopened.RowsPerPage(), reopened.DefaultRow())
+	compareRow(t, reopened, 4*reopened.RowsPerPage(), mixedCompressionRow(4*reopened.RowsPerPage()))
+}
+
+func TestMemoryStoreCompressedColumns(t *testing.T) {
+	store, err := NewMemoryStore("mixed", 3000, mixedCompressionColumns()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetRowAt(r, mixedCompressionRow(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// write every page out and empty the cache so that each is read back through the codec,
+	// which checkpoints skip for stores in memory
+	if _, err := store.file.FlushAllPages(); err != nil {
+		t.Fatal(err)
+	}
+	store.file.ClearCache()
+	for r := 0; r < store.Rows; r++ {
+		compareRow(t, store, r, mixedCompressionRow(r))
+	}
+}
@@ -0,0 +1,191 @@
+package pixidb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The number of rows read from a CSV file before they are written to the table together.
+const csvImportBatchSize int = 1024
+
+// Write the given columns of every pixel in the table as CSV, in storage order. The header row
+// names each column, preceded by 'lat' and 'lon' columns holding the pixel centers in radians
+// when the indexer is able to locate its pixels on the sphere. Values matching the no-data
+// sentinel of their column are written as empty fields.
+func (t *Table) ExportCSV(w io.Writer, columns []string) error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	columnProj, err := t.store.Projection(columns...)
+	if err != nil {
+		return err
+	}
+	columnSet := t.store.FilterColumns(columnProj)
+
+	_, err = t.Indexer.ToLocation(0)
+	var notSupported *LocationNotSupportedError
+	located := !errors.As(err, &notSupported)
+
+	header := make([]string, 0, len(columns)+2)
+	if located {
+		header = append(header, "lat", "lon")
+	}
+	header = append(header, columns...)
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	for i := 0; i < t.store.Rows; i++ {
+		row, err := t.store.GetRowAt(i)
+		if err != nil {
+			return err
+		}
+		fields := record
+		if located {
+			loc, err := t.Indexer.ToLocation(i)
+			if err != nil {
+				return err
+			}
+			record[0] = strconv.FormatFloat(loc.Latitude, 'g', -1, 64)
+			record[1] = strconv.FormatFloat(loc.Longitude, 'g', -1, 64)
+			fields = record[2:]
+		}
+		for vInd, val := range row.Project(columnProj) {
+			if columnSet[vInd].IsNoData(val) {
+				fields[vInd] = ""
+			} else {
+				fields[vInd] = columnSet[vInd].Type.FormatValue(val)
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Read rows of values from CSV into the given columns of the table, returning the number of rows
+// written. The header row must name each of the columns, along with either an 'index' column
+// holding the storage index of each row's pixel or 'lat' and 'lon' columns holding a location in
+// radians, which is written to the pixel with the nearest center. Other columns in the file are
+// ignored, so the output of ExportCSV can be read back. Empty fields are written as the no-data
+// sentinel of columns that have one. Reading stops at the first malformed row, which is reported
+// in a CSVRowError numbered from the header as row 1; the rows before it are still written.
+func (t *Table) ImportCSV(r io.Reader, columns []string) (int, error) {
+	columnProj, err := t.store.Projection(columns...)
+	if err != nil {
+		return 0, err
+	}
+	columnSet := t.store.FilterColumns(columnProj)
+
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.ReuseRecord = true
+	header, err := in.Read()
+	if err != nil {
+		return 0, NewCSVRowError(1, err)
+	}
+	fields := make(map[string]int, len(header))
+	for i, name := range header {
+		fields[name] = i
+	}
+	indexField, indexed := fields["index"]
+	latField, hasLat := fields["lat"]
+	lonField, hasLon := fields["lon"]
+	if !indexed && !(hasLat && hasLon) {
+		return 0, NewCSVRowError(1, errors.New("header needs an 'index' column or 'lat' and 'lon' columns"))
+	}
+	valueFields := make([]int, len(columns))
+	for i, c := range columns {
+		field, ok := fields[c]
+		if !ok {
+			return 0, NewCSVRowError(1, fmt.Errorf("header is missing column '%s'", c))
+		}
+		valueFields[i] = field
+	}
+
+	imported := 0
+	locations := make([]Location, 0, csvImportBatchSize)
+	values := make([][]Value, 0, csvImportBatchSize)
+	flush := func() error {
+		written, err := t.SetRows(columns, locations, values)
+		imported += written
+		locations, values = locations[:0], values[:0]
+		return err
+	}
+	parse := func(record []string) (Location, []Value, error) {
+		if len(record) != len(header) {
+			return nil, nil, fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+		}
+		var loc Location
+		if indexed {
+			index, err := strconv.Atoi(record[indexField])
+			if err != nil {
+				return nil, nil, err
+			}
+			if index < 0 || index >= t.Indexer.Size() {
+				return nil, nil, NewLocationOutOfBoundsError(IndexLocation(index))
+			}
+			loc = IndexLocation(index)
+		} else {
+			lat, err := strconv.ParseFloat(record[latField], 64)
+			if err != nil {
+				return nil, nil, err
+			}
+			lon, err := strconv.ParseFloat(record[lonField], 64)
+			if err != nil {
+				return nil, nil, err
+			}
+			index, _, err := t.Indexer.NearestIndex(SphericalLocation{lat, lon})
+			if err != nil {
+				return nil, nil, err
+			}
+			loc = IndexLocation(index)
+		}
+		vals := make([]Value, len(columns))
+		for i, field := range valueFields {
+			if record[field] == "" && columnSet[i].NoData != nil {
+				vals[i] = columnSet[i].NoData
+				continue
+			}
+			val, err := columnSet[i].Type.ParseValue(record[field])
+			if err != nil {
+				return nil, nil, fmt.Errorf("column '%s': %w", columns[i], err)
+			}
+			vals[i] = val
+		}
+		return loc, vals, nil
+	}
+
+	for row := 2; ; row++ {
+		record, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		var loc Location
+		var vals []Value
+		if err == nil {
+			loc, vals, err = parse(record)
+		}
+		if err != nil {
+			if flushErr := flush(); flushErr != nil {
+				return imported, flushErr
+			}
+			return imported, NewCSVRowError(row, err)
+		}
+		locations = append(locations, loc)
+		values = append(values, vals)
+		if len(locations) == csvImportBatchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	err = flush()
+	return imported, err
+}
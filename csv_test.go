@@ -0,0 +1,229 @@
+package pixidb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestTableExportCSV(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_export_csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexer := NewCylindricalEquirectangularIndexer(0, 4, 3, true)
+	tbl, err := NewTable(filepath.Join(dir, "exported"), indexer,
+		NewColumnFloat64("temp", math.NaN()).WithNoData(NewFloat64Value(-9999)),
+		NewColumnInt16("count", 0),
+		NewColumnUint8("flag", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < indexer.Size(); i++ {
+		temp := NewFloat64Value(float64(i) / 3)
+		if i%5 == 0 {
+			temp = NewFloat64Value(-9999)
+		}
+		if err := tbl.SetValue("temp", IndexLocation(i), temp); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.SetValue("count", IndexLocation(i), NewInt16Value(int16(-i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tbl.ExportCSV(&buf, []string{"count", "temp"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != indexer.Size()+1 {
+		t.Fatalf("expected %d records, got %d", indexer.Size()+1, len(records))
+	}
+	if !slices.Equal(records[0], []string{"lat", "lon", "count", "temp"}) {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	for i, record := range records[1:] {
+		center, err := indexer.ToLocation(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lat, err := strconv.ParseFloat(record[0], 64)
+		if err != nil || lat != center.Latitude {
+			t.Errorf("expected latitude %v for pixel %d, got %s", center.Latitude, i, record[0])
+		}
+		lon, err := strconv.ParseFloat(record[1], 64)
+		if err != nil || lon != center.Longitude {
+			t.Errorf("expected longitude %v for pixel %d, got %s", center.Longitude, i, record[1])
+		}
+		if record[2] != strconv.Itoa(-i) {
+			t.Errorf("expected count %d for pixel %d, got %s", -i, i, record[2])
+		}
+		if i%5 == 0 {
+			if record[3] != "" {
+				t.Errorf("expected no-data temp for pixel %d, got %s", i, record[3])
+			}
+			continue
+		}
+		temp, err := strconv.ParseFloat(record[3], 64)
+		if err != nil || temp != float64(i)/3 {
+			t.Errorf("expected temp %v for pixel %d, got %s", float64(i)/3, i, record[3])
+		}
+	}
+
+	buf.Reset()
+	if err := tbl.ExportCSV(&buf, []string{"missing"}); err == nil {
+		t.Errorf("expected error exporting a missing column")
+	}
+
+	plain, err := NewTable(filepath.Join(dir, "plain"), NewProjectionlessIndexer(2, 2, true), NewColumnUint8("flag", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := plain.ExportCSV(&buf, []string{"flag"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "flag\n7\n7\n7\n7\n" {
+		t.Errorf("unexpected projectionless export %q", buf.String())
+	}
+}
+
+func TestTableImportCSV(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_import_csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexer := NewCylindricalEquirectangularIndexer(0, 4, 3, true)
+	columns := []Column{
+		NewColumnFloat64("temp", 0).WithNoData(NewFloat64Value(-9999)),
+		NewColumnInt16("count", 0),
+	}
+
+	t.Run("indexed", func(t *testing.T) {
+		tbl, err := NewTable(filepath.Join(dir, "indexed"), indexer, columns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := "count,ignored,index,temp\n-4,x,3,1.5\n7,y,10,\n-32768,z,0,-2.25e-3\n"
+		imported, err := tbl.ImportCSV(bytes.NewBufferString(input), []string{"temp", "count"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported != 3 {
+			t.Errorf("expected 3 rows imported, got %d", imported)
+		}
+		result, err := tbl.GetRows([]string{"temp", "count"}, IndexLocation(3), IndexLocation(10), IndexLocation(0), IndexLocation(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]Value{
+			{NewFloat64Value(1.5), NewInt16Value(-4)},
+			{NewFloat64Value(-9999), NewInt16Value(7)},
+			{NewFloat64Value(-2.25e-3), NewInt16Value(-32768)},
+			{NewFloat64Value(0), NewInt16Value(0)},
+		}
+		for i, row := range result.Rows {
+			for j, val := range row {
+				if !slices.Equal(val, expected[i][j]) {
+					t.Errorf("expected value %v in row %d column %d, got %v", expected[i][j], i, j, val)
+				}
+			}
+		}
+	})
+
+	t.Run("exported", func(t *testing.T) {
+		source, err := NewTable(filepath.Join(dir, "source"), indexer, columns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < indexer.Size(); i++ {
+			if err := source.SetValue("temp", IndexLocation(i), NewFloat64Value(float64(i)/7)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := source.ExportCSV(&buf, []string{"temp"}); err != nil {
+			t.Fatal(err)
+		}
+		dest, err := NewTable(filepath.Join(dir, "dest"), indexer, columns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, err := dest.ImportCSV(&buf, []string{"temp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported != indexer.Size() {
+			t.Errorf("expected %d rows imported, got %d", indexer.Size(), imported)
+		}
+		for i := 0; i < indexer.Size(); i++ {
+			result, err := dest.GetRows([]string{"temp"}, IndexLocation(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if temp := result.Rows[0][0].AsFloat64(); temp != float64(i)/7 {
+				t.Errorf("expected temp %v for pixel %d after round trip, got %v", float64(i)/7, i, temp)
+			}
+		}
+	})
+
+	malformedCases := []struct {
+		name     string
+		input    string
+		imported int
+		row      int
+	}{
+		{"wrong field count", "index,count\n0,1\n1,2\n2\n3,4\n", 2, 4},
+		{"unparseable number", "index,count\n0,1\n1,two\n", 1, 3},
+		{"overflowing number", "index,count\n0,40000\n", 0, 2},
+		{"unparseable index", "index,count\nfirst,1\n", 0, 2},
+		{"index out of range", "index,count\n0,1\n12,1\n", 1, 3},
+		{"empty without no-data", "index,count\n0,\n", 0, 2},
+		{"missing value column", "index,temp\n0,1\n", 0, 1},
+		{"missing location columns", "lat,count\n0,1\n", 0, 1},
+		{"empty file", "", 0, 1},
+	}
+	for _, tc := range malformedCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tbl, err := NewTable(filepath.Join(dir, "malformed"), indexer, columns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tbl.Drop()
+
+			imported, err := tbl.ImportCSV(bytes.NewBufferString(tc.input), []string{"count"})
+			var rowErr CSVRowError
+			if !errors.As(err, &rowErr) {
+				t.Fatalf("expected csv row error, got %v", err)
+			}
+			if rowErr.Row != tc.row {
+				t.Errorf("expected error on row %d, got %d", tc.row, rowErr.Row)
+			}
+			if imported != tc.imported {
+				t.Errorf("expected %d rows imported, got %d", tc.imported, imported)
+			}
+		})
+	}
+
+	tbl, err := NewTable(filepath.Join(dir, "unknown"), indexer, columns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tbl.ImportCSV(bytes.NewBufferString("index,other\n0,1\n"), []string{"other"}); err == nil {
+		t.Errorf("expected error importing into a missing table column")
+	}
+}
@@ -1,33 +1,135 @@
 package pixidb
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 
 	"golang.org/x/exp/maps"
 )
 
+// A summary of the schema of a table in a database.
+type TableDescription struct {
+	Name     string
+	Indexer  string            // the name of the location indexer of the table
+	Pixels   int               // the number of locations, and rows, in the table
+	Columns  []Column          // the columns of each row, in storage order
+	Metadata map[string]string // a copy of the metadata of the table
+}
+
+// The name of the file in the directory of a database holding its configuration.
+const DatabaseFileName = "database.json"
+
+// The version of the layout of a database directory written by this version of the package.
+const DatabaseFormatVersion = 1
+
+// Settings of a database, kept in its directory and applied to the tables created in it.
+type DatabaseConfig struct {
+	FormatVersion int `json:"formatVersion"`
+	PageSize      int `json:"pageSize,omitempty"` // the page size of new tables, the host default when zero
+}
+
 type Database struct {
-	dbPath string
-	tables map[string]*Table
-	lock   sync.RWMutex
+	dbPath     string
+	config     DatabaseConfig
+	tables     map[string]*Table // tables of a lazily opened database are nil until first accessed
+	lock       sync.RWMutex      // guards the tables map only, never held across table operations
+	createLock sync.Mutex        // serializes table creation so files are never created concurrently
+	closed     bool              // set once the database is closed, guarded by the lock
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
+	return NewDatabaseConfig(dbPath, DatabaseConfig{})
+}
+
+// Create a new database like NewDatabase, whose tables are created with the given settings. If
+// the directory already holds a database, its own settings are kept and the given ones ignored.
+func NewDatabaseConfig(dbPath string, config DatabaseConfig) (*Database, error) {
 	// make sure the directory exists
 	if err := os.MkdirAll(dbPath, os.ModePerm); err != nil {
 		return nil, err
 	}
 
+	if _, err := os.Stat(filepath.Join(dbPath, DatabaseFileName)); err == nil {
+		if config, err = readDatabaseConfig(dbPath); err != nil {
+			return nil, err
+		}
+	} else {
+		config.FormatVersion = DatabaseFormatVersion
+		if err := writeDatabaseConfig(dbPath, config); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Database{
 		dbPath: dbPath,
+		config: config,
 		tables: map[string]*Table{},
 		lock:   sync.RWMutex{},
 	}, nil
 }
 
+func writeDatabaseConfig(dbPath string, config DatabaseConfig) error {
+	jsonData, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return writeMetadataFile(filepath.Join(dbPath, DatabaseFileName), jsonData, false)
+}
+
+// Read the settings of the database in the directory. Directories written before databases kept
+// their settings are recognized by holding nothing but table directories, and get the default
+// settings. Returns a NotDatabaseError for any other directory without settings.
+func readDatabaseConfig(dbPath string) (DatabaseConfig, error) {
+	config := DatabaseConfig{}
+	jsonText, _, err := readMetadataFile(filepath.Join(dbPath, DatabaseFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		config.FormatVersion = DatabaseFormatVersion
+		return config, checkLegacyDatabase(dbPath)
+	} else if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(jsonText, &config); err != nil {
+		return config, NewNotDatabaseError(dbPath, fmt.Sprintf("unreadable %s: %v", DatabaseFileName, err))
+	}
+	if config.FormatVersion > DatabaseFormatVersion {
+		return config, NewDatabaseVersionError(dbPath, config.FormatVersion, DatabaseFormatVersion)
+	}
+	return config, nil
+}
+
+// Check that every entry of a directory without database settings is the directory of a table.
+// Hidden files, such as those left by file browsers, and the temporary files of interrupted
+// metadata writes are ignored.
+func checkLegacyDatabase(dbPath string) error {
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && (strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) == ".tmp") {
+			continue
+		}
+		if !e.IsDir() {
+			return NewNotDatabaseError(dbPath, fmt.Sprintf("no %s, and '%s' is not a table directory", DatabaseFileName, e.Name()))
+		}
+		if _, err := os.Stat(filepath.Join(dbPath, e.Name(), e.Name()+MetadataFileExt)); err != nil {
+			return NewNotDatabaseError(dbPath, fmt.Sprintf("no %s, and '%s' holds no table", DatabaseFileName, e.Name()))
+		}
+	}
+	return nil
+}
+
 func OpenDatabase(dbPath string) (*Database, error) {
+	config, err := readDatabaseConfig(dbPath)
+	if err != nil {
+		return nil, err
+	}
 	entries, err := os.ReadDir(dbPath)
 	if err != nil {
 		return nil, err
@@ -38,24 +140,80 @@ func OpenDatabase(dbPath string) (*Database, error) {
 		if e.IsDir() {
 			table, err := OpenTable(filepath.Join(dbPath, e.Name()))
 			if err != nil {
+				// release the tables already opened so that they can be opened again
+				for _, opened := range tables {
+					opened.Close()
+				}
 				return nil, err
 			}
+			table.managed = true
 			tables[e.Name()] = table
 		}
 	}
 
 	return &Database{
 		dbPath: dbPath,
+		config: config,
+		tables: tables,
+		lock:   sync.RWMutex{},
+	}, nil
+}
+
+// Open the database in the directory without opening any of its tables, each of which is opened
+// the first time it is accessed through the database. Errors opening a table are returned from
+// that access rather than from here, and the access can be retried.
+func OpenDatabaseLazy(dbPath string) (*Database, error) {
+	config, err := readDatabaseConfig(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := map[string]*Table{}
+	for _, e := range entries {
+		if e.IsDir() {
+			tables[e.Name()] = nil
+		}
+	}
+
+	return &Database{
+		dbPath: dbPath,
+		config: config,
 		tables: tables,
 		lock:   sync.RWMutex{},
 	}, nil
 }
 
+// Create a new table in the database. Errors with a TableExistsError if the database already
+// has a table with the name, or if the files of a table are already in its place on disk.
 func (d *Database) Create(tableName string, indexer LocationIndexer, columns ...Column) error {
-	table, err := NewTable(filepath.Join(d.dbPath, tableName), indexer, columns...)
+	d.createLock.Lock()
+	defer d.createLock.Unlock()
+
+	d.lock.RLock()
+	closed := d.closed
+	_, exists := d.tables[tableName]
+	d.lock.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	tablePath := filepath.Join(d.dbPath, tableName)
+	if _, err := os.Stat(filepath.Join(tablePath, tableName+MetadataFileExt)); exists || err == nil {
+		return NewTableExistsError(tableName)
+	}
+
+	pageSize := d.config.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize()
+	}
+	table, err := NewTablePageSize(tablePath, indexer, pageSize, columns...)
 	if err != nil {
 		return err
 	}
+	table.managed = true
 
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -63,88 +221,403 @@ func (d *Database) Create(tableName string, indexer LocationIndexer, columns ...
 	return nil
 }
 
-func (d *Database) Drop(tableName string) error {
-	err := d.tables[tableName].Drop()
+// Create a new table in the database like Create, unless a table with the name already exists,
+// in which case nothing is done. The existing table is left untouched even if its indexer or
+// columns differ from those given.
+func (d *Database) CreateIfNotExists(tableName string, indexer LocationIndexer, columns ...Column) error {
+	err := d.Create(tableName, indexer, columns...)
+	if errors.As(err, &TableExistsError{}) {
+		return nil
+	}
+	return err
+}
 
+// Delete the table and its files, after which the database no longer has a table with the name.
+// This is the only way to drop a table of the database, as Table.Drop refuses managed tables.
+func (d *Database) Drop(tableName string) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	table, err := d.openLocked(tableName)
+	if err != nil {
+		return err
+	}
+	if err := table.drop(); err != nil {
+		return err
+	}
 	delete(d.tables, tableName)
-	return err
+	return nil
+}
+
+// Rename a table, moving its directory and files to match the new name. Errors if no table has
+// the old name, or if a table with the new name already exists.
+func (d *Database) Rename(oldName string, newName string) error {
+	d.createLock.Lock()
+	defer d.createLock.Unlock()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+
+	if _, ok := d.tables[newName]; ok {
+		return NewTableExistsError(newName)
+	}
+	table, err := d.openLocked(oldName)
+	if err != nil {
+		return err
+	}
+
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if err := table.moveTo(filepath.Join(d.dbPath, newName)); err != nil {
+		return err
+	}
+	delete(d.tables, oldName)
+	d.tables[newName] = table
+	return nil
+}
+
+// Copy a table into a new table with another name, which shares nothing with the original so
+// that writes to either leave the other unchanged. Dirty pages of the original are flushed before
+// its files are copied, and writes to it are blocked until the copy is complete. Errors if no
+// table has the source name, or if a table with the destination name already exists.
+func (d *Database) Clone(srcName string, destName string) error {
+	d.createLock.Lock()
+	defer d.createLock.Unlock()
+
+	d.lock.RLock()
+	closed := d.closed
+	_, exists := d.tables[destName]
+	d.lock.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	destPath := filepath.Join(d.dbPath, destName)
+	if _, err := os.Stat(destPath); exists || err == nil {
+		return NewTableExistsError(destName)
+	}
+	src, err := d.lookup(srcName)
+	if err != nil {
+		return err
+	}
+
+	src.lock.RLock()
+	if src.closed {
+		src.lock.RUnlock()
+		return ErrClosed
+	}
+	err = src.copyTo(destPath)
+	src.lock.RUnlock()
+	if err != nil {
+		os.RemoveAll(destPath)
+		return err
+	}
+	clone, err := OpenTable(destPath)
+	if err != nil {
+		os.RemoveAll(destPath)
+		return err
+	}
+	clone.managed = true
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.tables[destName] = clone
+	return nil
+}
+
+// The settings of the database, which apply to the tables created in it.
+func (d *Database) Config() DatabaseConfig {
+	return d.config
 }
 
 func (d *Database) GetTableNames() ([]string, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
 	return maps.Keys(d.tables), nil
 }
 
-func (d *Database) Table(name string) *Table {
+// Describe every table in the database, ordered by name.
+func (d *Database) Describe() ([]TableDescription, error) {
+	if err := d.openAll(); err != nil {
+		return nil, err
+	}
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.tables[name]
+	if d.closed {
+		return nil, ErrClosed
+	}
+
+	names := maps.Keys(d.tables)
+	slices.Sort(names)
+	descriptions := make([]TableDescription, len(names))
+	for i, name := range names {
+		table := d.tables[name]
+		table.lock.RLock()
+		descriptions[i] = TableDescription{
+			Name:     name,
+			Indexer:  table.IndexerName,
+			Pixels:   table.Indexer.Size(),
+			Columns:  slices.Clone(table.store.ColumnSet),
+			Metadata: maps.Clone(table.Metadata),
+		}
+		table.lock.RUnlock()
+	}
+	return descriptions, nil
 }
 
-func (d *Database) GetColumns(tableName string) ([]Column, error) {
+// The managed handle of the table with the given name, which shares its cache with every other
+// access through the database. Errors with a TableNotFoundError if there is no such table.
+func (d *Database) Table(name string) (*Table, error) {
+	return d.lookup(name)
+}
+
+// Find the managed table with the given name, holding the database lock only for
+// the duration of the lookup so that slow table operations do not block other tables.
+// Opens the table if it has not been opened yet.
+func (d *Database) lookup(tableName string) (*Table, error) {
 	d.lock.RLock()
-	defer d.lock.RUnlock()
-	if table, ok := d.tables[tableName]; !ok {
+	closed := d.closed
+	table, ok := d.tables[tableName]
+	d.lock.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	if !ok {
 		return nil, NewTableNotFoundError(tableName)
-	} else {
-		return table.store.ColumnSet, nil
 	}
+	if table != nil {
+		return table, nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	return d.openLocked(tableName)
+}
+
+// Find the managed table with the given name, opening it if it has not been opened yet.
+// Must be called holding the write lock.
+func (d *Database) openLocked(tableName string) (*Table, error) {
+	table, ok := d.tables[tableName]
+	if !ok {
+		return nil, NewTableNotFoundError(tableName)
+	}
+	if table == nil {
+		opened, err := OpenTable(filepath.Join(d.dbPath, tableName))
+		if err != nil {
+			return nil, err
+		}
+		opened.managed = true
+		d.tables[tableName] = opened
+		table = opened
+	}
+	return table, nil
+}
+
+func (d *Database) GetColumns(tableName string) ([]Column, error) {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return table.store.ColumnSet, nil
 }
 
 func (d *Database) GetRows(tableName string, columns []string, locations ...Location) (ResultSet, error) {
-	d.lock.RLock()
-	defer d.lock.RUnlock()
-	if table, ok := d.tables[tableName]; !ok {
-		return ResultSet{}, NewTableNotFoundError(tableName)
-	} else {
-		return table.GetRows(columns, locations)
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return ResultSet{}, err
 	}
+	return table.GetRows(columns, locations...)
+}
+
+func (d *Database) Query(tableName string, columns []string, filters []FilterClause, locations ...Location) (ResultSet, error) {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	return table.Query(columns, filters, locations...)
 }
 
 func (d *Database) SetRows(tableName string, columns []string, locations []Location, values [][]Value) (int, error) {
-	d.lock.RLock()
-	defer d.lock.RUnlock()
-	if table, ok := d.tables[tableName]; !ok {
-		return 0, NewTableNotFoundError(tableName)
-	} else {
-		return table.SetRows(columns, locations, values)
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return 0, err
 	}
+	return table.SetRows(columns, locations, values)
+}
+
+// Write the same values of the given columns into the row at every location of the table with
+// the given name, see Table.SetRowsUniform.
+func (d *Database) SetRowsUniform(tableName string, columns []string, value []Value, locations ...Location) (int, error) {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return table.SetRowsUniform(columns, value, locations...)
 }
 
 func (d *Database) GetMetadata(tableName string, key string) (string, error) {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return "", err
+	}
+	metadata, _ := table.GetMetadata(key)
+	return metadata, nil
+}
+
+// A copy of all the metadata of the table with the given name.
+func (d *Database) GetAllMetadata(tableName string) (map[string]string, error) {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return table.AllMetadata(), nil
+}
+
+func (d *Database) SetMetadata(tableName string, key string, value string) error {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	return table.SetMetadata(key, value)
+}
+
+// Remove the key from the metadata of the table with the given name, see Table.DeleteMetadata.
+func (d *Database) DeleteMetadata(tableName string, key string) error {
+	table, err := d.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	return table.DeleteMetadata(key)
+}
+
+// Checkpoint every open table, returning the total number of pages written.
+func (d *Database) Checkpoint() (int, error) {
 	d.lock.RLock()
-	defer d.lock.RUnlock()
-	if table, ok := d.tables[tableName]; !ok {
-		return "", NewTableNotFoundError(tableName)
-	} else {
-		if metadata, ok := table.Metadata[key]; !ok {
-			return "", nil
-		} else {
-			return metadata, nil
+	closed := d.closed
+	tables := maps.Values(d.tables)
+	d.lock.RUnlock()
+	if closed {
+		return 0, ErrClosed
+	}
+
+	written := 0
+	for _, tbl := range tables {
+		if tbl == nil {
+			continue
+		}
+		pages, err := tbl.Checkpoint()
+		written += pages
+		if err != nil {
+			return written, err
 		}
 	}
+	return written, nil
 }
 
-func (d *Database) SetMetadata(tableName string, key string, value string) error {
+// The number of bytes the files of every table in the database take up on disk, see
+// Table.StorageBytes.
+func (d *Database) TotalBytes() (int64, error) {
+	if err := d.openAll(); err != nil {
+		return 0, err
+	}
 	d.lock.RLock()
-	defer d.lock.RUnlock()
-	if table, ok := d.tables[tableName]; !ok {
-		return NewTableNotFoundError(tableName)
-	} else {
-		return table.SetMetadata(key, value)
+	tables := maps.Values(d.tables)
+	d.lock.RUnlock()
+
+	total := int64(0)
+	for _, table := range tables {
+		size, err := table.StorageBytes()
+		if err != nil {
+			return 0, err
+		}
+		total += size
 	}
+	return total, nil
 }
 
-func (d *Database) Checkpoint() error {
+// Open every table that has not been opened yet.
+func (d *Database) openAll() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	for name := range d.tables {
+		if _, err := d.openLocked(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write a consistent copy of every table in the database into the destination directory, which
+// must be empty or not yet exist, and can then be opened with OpenDatabase. Dirty pages are
+// flushed first, and writes to every table are blocked until the whole copy is complete so that
+// the snapshot reflects a single moment across tables.
+func (d *Database) Snapshot(destDir string) error {
+	if err := d.openAll(); err != nil {
+		return err
+	}
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	for _, tbl := range d.tables {
-		if err := tbl.Checkpoint(); err != nil {
+	if d.closed {
+		return ErrClosed
+	}
+
+	if entries, err := os.ReadDir(destDir); err == nil && len(entries) > 0 {
+		return ErrSnapshotNotEmpty
+	}
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// lock in a consistent order so that concurrent snapshots can never deadlock
+	names := maps.Keys(d.tables)
+	slices.Sort(names)
+	for _, name := range names {
+		table := d.tables[name]
+		table.lock.RLock()
+		defer table.lock.RUnlock()
+	}
+	for _, name := range names {
+		if err := d.tables[name].copyTo(filepath.Join(destDir, name)); err != nil {
 			return err
 		}
 	}
-	return nil
+	return writeDatabaseConfig(destDir, d.config)
+}
+
+// Flush and close every table in the database, after which all operations on the database
+// return ErrClosed. Every table is closed even if closing one of them fails, in which case the
+// first failure is returned and the database stays open so that the close can be retried.
+// Closing an already closed database does nothing.
+func (d *Database) Close() error {
+	d.createLock.Lock()
+	defer d.createLock.Unlock()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil
+	}
+
+	var firstErr error
+	for _, table := range d.tables {
+		if table == nil {
+			continue
+		}
+		if err := table.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	d.closed = firstErr == nil
+	return firstErr
 }
@@ -1,11 +1,16 @@
 package pixidb
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
+	"sync"
 	"testing"
 
 	"github.com/owlpinetech/healpix"
+	"golang.org/x/exp/maps"
 )
 
 func TestOpenDatabase(t *testing.T) {
@@ -28,6 +33,9 @@ func TestOpenDatabase(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := orig.Close(); err != nil {
+		t.Fatal(err)
+	}
 
 	opened, err := OpenDatabase(dir)
 	if err != nil {
@@ -48,3 +56,920 @@ func TestOpenDatabase(t *testing.T) {
 		t.Errorf("expected table goodbye to be in database, but wasn't")
 	}
 }
+
+func TestDatabaseConcurrentAccess(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"one", "two", "three", "four"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := db.Create(name, NewProjectionlessIndexer(10, 10, true), NewColumnInt32("col1", 0)); err != nil {
+				t.Error(err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	tables, err := db.GetTableNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != len(names) {
+		t.Fatalf("expected to have %d tables, but had %d", len(names), len(tables))
+	}
+
+	// every table gets a writer per row and a reader racing against it
+	for _, name := range names {
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(name string, i int) {
+				defer wg.Done()
+				loc := GridLocation{X: i, Y: i}
+				if _, err := db.SetRows(name, []string{"col1"}, []Location{loc}, [][]Value{{NewInt32Value(int32(i))}}); err != nil {
+					t.Error(err)
+				}
+				if err := db.SetMetadata(name, "last", name); err != nil {
+					t.Error(err)
+				}
+			}(name, i)
+			go func(name string, i int) {
+				defer wg.Done()
+				if _, err := db.GetRows(name, []string{"col1"}, GridLocation{X: i, Y: i}); err != nil {
+					t.Error(err)
+				}
+				if _, err := db.GetMetadata(name, "last"); err != nil {
+					t.Error(err)
+				}
+			}(name, i)
+		}
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		for i := 0; i < 10; i++ {
+			res, err := db.GetRows(name, []string{"col1"}, GridLocation{X: i, Y: i})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Rows[0][0].AsInt32() != int32(i) {
+				t.Errorf("expected %d in table %s at %d,%d, got %d", i, name, i, i, res.Rows[0][0].AsInt32())
+			}
+		}
+	}
+}
+
+func TestDatabaseSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	snapDir, err := os.MkdirTemp(".", "pixidb_database_snapshot_dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(snapDir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("grid", NewProjectionlessIndexer(40, 40, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("sphere", NewFlatHealpixIndexer(2, healpix.NestScheme), NewColumnFloat64("temp", 1.5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("grid", []string{"value"}, []Location{IndexLocation(0), IndexLocation(1599)}, [][]Value{{NewInt32Value(7)}, {NewInt32Value(8)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("sphere", "source", "original"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Snapshot(snapDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("grid", []string{"value"}, []Location{IndexLocation(0), IndexLocation(800)}, [][]Value{{NewInt32Value(-1)}, {NewInt32Value(-2)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("sphere", []string{"temp"}, []Location{IndexLocation(5)}, [][]Value{{NewFloat64Value(-3)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("sphere", "source", "mutated"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := OpenDatabase(snapDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := snap.GetTableNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"grid", "sphere"}) {
+		t.Fatalf("expected snapshot tables grid and sphere, got %v", names)
+	}
+	grid, err := snap.GetRows("grid", []string{"value"}, IndexLocation(0), IndexLocation(800), IndexLocation(1599))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []int32{7, 0, 8} {
+		if val := grid.Rows[i][0].AsInt32(); val != expect {
+			t.Errorf("expected snapshot value %d in row %d, got %d", expect, i, val)
+		}
+	}
+	sphere, err := snap.GetRows("sphere", []string{"temp"}, IndexLocation(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp := sphere.Rows[0][0].AsFloat64(); temp != 1.5 {
+		t.Errorf("expected snapshot temp 1.5, got %v", temp)
+	}
+	if source, err := snap.GetMetadata("sphere", "source"); err != nil || source != "original" {
+		t.Errorf("expected snapshot metadata 'original', got %q", source)
+	}
+
+	if err := db.Snapshot(snapDir); !errors.Is(err, ErrSnapshotNotEmpty) {
+		t.Errorf("expected error snapshotting into a non-empty directory, got %v", err)
+	}
+}
+
+func TestDatabaseRename(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("before", NewProjectionlessIndexer(40, 40, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("other", NewProjectionlessIndexer(2, 2, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	locations := []Location{IndexLocation(0), IndexLocation(900), IndexLocation(1599)}
+	values := [][]Value{{NewInt32Value(1)}, {NewInt32Value(2)}, {NewInt32Value(3)}}
+	if _, err := db.SetRows("before", []string{"value"}, locations, values); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("before", "source", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Rename("before", "other"); !errors.As(err, &TableExistsError{}) {
+		t.Errorf("expected error renaming onto an existing table, got %v", err)
+	}
+	if err := db.Rename("missing", "after"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected error renaming a missing table, got %v", err)
+	}
+	if err := db.Rename("before", "after"); err != nil {
+		t.Fatal(err)
+	}
+
+	// pages dirtied both before and after the rename must be flushed to the renamed data file
+	if _, err := db.SetRows("after", []string{"value"}, []Location{IndexLocation(1)}, [][]Value{{NewInt32Value(4)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetRows("before", []string{"value"}, IndexLocation(0)); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected old table name to be gone, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := reopened.GetTableNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"after", "other"}) {
+		t.Fatalf("expected tables after and other, got %v", names)
+	}
+	result, err := reopened.GetRows("after", []string{"value"}, append(locations, IndexLocation(1))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []int32{1, 2, 3, 4} {
+		if val := result.Rows[i][0].AsInt32(); val != expect {
+			t.Errorf("expected value %d in row %d after rename, got %d", expect, i, val)
+		}
+	}
+	if source, err := reopened.GetMetadata("after", "source"); err != nil || source != "renamed" {
+		t.Errorf("expected metadata to survive rename, got %q", source)
+	}
+	if table, err := reopened.Table("after"); err != nil || table.Name() != "after" {
+		t.Errorf("expected reopened table name after, got %v", err)
+	}
+}
+
+func TestDatabaseClone(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("source", NewProjectionlessIndexer(40, 40, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("other", NewProjectionlessIndexer(2, 2, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	locations := []Location{IndexLocation(0), IndexLocation(900), IndexLocation(1599)}
+	values := [][]Value{{NewInt32Value(1)}, {NewInt32Value(2)}, {NewInt32Value(3)}}
+	if _, err := db.SetRows("source", []string{"value"}, locations, values); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("source", "experiment", "baseline"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Clone("source", "other"); !errors.As(err, &TableExistsError{}) {
+		t.Errorf("expected error cloning onto an existing table, got %v", err)
+	}
+	if err := db.Clone("missing", "copy"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected error cloning a missing table, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "copy")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no directory left by the failed clone, got %v", err)
+	}
+	if err := db.Clone("source", "copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clone starts with the rows written to the source before the copy, unflushed or not
+	if _, err := db.SetRows("copy", []string{"value"}, locations[:2], [][]Value{{NewInt32Value(10)}, {NewInt32Value(20)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("copy", "experiment", "branch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for name, expected := range map[string][]int32{"source": {1, 2, 3}, "copy": {10, 20, 3}} {
+		result, err := reopened.GetRows(name, []string{"value"}, locations...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, expect := range expected {
+			if val := result.Rows[i][0].AsInt32(); val != expect {
+				t.Errorf("expected value %d in row %d of %s, got %d", expect, i, name, val)
+			}
+		}
+	}
+	if experiment, _ := reopened.GetMetadata("source", "experiment"); experiment != "baseline" {
+		t.Errorf("expected source metadata unchanged by the clone, got %q", experiment)
+	}
+	if experiment, _ := reopened.GetMetadata("copy", "experiment"); experiment != "branch" {
+		t.Errorf("expected clone metadata 'branch', got %q", experiment)
+	}
+	if table, err := reopened.Table("copy"); err != nil || table.Name() != "copy" {
+		t.Errorf("expected reopened clone name copy, got %v", err)
+	}
+}
+
+func TestDatabaseClose(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("grid", NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("grid", []string{"value"}, []Location{IndexLocation(42)}, [][]Value{{NewInt32Value(9)}}); err != nil {
+		t.Fatal(err)
+	}
+	table, err := db.Table("grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("expected closing twice to be safe, got %v", err)
+	}
+
+	closedOps := map[string]error{
+		"create":   db.Create("other", NewProjectionlessIndexer(2, 2, true), NewColumnInt32("value", 0)),
+		"drop":     db.Drop("grid"),
+		"rename":   db.Rename("grid", "other"),
+		"snapshot": db.Snapshot(filepath.Join(dir, "snapshot")),
+	}
+	_, closedOps["checkpoint"] = db.Checkpoint()
+	_, closedOps["get rows"] = db.GetRows("grid", []string{"value"}, IndexLocation(0))
+	_, closedOps["set rows"] = db.SetRows("grid", []string{"value"}, []Location{IndexLocation(0)}, [][]Value{{NewInt32Value(1)}})
+	_, closedOps["table names"] = db.GetTableNames()
+	closedOps["set metadata"] = db.SetMetadata("grid", "key", "value")
+	_, closedOps["table get rows"] = table.GetRows([]string{"value"}, IndexLocation(0))
+	_, closedOps["table set rows"] = table.SetRows([]string{"value"}, []Location{IndexLocation(0)}, [][]Value{{NewInt32Value(1)}})
+	closedOps["table set value"] = table.SetValue("value", IndexLocation(0), NewInt32Value(1))
+	closedOps["table set metadata"] = table.SetMetadata("key", "value")
+	_, closedOps["table checkpoint"] = table.Checkpoint()
+	for name, err := range closedOps {
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("expected %s to fail as closed, got %v", name, err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Errorf("expected closing a closed table to be safe, got %v", err)
+	}
+
+	// closing flushes the dirty pages
+	reopened, err := OpenDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	result, err := reopened.GetRows("grid", []string{"value"}, IndexLocation(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := result.Rows[0][0].AsInt32(); val != 9 {
+		t.Errorf("expected value written before close to persist, got %d", val)
+	}
+}
+
+func TestOpenDatabaseLazy(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_lazy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orig, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"alpha", "beta", "gamma", "broken"}
+	for i, name := range names {
+		if err := orig.Create(name, NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", int32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := orig.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken", "broken"+MetadataFileExt), []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDatabase(dir); err == nil {
+		t.Errorf("expected eager open to fail on the broken table")
+	}
+
+	var openLock sync.Mutex
+	opened := map[string]int{}
+	defer func(original func(string, int) (blockDevice, error)) { openPageFile = original }(openPageFile)
+	openPageFile = func(path string, flag int) (blockDevice, error) {
+		openLock.Lock()
+		opened[filepath.Base(path)]++
+		openLock.Unlock()
+		return openOSPageFile(path, flag)
+	}
+
+	db, err := OpenDatabaseLazy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := db.GetTableNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(tables)
+	if !slices.Equal(tables, []string{"alpha", "beta", "broken", "gamma"}) {
+		t.Errorf("expected all four tables discovered, got %v", tables)
+	}
+
+	result, err := db.GetRows("beta", []string{"value"}, IndexLocation(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := result.Rows[0][0].AsInt32(); val != 1 {
+		t.Errorf("expected default 1 in beta, got %d", val)
+	}
+	if len(opened) != 1 || opened["beta"+DataFileExt] != 1 {
+		t.Errorf("expected only the beta data file opened once, got %v", opened)
+	}
+	for _, name := range []string{"alpha", "gamma", "broken"} {
+		if db.tables[name] != nil {
+			t.Errorf("expected table %s not to be opened", name)
+		}
+	}
+
+	if _, err := db.GetRows("broken", []string{"value"}, IndexLocation(0)); err == nil {
+		t.Errorf("expected error accessing the broken table")
+	}
+	if _, err := db.GetColumns("missing"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected table not found error, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatabaseDescribe(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_describe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gridColumns := []Column{NewColumnInt32("count", 0), NewColumnFloat64("level", 1.5)}
+	if err := db.Create("grid", NewProjectionlessIndexer(12, 5, true), gridColumns...); err != nil {
+		t.Fatal(err)
+	}
+	sphereColumns := []Column{NewColumnUint16("class", 3).WithNoData(NewUint16Value(0))}
+	if err := db.Create("sphere", NewFlatHealpixIndexer(2, healpix.RingScheme), sphereColumns...); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("sphere", "source", "survey"); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptions, err := db.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 2 {
+		t.Fatalf("expected 2 descriptions, got %d", len(descriptions))
+	}
+	expect := []struct {
+		name    string
+		indexer string
+		pixels  int
+		columns []Column
+	}{
+		{"grid", "projectionless", 60, gridColumns},
+		{"sphere", "flat-healpix", 192, sphereColumns},
+	}
+	for i, e := range expect {
+		desc := descriptions[i]
+		if desc.Name != e.name || desc.Indexer != e.indexer || desc.Pixels != e.pixels {
+			t.Errorf("expected %s with indexer %s and %d pixels, got %s with indexer %s and %d pixels",
+				e.name, e.indexer, e.pixels, desc.Name, desc.Indexer, desc.Pixels)
+		}
+		if !reflect.DeepEqual(desc.Columns, e.columns) {
+			t.Errorf("expected columns %v for %s, got %v", e.columns, e.name, desc.Columns)
+		}
+		if desc.Metadata[ProjectionKey] != e.indexer {
+			t.Errorf("expected projection metadata %s for %s, got %s", e.indexer, e.name, desc.Metadata[ProjectionKey])
+		}
+	}
+	if source := descriptions[1].Metadata["source"]; source != "survey" {
+		t.Errorf("expected source metadata survey, got '%s'", source)
+	}
+
+	// the description is a copy that does not alias the table
+	descriptions[1].Metadata["source"] = "changed"
+	if source, _ := db.GetMetadata("sphere", "source"); source != "survey" {
+		t.Errorf("expected table metadata unchanged by editing the description, got '%s'", source)
+	}
+}
+
+func TestDatabaseCreateExisting(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_create_existing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Create("terrain", NewProjectionlessIndexer(4, 4, true), NewColumnInt32("height", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("terrain", []string{"height"}, []Location{IndexLocation(3)}, [][]Value{{NewInt32Value(812)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Create("terrain", NewProjectionlessIndexer(8, 8, true), NewColumnFloat64("height", 0))
+	var existsErr TableExistsError
+	if !errors.As(err, &existsErr) || existsErr.Table != "terrain" {
+		t.Errorf("expected table exists error for terrain, got %v", err)
+	}
+	if err := db.CreateIfNotExists("terrain", NewProjectionlessIndexer(8, 8, true), NewColumnFloat64("height", 0)); err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.GetRows("terrain", []string{"height"}, IndexLocation(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := result.Rows[0][0].AsInt32(); val != 812 {
+		t.Errorf("expected existing height 812 left untouched, got %d", val)
+	}
+	if columns, _ := db.GetColumns("terrain"); columns[0].Type != ColumnTypeInt32 {
+		t.Errorf("expected existing int32 column left untouched, got %v", columns[0].Type)
+	}
+
+	// a table already on disk but unknown to this database is not clobbered either
+	other, err := OpenDatabaseLazy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := other.Create("coast", NewProjectionlessIndexer(2, 2, true), NewColumnInt8("land", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("coast", NewProjectionlessIndexer(2, 2, true), NewColumnInt8("land", 0)); !errors.As(err, &existsErr) {
+		t.Errorf("expected table exists error for table on disk, got %v", err)
+	}
+
+	if err := db.CreateIfNotExists("lakes", NewProjectionlessIndexer(2, 2, true), NewColumnInt8("depth", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetColumns("lakes"); err != nil {
+		t.Errorf("expected lakes created, got %v", err)
+	}
+}
+
+func TestDatabaseDrop(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Create("rivers", NewProjectionlessIndexer(4, 4, true), NewColumnInt32("flow", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetRows("rivers", []string{"flow"}, []Location{IndexLocation(2)}, [][]Value{{NewInt32Value(40)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Drop("missing"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected table not found error, got %v", err)
+	}
+
+	defer func(original func(string) error) { removeAll = original }(removeAll)
+	removeAll = func(path string) error {
+		return errors.New("injected removal failure")
+	}
+	if err := db.Drop("rivers"); err == nil {
+		t.Errorf("expected error from failed removal")
+	}
+	result, err := db.GetRows("rivers", []string{"flow"}, IndexLocation(2))
+	if err != nil {
+		t.Fatalf("expected table usable after failed drop, got %v", err)
+	}
+	if val := result.Rows[0][0].AsInt32(); val != 40 {
+		t.Errorf("expected unflushed flow 40 kept after failed drop, got %d", val)
+	}
+
+	removeAll = os.RemoveAll
+	if err := db.Drop("rivers"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rivers")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected table directory removed, got %v", err)
+	}
+	if _, err := db.GetColumns("rivers"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected dropped table to be gone, got %v", err)
+	}
+}
+
+func TestDatabaseManagedTableDrop(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_managed_drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"lakes", "rivers"} {
+		if err := db.Create(name, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("depth", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lakes, err := db.Table("lakes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lakes.Drop(); !errors.Is(err, ErrManagedTable) {
+		t.Errorf("expected dropping a managed table directly to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lakes")); err != nil {
+		t.Errorf("expected table files kept after a refused drop, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// tables opened lazily are managed too
+	lazy, err := OpenDatabaseLazy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lazy.Close()
+	rivers, err := lazy.Table("rivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rivers.Drop(); !errors.Is(err, ErrManagedTable) {
+		t.Errorf("expected dropping a lazily opened managed table directly to fail, got %v", err)
+	}
+	if err := lazy.Drop("rivers"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lazy.Table("rivers"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected the dropped table to be gone from the database, got %v", err)
+	}
+	if err := lazy.Create("rivers", NewProjectionlessIndexer(2, 2, true), NewColumnInt32("flow", 0)); err != nil {
+		t.Errorf("expected the name of a dropped table to be reusable, got %v", err)
+	}
+
+	// tables opened on their own can still be dropped directly
+	standalone, err := OpenTable(filepath.Join(dir, "lakes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := standalone.Drop(); err != nil {
+		t.Errorf("expected dropping an unmanaged table to succeed, got %v", err)
+	}
+}
+
+func TestDatabaseTable(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Create("grid", NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := db.Table("grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SetValue("value", GridLocation{3, 4}, NewInt32Value(17)); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SetMetadata("units", "m"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.GetRows("grid", []string{"value"}, GridLocation{3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows[0][0].AsInt32() != 17 {
+		t.Errorf("expected the write through the table handle to be seen by the database, got %d", result.Rows[0][0].AsInt32())
+	}
+	if units, err := db.GetMetadata("grid", "units"); err != nil || units != "m" {
+		t.Errorf("expected metadata set through the table handle, got %q, %v", units, err)
+	}
+	if again, err := db.Table("grid"); err != nil || again != table {
+		t.Errorf("expected the same managed handle each time, got %p and %p, %v", table, again, err)
+	}
+
+	if _, err := db.Table("missing"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected a TableNotFoundError, got %v", err)
+	}
+}
+
+func TestDatabaseConfig(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "configured")
+	db, err := NewDatabaseConfig(dbPath, DatabaseConfig{PageSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("small", NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	small, err := db.Table("small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.store.PageSize != 1024 {
+		t.Errorf("expected the configured page size 1024 for a new table, got %d", small.store.PageSize)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings are kept across opens, even by NewDatabase on the same directory
+	for _, open := range []func(string) (*Database, error){OpenDatabase, OpenDatabaseLazy, NewDatabase} {
+		reopened, err := open(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config := reopened.Config(); config.PageSize != 1024 || config.FormatVersion != DatabaseFormatVersion {
+			t.Errorf("expected the saved settings after reopening, got %+v", config)
+		}
+		if err := reopened.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// databases written before settings were kept still open with the defaults
+	if err := os.Remove(filepath.Join(dbPath, DatabaseFileName)); err != nil {
+		t.Fatal(err)
+	}
+	// hidden and leftover temporary files beside the tables do not matter
+	for _, name := range []string{".DS_Store", DatabaseFileName + ".tmp"} {
+		if err := os.WriteFile(filepath.Join(dbPath, name), []byte("stray"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy, err := OpenDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("expected a database without settings to open, got %v", err)
+	}
+	if config := legacy.Config(); config.PageSize != 0 {
+		t.Errorf("expected the default page size for a database without settings, got %d", config.PageSize)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeDatabaseConfig(dbPath, DatabaseConfig{FormatVersion: DatabaseFormatVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	var versionErr DatabaseVersionError
+	if _, err := OpenDatabase(dbPath); !errors.As(err, &versionErr) || versionErr.Version != DatabaseFormatVersion+1 {
+		t.Errorf("expected a database version error, got %v", err)
+	}
+}
+
+func TestOpenNotDatabase(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_not_database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := filepath.Join(dir, "files")
+	if err := os.MkdirAll(filepath.Join(files, "photos"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	holiday := filepath.Join(dir, "holiday")
+	if err := os.MkdirAll(holiday, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(holiday, "notes.txt"), []byte("not a table"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{files, holiday} {
+		for _, open := range []func(string) (*Database, error){OpenDatabase, OpenDatabaseLazy} {
+			var notDb NotDatabaseError
+			if _, err := open(path); !errors.As(err, &notDb) || notDb.Path != path {
+				t.Errorf("expected opening %s to fail as not a database, got %v", path, err)
+			}
+		}
+	}
+}
+
+func TestDatabaseGetAllMetadata(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_all_metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Create("grid", NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMetadata("grid", "units", "m"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := db.GetAllMetadata("grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all["units"] != "m" || all[ProjectionKey] != "projectionless" || all[CreatedAt] == "" {
+		t.Errorf("expected units along with the built-in metadata, got %v", all)
+	}
+	if _, err := db.GetAllMetadata("missing"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected a table not found error, got %v", err)
+	}
+}
+
+func TestDatabaseDeleteMetadata(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_database_delete_metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("grid", NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"units", "source"} {
+		if err := db.SetMetadata("grid", key, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.DeleteMetadata("grid", "units"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteMetadata("grid", CreatedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteMetadata("grid", "never-set"); err != nil {
+		t.Errorf("expected deleting a missing key to do nothing, got %v", err)
+	}
+	if err := db.DeleteMetadata("missing", "units"); !errors.As(err, &TableNotFoundError{}) {
+		t.Errorf("expected a table not found error, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	all, err := reopened.GetAllMetadata("grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(all, map[string]string{ProjectionKey: "projectionless", "source": "value"}) {
+		t.Errorf("expected the deleted keys gone after reopening, got %v", all)
+	}
+}
@@ -0,0 +1,40 @@
+package pixidb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Reports the bytes available for new files on the filesystem holding the path, replaceable so
+// that tests can simulate a full disk.
+var availableDiskSpace = freeDiskSpace
+
+// Check that the filesystem that will hold the path has room for the needed number of bytes,
+// without creating anything. The path need not exist yet. Does nothing on platforms where the
+// free space cannot be determined.
+func checkDiskSpace(path string, needed int64) error {
+	dir := path
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	available, err := availableDiskSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if uint64(needed) > available {
+		return NewInsufficientSpaceError(path, needed, available)
+	}
+	return nil
+}
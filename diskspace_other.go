@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package pixidb
+
+import "errors"
+
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
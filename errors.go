@@ -6,7 +6,17 @@ import (
 )
 
 var (
-	ErrZeroColumns = errors.New("cannot create a table with zero columns")
+	ErrZeroColumns            = errors.New("cannot create a table with zero columns")
+	ErrSnapshotNotEmpty       = errors.New("snapshot destination directory is not empty")
+	ErrClosed                 = errors.New("use of closed database, table, or store")
+	ErrInvalidBins            = errors.New("histogram needs at least one bin and a range with min below max")
+	ErrMetadataNotFound       = errors.New("metadata key not found")
+	ErrPageTooSmall           = errors.New("page size is too small to hold a row")
+	ErrReadOnly               = errors.New("cannot write to a read-only table or store")
+	ErrManagedTable           = errors.New("table belongs to a database, drop it with Database.Drop")
+	ErrCompressedCorrupted    = errors.New("compressed data is corrupted")
+	ErrInvalidLayout          = errors.New("unknown store layout")
+	ErrRowChecksumsCompressed = errors.New("row checksums cannot be combined with compressed columns")
 )
 
 type TableNotFoundError struct {
@@ -23,6 +33,20 @@ func (t TableNotFoundError) Error() string {
 	return fmt.Sprintf("table '%s' not found in database", t.Table)
 }
 
+type TableExistsError struct {
+	Table string
+}
+
+func NewTableExistsError(tableName string) TableExistsError {
+	return TableExistsError{
+		Table: tableName,
+	}
+}
+
+func (t TableExistsError) Error() string {
+	return fmt.Sprintf("table '%s' already exists in database", t.Table)
+}
+
 type ColumnNotFoundError struct {
 	Store  string
 	Column string
@@ -39,6 +63,34 @@ func (c ColumnNotFoundError) Error() string {
 	return fmt.Sprintf("column '%s' not found in store '%s'", c.Column, c.Store)
 }
 
+type DuplicateColumnError struct {
+	Column string
+}
+
+func NewDuplicateColumnError(column string) DuplicateColumnError {
+	return DuplicateColumnError{
+		Column: column,
+	}
+}
+
+func (d DuplicateColumnError) Error() string {
+	return fmt.Sprintf("column name '%s' is used by more than one column", d.Column)
+}
+
+type InvalidColumnNameError struct {
+	Column string
+}
+
+func NewInvalidColumnNameError(column string) InvalidColumnNameError {
+	return InvalidColumnNameError{
+		Column: column,
+	}
+}
+
+func (i InvalidColumnNameError) Error() string {
+	return fmt.Sprintf("column name '%s' must start with a letter or underscore and contain only letters, digits, underscores, hyphens and periods", i.Column)
+}
+
 type LocationNotSupportedError struct {
 	Projection string
 	Location   Location
@@ -66,3 +118,398 @@ func NewLocationOutOfBoundsError(location Location) LocationOutOfBoundsError {
 func (l LocationOutOfBoundsError) Error() string {
 	return fmt.Sprintf("location %v was out of bounds", l.Location)
 }
+
+type IndexOutOfRangeError struct {
+	Store string
+	Index int
+	Rows  int
+}
+
+func NewIndexOutOfRangeError(store string, index int, rows int) IndexOutOfRangeError {
+	return IndexOutOfRangeError{
+		Store: store,
+		Index: index,
+		Rows:  rows,
+	}
+}
+
+func (i IndexOutOfRangeError) Error() string {
+	return fmt.Sprintf("row index %d out of range [0, %d) in store '%s'", i.Index, i.Rows, i.Store)
+}
+
+type ValueSizeError struct {
+	Column   string
+	Size     int
+	Expected int
+}
+
+func NewValueSizeError(column string, size int, expected int) ValueSizeError {
+	return ValueSizeError{
+		Column:   column,
+		Size:     size,
+		Expected: expected,
+	}
+}
+
+func (v ValueSizeError) Error() string {
+	return fmt.Sprintf("value of %d bytes for column '%s', expected %d", v.Size, v.Column, v.Expected)
+}
+
+type ValueTypeError struct {
+	Expected string
+	Actual   string
+}
+
+func NewValueTypeError(expected string, actual string) ValueTypeError {
+	return ValueTypeError{
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+func (v ValueTypeError) Error() string {
+	return fmt.Sprintf("value of Go type %s given where %s was expected", v.Actual, v.Expected)
+}
+
+type ValueCountError struct {
+	Values  int
+	Columns int
+}
+
+func NewValueCountError(values int, columns int) ValueCountError {
+	return ValueCountError{
+		Values:  values,
+		Columns: columns,
+	}
+}
+
+func (v ValueCountError) Error() string {
+	return fmt.Sprintf("%d values given for %d columns", v.Values, v.Columns)
+}
+
+type LocationCountError struct {
+	Locations int
+	Values    int
+}
+
+func NewLocationCountError(locations int, values int) LocationCountError {
+	return LocationCountError{
+		Locations: locations,
+		Values:    values,
+	}
+}
+
+func (l LocationCountError) Error() string {
+	return fmt.Sprintf("%d locations given for %d rows of values", l.Locations, l.Values)
+}
+
+type BatchRowError struct {
+	Row int // the position of the row among those of the batch
+	Err error
+}
+
+func NewBatchRowError(row int, err error) BatchRowError {
+	return BatchRowError{
+		Row: row,
+		Err: err,
+	}
+}
+
+func (b BatchRowError) Error() string {
+	return fmt.Sprintf("row %d of the batch: %v", b.Row, b.Err)
+}
+
+func (b BatchRowError) Unwrap() error {
+	return b.Err
+}
+
+type FormatVersionError struct {
+	Store     string
+	Version   int
+	Supported int
+}
+
+func NewFormatVersionError(store string, version int, supported int) FormatVersionError {
+	return FormatVersionError{
+		Store:     store,
+		Version:   version,
+		Supported: supported,
+	}
+}
+
+func (f FormatVersionError) Error() string {
+	return fmt.Sprintf("store '%s' has format version %d, but only version %d is supported", f.Store, f.Version, f.Supported)
+}
+
+type NotDatabaseError struct {
+	Path   string
+	Reason string
+}
+
+func NewNotDatabaseError(path string, reason string) NotDatabaseError {
+	return NotDatabaseError{
+		Path:   path,
+		Reason: reason,
+	}
+}
+
+func (n NotDatabaseError) Error() string {
+	return fmt.Sprintf("'%s' is not a pixidb database: %s", n.Path, n.Reason)
+}
+
+type DatabaseVersionError struct {
+	Path      string
+	Version   int
+	Supported int
+}
+
+func NewDatabaseVersionError(path string, version int, supported int) DatabaseVersionError {
+	return DatabaseVersionError{
+		Path:      path,
+		Version:   version,
+		Supported: supported,
+	}
+}
+
+func (d DatabaseVersionError) Error() string {
+	return fmt.Sprintf("database '%s' has format version %d, but only version %d is supported", d.Path, d.Version, d.Supported)
+}
+
+type InsufficientSpaceError struct {
+	Path      string
+	Needed    int64
+	Available uint64
+}
+
+func NewInsufficientSpaceError(path string, needed int64, available uint64) InsufficientSpaceError {
+	return InsufficientSpaceError{
+		Path:      path,
+		Needed:    needed,
+		Available: available,
+	}
+}
+
+func (i InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("creating '%s' needs %d bytes of disk space, but only %d are available", i.Path, i.Needed, i.Available)
+}
+
+type PageCorruptedError struct {
+	Path     string
+	Page     int
+	Stored   uint32 // the checksum kept with the page, zero if the page is cut short
+	Computed uint32 // the checksum of the data read, equal to Stored if only decompressing failed
+}
+
+func NewPageCorruptedError(path string, page int, stored uint32, computed uint32) PageCorruptedError {
+	return PageCorruptedError{
+		Path:     path,
+		Page:     page,
+		Stored:   stored,
+		Computed: computed,
+	}
+}
+
+func (p PageCorruptedError) Error() string {
+	if p.Stored != p.Computed {
+		return fmt.Sprintf("page %d of '%s' is corrupted, its checksum is %08x but its data sums to %08x", p.Page, p.Path, p.Stored, p.Computed)
+	}
+	return fmt.Sprintf("page %d of '%s' is corrupted", p.Page, p.Path)
+}
+
+type StoreLockedError struct {
+	Path string
+}
+
+func NewStoreLockedError(path string) StoreLockedError {
+	return StoreLockedError{
+		Path: path,
+	}
+}
+
+func (s StoreLockedError) Error() string {
+	return fmt.Sprintf("store '%s' is already open for writing, open it read-only or close the other handle", s.Path)
+}
+
+type RowCorruptedError struct {
+	Path string
+	Row  int
+}
+
+func NewRowCorruptedError(path string, row int) RowCorruptedError {
+	return RowCorruptedError{
+		Path: path,
+		Row:  row,
+	}
+}
+
+func (r RowCorruptedError) Error() string {
+	return fmt.Sprintf("row %d of '%s' is corrupted", r.Row, r.Path)
+}
+
+type EvictionError struct {
+	Path     string
+	Page     int
+	Attempts int
+	Err      error
+}
+
+func NewEvictionError(path string, page int, attempts int, err error) EvictionError {
+	return EvictionError{
+		Path:     path,
+		Page:     page,
+		Attempts: attempts,
+		Err:      err,
+	}
+}
+
+func (e EvictionError) Error() string {
+	return fmt.Sprintf("writing back page %d of '%s' to evict it failed %d times: %v", e.Page, e.Path, e.Attempts, e.Err)
+}
+
+func (e EvictionError) Unwrap() error {
+	return e.Err
+}
+
+type PageFileError struct {
+	Path string
+	Op   string // the operation that failed, one of read, write, init, or truncate
+	Err  error
+}
+
+func NewPageFileError(path string, op string, err error) PageFileError {
+	return PageFileError{
+		Path: path,
+		Op:   op,
+		Err:  err,
+	}
+}
+
+func (p PageFileError) Error() string {
+	return fmt.Sprintf("%s of data file '%s' failed: %v", p.Op, p.Path, p.Err)
+}
+
+func (p PageFileError) Unwrap() error {
+	return p.Err
+}
+
+type MetadataCorruptedError struct {
+	Path string
+}
+
+func NewMetadataCorruptedError(path string) MetadataCorruptedError {
+	return MetadataCorruptedError{
+		Path: path,
+	}
+}
+
+func (m MetadataCorruptedError) Error() string {
+	return fmt.Sprintf("metadata file '%s' is corrupted, its checksum does not match", m.Path)
+}
+
+type IndexerNotSupportedError struct {
+	Indexer   string
+	Operation string
+}
+
+func NewIndexerNotSupportedError(indexer string, operation string) IndexerNotSupportedError {
+	return IndexerNotSupportedError{
+		Indexer:   indexer,
+		Operation: operation,
+	}
+}
+
+func (i IndexerNotSupportedError) Error() string {
+	return fmt.Sprintf("%s not supported by indexer %s", i.Operation, i.Indexer)
+}
+
+type CSVRowError struct {
+	Row int
+	Err error
+}
+
+func NewCSVRowError(row int, err error) CSVRowError {
+	return CSVRowError{
+		Row: row,
+		Err: err,
+	}
+}
+
+func (c CSVRowError) Error() string {
+	return fmt.Sprintf("csv row %d: %v", c.Row, c.Err)
+}
+
+func (c CSVRowError) Unwrap() error {
+	return c.Err
+}
+
+type MetadataError struct {
+	Key string
+	Err error
+}
+
+func NewMetadataError(key string, err error) MetadataError {
+	return MetadataError{
+		Key: key,
+		Err: err,
+	}
+}
+
+func (m MetadataError) Error() string {
+	return fmt.Sprintf("metadata '%s': %v", m.Key, m.Err)
+}
+
+func (m MetadataError) Unwrap() error {
+	return m.Err
+}
+
+type HTTPStatusError struct {
+	URL    string
+	Status int
+}
+
+func NewHTTPStatusError(url string, status int) HTTPStatusError {
+	return HTTPStatusError{
+		URL:    url,
+		Status: status,
+	}
+}
+
+func (h HTTPStatusError) Error() string {
+	return fmt.Sprintf("request for '%s' failed with status %d", h.URL, h.Status)
+}
+
+type ScanCountError struct {
+	Expected int
+	Actual   int
+}
+
+func NewScanCountError(expected int, actual int) ScanCountError {
+	return ScanCountError{
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+func (s ScanCountError) Error() string {
+	return fmt.Sprintf("scan into %d destinations, expected %d", s.Actual, s.Expected)
+}
+
+type ScanError struct {
+	Column string
+	Err    error
+}
+
+func NewScanError(column string, err error) ScanError {
+	return ScanError{
+		Column: column,
+		Err:    err,
+	}
+}
+
+func (s ScanError) Error() string {
+	return fmt.Sprintf("scan column '%s': %v", s.Column, s.Err)
+}
+
+func (s ScanError) Unwrap() error {
+	return s.Err
+}
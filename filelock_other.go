@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package pixidb
+
+import "os"
+
+// Files are not locked on platforms without advisory locks.
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package pixidb
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Take an exclusive advisory lock on the open file without waiting, which is held until the file
+// is closed. Returns errFileLocked if another open file holds the lock, even in this process.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errFileLocked
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+//go:build linux || darwin || freebsd
+
+package pixidb
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreWriterLock(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_writer_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "locked")
+	writer, err := NewStore(path, 100, NewColumnInt32("value", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.SetRowAt(7, Row(NewInt32Value(70))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second writer is refused, whether opening or creating the store, and leaves it intact
+	var lockedErr StoreLockedError
+	if _, err := OpenStore(path); !errors.As(err, &lockedErr) || lockedErr.Path != path {
+		t.Errorf("expected a store locked error opening a second writer, got %v", err)
+	}
+	if _, err := NewStore(path, 5, NewColumnInt8("other", 0)); !errors.As(err, &lockedErr) {
+		t.Errorf("expected a store locked error creating over an open store, got %v", err)
+	}
+	if _, err := OpenTable(path); !errors.As(err, &lockedErr) {
+		t.Errorf("expected a store locked error opening a second table writer, got %v", err)
+	}
+
+	// readers are allowed alongside the writer, but cannot write
+	reader, err := OpenStoreReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	compareRow(t, reader, 7, Row(NewInt32Value(70)))
+	if err := reader.SetRowAt(8, Row(NewInt32Value(80))); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected a read-only error writing through the reader, got %v", err)
+	}
+	if _, err := reader.Checkpoint(); err != nil {
+		t.Errorf("expected checkpointing a reader to do nothing, got %v", err)
+	}
+
+	// the lock is released when the writer closes
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	next, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("expected the store to open for writing once the writer closed, got %v", err)
+	}
+	if err := next.SetRowAt(8, Row(NewInt32Value(80))); err != nil {
+		t.Fatal(err)
+	}
+	if err := next.Drop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreWriterLockFailedClose(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_writer_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the data file is opened for each flush, so every write fails while failing is set
+	failing := false
+	defer func(original func(string, int) (blockDevice, error)) { openPageFile = original }(openPageFile)
+	openPageFile = func(path string, flag int) (blockDevice, error) {
+		file, err := openOSPageFile(path, flag)
+		if err != nil || !failing {
+			return file, err
+		}
+		return &faultyDevice{blockDevice: file, failOn: 1}, nil
+	}
+
+	path := filepath.Join(dir, "locked")
+	writer, err := NewStore(path, 100, NewColumnInt32("value", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.SetRowAt(7, Row(NewInt32Value(70))); err != nil {
+		t.Fatal(err)
+	}
+
+	// the writer keeps its lock while its pages could not be flushed
+	failing = true
+	if err := writer.Close(); !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected the injected failure closing the writer, got %v", err)
+	}
+	if _, err := OpenStore(path); !errors.As(err, &StoreLockedError{}) {
+		t.Errorf("expected the store to stay locked after a failed close, got %v", err)
+	}
+
+	failing = false
+	if err := writer.Close(); err != nil {
+		t.Fatalf("expected a retried close to succeed, got %v", err)
+	}
+	next, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("expected the store to open for writing once the writer closed, got %v", err)
+	}
+	defer next.Close()
+	compareRow(t, next, 7, Row(NewInt32Value(70)))
+}
+
+func TestStoreWriterLockBeforeMigrate(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_writer_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "locked")
+	store, err := NewStore(path, 100, NewColumnInt32("value", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	metaPath := filepath.Join(path, "locked"+MetadataFileExt)
+	meta, _, err := readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta["formatVersion"] = json.RawMessage("2")
+	if err := writeRawMetadata(metaPath, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// a writer refused the lock leaves a store in an older format as it was
+	lock, err := lockDataFile(path, filepath.Join(path, "locked"+DataFileExt), os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path); !errors.As(err, &StoreLockedError{}) {
+		t.Errorf("expected a store locked error, got %v", err)
+	}
+	meta, _, err = readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(meta["formatVersion"]) != "2" {
+		t.Errorf("expected the refused writer to leave version 2, got %s", meta["formatVersion"])
+	}
+
+	lock.Close()
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.FormatVersion != StoreFormatVersion {
+		t.Errorf("expected the store upgraded to version %d, got %d", StoreFormatVersion, reopened.FormatVersion)
+	}
+}
@@ -0,0 +1,104 @@
+package pixidb
+
+import "fmt"
+
+// The comparison a FilterClause makes between the value of a column and its operand.
+type FilterOp int
+
+const (
+	FilterEq FilterOp = iota
+	FilterNe
+	FilterLt
+	FilterLe
+	FilterGt
+	FilterGe
+)
+
+func (op FilterOp) String() string {
+	switch op {
+	case FilterEq:
+		return "="
+	case FilterNe:
+		return "!="
+	case FilterLt:
+		return "<"
+	case FilterLe:
+		return "<="
+	case FilterGt:
+		return ">"
+	case FilterGe:
+		return ">="
+	}
+	return fmt.Sprintf("FilterOp(%d)", int(op))
+}
+
+// Whether the result of comparing a column value to the operand satisfies the operator.
+func (op FilterOp) holds(comparison int) bool {
+	switch op {
+	case FilterEq:
+		return comparison == 0
+	case FilterNe:
+		return comparison != 0
+	case FilterLt:
+		return comparison < 0
+	case FilterLe:
+		return comparison <= 0
+	case FilterGt:
+		return comparison > 0
+	case FilterGe:
+		return comparison >= 0
+	}
+	return false
+}
+
+// A predicate on the value of a column in a row, such as 'temperature > 30'. The value is encoded
+// in the type of the column, and compared according to that type with ColumnType.Compare.
+type FilterClause struct {
+	Column string
+	Op     FilterOp
+	Value  Value
+}
+
+// A filter clause resolved against the columns of a store, ready to be evaluated on rows.
+type rowFilter struct {
+	column ColumnProjection
+	ctype  ColumnType
+	op     FilterOp
+	value  Value
+}
+
+// Resolve the filter clauses against the columns of the store, checking that every column exists
+// and every operand matches the size of its column.
+func (s *Store) rowFilters(filters []FilterClause) ([]rowFilter, error) {
+	resolved := make([]rowFilter, len(filters))
+	for i, f := range filters {
+		proj, err := s.Projection(f.Column)
+		if err != nil {
+			return nil, err
+		}
+		column := s.FilterColumns(proj)[0]
+		if len(f.Value) != column.Size() {
+			return nil, NewValueSizeError(f.Column, len(f.Value), column.Size())
+		}
+		if f.Op < FilterEq || f.Op > FilterGe {
+			return nil, fmt.Errorf("pixidb: unknown filter operator %v on column '%s'", f.Op, f.Column)
+		}
+		resolved[i] = rowFilter{proj[0], column.Type, f.Op, f.Value}
+	}
+	return resolved, nil
+}
+
+// Whether the value of the filtered column satisfies the filter.
+func (f rowFilter) matches(val Value) bool {
+	return f.op.holds(f.ctype.Compare(val, f.value))
+}
+
+// Whether the row satisfies every one of the filters.
+func matchesAll(row Row, filters []rowFilter) bool {
+	for _, f := range filters {
+		if !f.matches(Value(row[f.column.start : f.column.start+f.column.size])) {
+			return false
+		}
+	}
+	return true
+}
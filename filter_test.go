@@ -0,0 +1,138 @@
+package pixidb
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTableQueryFilters(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_query_filters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "filtered"), NewProjectionlessIndexer(4, 4, true),
+		NewColumnFloat32("temp", 0),
+		NewColumnInt16("elevation", 0),
+		NewColumnUint8("flag", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations := make([]Location, 16)
+	for i := range locations {
+		locations[i] = IndexLocation(i)
+		temp := float32(i) * 2.5
+		if i == 7 {
+			temp = float32(math.NaN())
+		}
+		if err := tbl.SetValue("temp", IndexLocation(i), NewFloat32Value(temp)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.SetValue("elevation", IndexLocation(i), NewInt16Value(int16(100-20*i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.SetValue("flag", IndexLocation(i), NewUint8Value(uint8(i%3))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		filters []FilterClause
+		expect  []int16 // the elevations of the matching rows, which identify the rows
+	}{
+		{"no filters", nil, []int16{100, 80, 60, 40, 20, 0, -20, -40, -60, -80, -100, -120, -140, -160, -180, -200}},
+		{"greater than threshold", []FilterClause{{"temp", FilterGt, NewFloat32Value(30)}}, []int16{-160, -180, -200}},
+		{"nan never exceeds", []FilterClause{{"temp", FilterGe, NewFloat32Value(15)}}, []int16{-20, -60, -80, -100, -120, -140, -160, -180, -200}},
+		{"signed less than", []FilterClause{{"elevation", FilterLt, NewInt16Value(-150)}}, []int16{-160, -180, -200}},
+		{"equal", []FilterClause{{"flag", FilterEq, NewUint8Value(2)}}, []int16{60, 0, -60, -120, -180}},
+		{"combined", []FilterClause{
+			{"temp", FilterGt, NewFloat32Value(5)},
+			{"elevation", FilterGe, NewInt16Value(-100)},
+			{"flag", FilterNe, NewUint8Value(0)},
+		}, []int16{20, 0, -60, -100}},
+		{"none match", []FilterClause{{"elevation", FilterLe, NewInt16Value(-1000)}}, []int16{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := tbl.Query([]string{"elevation"}, tc.filters, locations...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			elevations := make([]int16, len(result.Rows))
+			for i, row := range result.Rows {
+				elevations[i] = row[0].AsInt16()
+			}
+			if !slices.Equal(elevations, tc.expect) {
+				t.Errorf("expected rows with elevations %v, got %v", tc.expect, elevations)
+			}
+		})
+	}
+
+	if _, err := tbl.Query([]string{"temp"}, []FilterClause{{"missing", FilterEq, NewUint8Value(0)}}, locations...); err == nil {
+		t.Errorf("expected error filtering on a missing column")
+	}
+	if _, err := tbl.Query([]string{"temp"}, []FilterClause{{"temp", FilterEq, NewUint8Value(0)}}, locations...); err == nil {
+		t.Errorf("expected error filtering with a value of the wrong size")
+	}
+	if _, err := tbl.Query([]string{"temp"}, []FilterClause{{"flag", FilterOp(42), NewUint8Value(0)}}, locations...); err == nil {
+		t.Errorf("expected error filtering with an unknown operator")
+	}
+}
+
+func TestTableCountWhere(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_count_where")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexer := NewCylindricalEquirectangularIndexer(0, 60, 30, false)
+	tbl, err := NewTable(filepath.Join(dir, "counted"), indexer, NewColumnInt32("other", 0), NewColumnFloat64("elevation", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elevation := func(x int, y int) float64 {
+		return float64((x*37+y*11)%200) - 50.5
+	}
+	region := []Location{}
+	for x := 0; x < 60; x++ {
+		for y := 0; y < 30; y++ {
+			if err := tbl.SetValue("elevation", GridLocation{x, y}, NewFloat64Value(elevation(x, y))); err != nil {
+				t.Fatal(err)
+			}
+			if x >= 10 && x < 40 && y < 20 {
+				region = append(region, GridLocation{x, y})
+			}
+		}
+	}
+
+	for _, threshold := range []float64{-100, -50.5, 0, 75.5, 149.5, 1000} {
+		expect := 0
+		for _, loc := range region {
+			grid := loc.(GridLocation)
+			if elevation(grid.X, grid.Y) > threshold {
+				expect++
+			}
+		}
+		count, err := tbl.CountWhere("elevation", FilterClause{Op: FilterGt, Value: NewFloat64Value(threshold)}, region...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expect {
+			t.Errorf("expected %d pixels above %v, got %d", expect, threshold, count)
+		}
+	}
+
+	if _, err := tbl.CountWhere("elevation", FilterClause{Column: "other", Op: FilterGt, Value: NewInt32Value(0)}, region...); err == nil {
+		t.Errorf("expected error counting with a predicate on a different column")
+	}
+	if _, err := tbl.CountWhere("missing", FilterClause{Op: FilterGt, Value: NewInt32Value(0)}, region...); err == nil {
+		t.Errorf("expected error counting a missing column")
+	}
+}
@@ -0,0 +1,245 @@
+package pixidb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/owlpinetech/flatsphere"
+)
+
+// Tags and field types of baseline TIFF and the GeoTIFF extensions, see
+// https://docs.ogc.org/is/19-008r4/19-008r4.html
+const (
+	tiffTagImageWidth      uint16 = 256
+	tiffTagImageLength     uint16 = 257
+	tiffTagBitsPerSample   uint16 = 258
+	tiffTagCompression     uint16 = 259
+	tiffTagPhotometric     uint16 = 262
+	tiffTagStripOffsets    uint16 = 273
+	tiffTagSamplesPerPixel uint16 = 277
+	tiffTagRowsPerStrip    uint16 = 278
+	tiffTagStripByteCounts uint16 = 279
+	tiffTagPlanarConfig    uint16 = 284
+	tiffTagSampleFormat    uint16 = 339
+	tiffTagPixelScale      uint16 = 33550
+	tiffTagTiepoint        uint16 = 33922
+	tiffTagGeoKeyDirectory uint16 = 34735
+	tiffTagGDALNoData      uint16 = 42113
+
+	tiffTypeASCII  uint16 = 2
+	tiffTypeShort  uint16 = 3
+	tiffTypeLong   uint16 = 4
+	tiffTypeDouble uint16 = 12
+
+	geoKeyModelType      uint16 = 1024
+	geoKeyRasterType     uint16 = 1025
+	geoKeyGeographicType uint16 = 2048
+	geoKeyProjectedType  uint16 = 3072
+
+	// The radius of the sphere used by the web mercator coordinate system, in meters.
+	webMercatorRadius float64 = 6378137
+)
+
+type tiffEntry struct {
+	tag   uint16
+	kind  uint16
+	count int
+	data  []byte
+}
+
+func tiffShorts(tag uint16, vals ...uint16) tiffEntry {
+	data := make([]byte, 2*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint16(data[2*i:], v)
+	}
+	return tiffEntry{tag, tiffTypeShort, len(vals), data}
+}
+
+func tiffLongs(tag uint16, vals ...uint32) tiffEntry {
+	data := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(data[4*i:], v)
+	}
+	return tiffEntry{tag, tiffTypeLong, len(vals), data}
+}
+
+func tiffDoubles(tag uint16, vals ...float64) tiffEntry {
+	data := make([]byte, 8*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return tiffEntry{tag, tiffTypeDouble, len(vals), data}
+}
+
+func tiffASCII(tag uint16, text string) tiffEntry {
+	return tiffEntry{tag, tiffTypeASCII, len(text) + 1, append([]byte(text), 0)}
+}
+
+// Write a big-endian TIFF holding a single image in a single strip, which is written by the
+// write function after the directory of tags. The strip offset and byte count are filled in.
+func writeTIFF(w *bufio.Writer, entries []tiffEntry, imageSize int, write func(*bufio.Writer) error) error {
+	entries = append(entries, tiffLongs(tiffTagStripOffsets, 0), tiffLongs(tiffTagStripByteCounts, uint32(imageSize)))
+	slices.SortFunc(entries, func(a tiffEntry, b tiffEntry) int {
+		return int(a.tag) - int(b.tag)
+	})
+
+	// values that don't fit in the entry itself follow the directory, and the image follows them
+	directorySize := 2 + 12*len(entries) + 4
+	valuesSize := 0
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			valuesSize += len(e.data) + len(e.data)%2
+		}
+	}
+	imageOffset := 8 + directorySize + valuesSize
+	if imageOffset+imageSize > math.MaxUint32 {
+		return fmt.Errorf("pixidb: image of %d bytes too large for a tiff file", imageSize)
+	}
+	for i, e := range entries {
+		if e.tag == tiffTagStripOffsets {
+			entries[i] = tiffLongs(tiffTagStripOffsets, uint32(imageOffset))
+		}
+	}
+
+	w.WriteString("MM")
+	binary.Write(w, binary.BigEndian, uint16(42))
+	binary.Write(w, binary.BigEndian, uint32(8))
+	binary.Write(w, binary.BigEndian, uint16(len(entries)))
+	valueOffset := 8 + directorySize
+	for _, e := range entries {
+		binary.Write(w, binary.BigEndian, e.tag)
+		binary.Write(w, binary.BigEndian, e.kind)
+		binary.Write(w, binary.BigEndian, uint32(e.count))
+		if len(e.data) > 4 {
+			binary.Write(w, binary.BigEndian, uint32(valueOffset))
+			valueOffset += len(e.data) + len(e.data)%2
+		} else {
+			inline := make([]byte, 4)
+			copy(inline, e.data)
+			w.Write(inline)
+		}
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			w.Write(e.data)
+			w.Write(make([]byte, len(e.data)%2))
+		}
+	}
+
+	if err := write(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Write a column of the table as a single band GeoTIFF the size of the grid of the indexer, with
+// the north edge of the grid at the top of the image. Values are written in the column type, and
+// the no-data sentinel of the column is recorded in the GDAL no-data tag. The cylindrical indexer
+// is georeferenced in geographic coordinates (EPSG:4326) and the Mercator indexer in web mercator
+// coordinates (EPSG:3857), such that the pixel centers match those of the indexer; the
+// projectionless indexer is written without georeferencing. Other indexers are not supported.
+func (t *Table) ExportGeoTIFF(path string, column string) error {
+	var grid ProjectionlessIndexer
+	var geoTags []tiffEntry
+	switch indexer := t.Indexer.(type) {
+	case CylindricalEquirectangularIndexer:
+		grid = indexer.Grid
+		frame := flatsphere.NewRectangleBounds(360, 180)
+		geoTags = geoTIFFTags(grid, frame, geoKeyGeographicType, 4326)
+	case MercatorCutoffIndexer:
+		grid = indexer.Grid
+		frame := flatsphere.Bounds{
+			XMin: indexer.frame.XMin * webMercatorRadius,
+			XMax: indexer.frame.XMax * webMercatorRadius,
+			YMin: indexer.frame.YMin * webMercatorRadius,
+			YMax: indexer.frame.YMax * webMercatorRadius,
+		}
+		geoTags = geoTIFFTags(grid, frame, geoKeyProjectedType, 3857)
+	case ProjectionlessIndexer:
+		grid = indexer
+	default:
+		return NewIndexerNotSupportedError(t.Indexer.Name(), "geotiff export")
+	}
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	columnProj, err := t.store.Projection(column)
+	if err != nil {
+		return err
+	}
+	col := t.store.FilterColumns(columnProj)[0]
+
+	sampleFormat := uint16(1)
+	switch col.Type {
+	case ColumnTypeInt8, ColumnTypeInt16, ColumnTypeInt32, ColumnTypeInt64, ColumnTypeTimestamp:
+		sampleFormat = 2
+	case ColumnTypeFloat32, ColumnTypeFloat64:
+		sampleFormat = 3
+	case ColumnTypeComplex64, ColumnTypeComplex128:
+		sampleFormat = 6
+	}
+	entries := []tiffEntry{
+		tiffLongs(tiffTagImageWidth, uint32(grid.Width)),
+		tiffLongs(tiffTagImageLength, uint32(grid.Height)),
+		tiffShorts(tiffTagBitsPerSample, uint16(col.Size()*8)),
+		tiffShorts(tiffTagCompression, 1),
+		tiffShorts(tiffTagPhotometric, 1),
+		tiffShorts(tiffTagSamplesPerPixel, 1),
+		tiffLongs(tiffTagRowsPerStrip, uint32(grid.Height)),
+		tiffShorts(tiffTagPlanarConfig, 1),
+		tiffShorts(tiffTagSampleFormat, sampleFormat),
+	}
+	entries = append(entries, geoTags...)
+	if col.NoData != nil {
+		entries = append(entries, tiffASCII(tiffTagGDALNoData, col.Type.FormatValue(col.NoData)))
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	err = writeTIFF(bufio.NewWriter(out), entries, grid.Size()*col.Size(), func(w *bufio.Writer) error {
+		for y := grid.Height - 1; y >= 0; y-- {
+			for x := 0; x < grid.Width; x++ {
+				row, err := t.store.GetRowAt(grid.indexAt(GridLocation{x, y}))
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(row.Project(columnProj)[0]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// The georeferencing tags for a grid whose corner pixel centers lie on the corners of the frame,
+// given in the units of the coordinate system identified by the key and code.
+func geoTIFFTags(grid ProjectionlessIndexer, frame flatsphere.Bounds, crsKey uint16, crsCode uint16) []tiffEntry {
+	scaleX := frame.Width() / float64(max(grid.Width-1, 1))
+	scaleY := frame.Height() / float64(max(grid.Height-1, 1))
+	modelType := uint16(2)
+	if crsKey == geoKeyProjectedType {
+		modelType = 1
+	}
+	return []tiffEntry{
+		tiffDoubles(tiffTagPixelScale, scaleX, scaleY, 0),
+		tiffDoubles(tiffTagTiepoint, 0, 0, 0, frame.XMin-scaleX/2, frame.YMax+scaleY/2, 0),
+		tiffShorts(tiffTagGeoKeyDirectory,
+			1, 1, 0, 3,
+			geoKeyModelType, 0, 1, modelType,
+			geoKeyRasterType, 0, 1, 1,
+			crsKey, 0, 1, crsCode),
+	}
+}
@@ -0,0 +1,182 @@
+package pixidb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owlpinetech/healpix"
+)
+
+// Just enough of a reader for big-endian single strip TIFF files to check the files that are written.
+type testTIFF struct {
+	tags  map[uint16][]byte
+	image []byte
+}
+
+func readTestTIFF(t *testing.T, path string) testTIFF {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:4], []byte{'M', 'M', 0, 42}) {
+		t.Fatalf("missing big-endian tiff header, got %v", data[:4])
+	}
+	typeSize := map[uint16]int{tiffTypeASCII: 1, tiffTypeShort: 2, tiffTypeLong: 4, tiffTypeDouble: 8}
+	pos := int(binary.BigEndian.Uint32(data[4:]))
+	count := int(binary.BigEndian.Uint16(data[pos:]))
+	tiff := testTIFF{tags: make(map[uint16][]byte, count)}
+	for i := 0; i < count; i++ {
+		entry := data[pos+2+12*i:]
+		size := typeSize[binary.BigEndian.Uint16(entry[2:])] * int(binary.BigEndian.Uint32(entry[4:]))
+		if size <= 4 {
+			tiff.tags[binary.BigEndian.Uint16(entry)] = entry[8 : 8+size]
+		} else {
+			offset := int(binary.BigEndian.Uint32(entry[8:]))
+			tiff.tags[binary.BigEndian.Uint16(entry)] = data[offset : offset+size]
+		}
+	}
+	offset := tiff.number(tiffTagStripOffsets, 0)
+	tiff.image = data[int(offset) : int(offset)+int(tiff.number(tiffTagStripByteCounts, 0))]
+	return tiff
+}
+
+// The numeric value of a tag at the index, according to the size of the tag values.
+func (tiff testTIFF) number(tag uint16, index int) float64 {
+	data := tiff.tags[tag]
+	switch tag {
+	case tiffTagPixelScale, tiffTagTiepoint:
+		return math.Float64frombits(binary.BigEndian.Uint64(data[8*index:]))
+	case tiffTagImageWidth, tiffTagImageLength, tiffTagRowsPerStrip, tiffTagStripOffsets, tiffTagStripByteCounts:
+		return float64(binary.BigEndian.Uint32(data[4*index:]))
+	default:
+		return float64(binary.BigEndian.Uint16(data[2*index:]))
+	}
+}
+
+func TestTableExportGeoTIFF(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_export_geotiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "export.tif")
+
+	indexer := NewCylindricalEquirectangularIndexer(0, 5, 3, true)
+	tbl, err := NewTable(filepath.Join(dir, "cylindrical"), indexer,
+		NewColumnInt16("elevation", 0).WithNoData(NewInt16Value(-32768)),
+		NewColumnFloat64("temp", 1.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 3; y++ {
+			if err := tbl.SetValue("elevation", GridLocation{x, y}, NewInt16Value(int16(100*y+x))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tbl.ExportGeoTIFF(path, "elevation"); err != nil {
+		t.Fatal(err)
+	}
+	tiff := readTestTIFF(t, path)
+	expectTags := map[uint16]float64{
+		tiffTagImageWidth:    5,
+		tiffTagImageLength:   3,
+		tiffTagBitsPerSample: 16,
+		tiffTagSampleFormat:  2,
+	}
+	for tag, expect := range expectTags {
+		if val := tiff.number(tag, 0); val != expect {
+			t.Errorf("expected tag %d to be %v, got %v", tag, expect, val)
+		}
+	}
+	// pixel centers are 90 degrees apart, with the corner pixel centers on the edges of the globe
+	for i, expect := range []float64{90, 90, 0} {
+		if scale := tiff.number(tiffTagPixelScale, i); scale != expect {
+			t.Errorf("expected pixel scale %d to be %v, got %v", i, expect, scale)
+		}
+	}
+	for i, expect := range []float64{0, 0, 0, -225, 135, 0} {
+		if tie := tiff.number(tiffTagTiepoint, i); tie != expect {
+			t.Errorf("expected tiepoint %d to be %v, got %v", i, expect, tie)
+		}
+	}
+	if crs := tiff.number(tiffTagGeoKeyDirectory, 15); crs != 4326 {
+		t.Errorf("expected geographic coordinate system 4326, got %v", crs)
+	}
+	if nodata := string(tiff.tags[tiffTagGDALNoData]); nodata != "-32768\x00" {
+		t.Errorf("expected no-data tag -32768, got %q", nodata)
+	}
+	if len(tiff.image) != 5*3*2 {
+		t.Fatalf("expected image of %d bytes, got %d", 5*3*2, len(tiff.image))
+	}
+	// the first row of the image is the northernmost row of the grid
+	for _, expect := range []struct{ x, y, imageRow int }{{0, 2, 0}, {4, 2, 0}, {1, 1, 1}, {3, 0, 2}} {
+		pixel := Value(tiff.image[2*(expect.imageRow*5+expect.x):])
+		if val := pixel.AsInt16(); val != int16(100*expect.y+expect.x) {
+			t.Errorf("expected elevation %d at %d,%d, got %d", 100*expect.y+expect.x, expect.x, expect.y, val)
+		}
+	}
+
+	if err := tbl.ExportGeoTIFF(path, "temp"); err != nil {
+		t.Fatal(err)
+	}
+	tiff = readTestTIFF(t, path)
+	if tiff.number(tiffTagBitsPerSample, 0) != 64 || tiff.number(tiffTagSampleFormat, 0) != 3 {
+		t.Errorf("expected 64 bit float samples")
+	}
+	if _, ok := tiff.tags[tiffTagGDALNoData]; ok {
+		t.Errorf("expected no no-data tag for a column without a sentinel")
+	}
+	if Value(tiff.image[8:]).AsFloat64() != 1.5 {
+		t.Errorf("expected default temp of 1.5")
+	}
+
+	mercator, err := NewTable(filepath.Join(dir, "mercator"), NewMercatorCutoffIndexer(math.Pi/4, -math.Pi/4, 9, 5, false), NewColumnUint8("flag", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mercator.ExportGeoTIFF(path, "flag"); err != nil {
+		t.Fatal(err)
+	}
+	tiff = readTestTIFF(t, path)
+	if crs := tiff.number(tiffTagGeoKeyDirectory, 15); crs != 3857 {
+		t.Errorf("expected projected coordinate system 3857, got %v", crs)
+	}
+	if scale := tiff.number(tiffTagPixelScale, 0); math.Abs(scale-2*math.Pi*webMercatorRadius/8) > 1e-6 {
+		t.Errorf("unexpected mercator pixel scale %v", scale)
+	}
+	if tiff.number(tiffTagSampleFormat, 0) != 1 || !bytes.Equal(tiff.image, bytes.Repeat([]byte{3}, 45)) {
+		t.Errorf("expected unsigned image of default flags")
+	}
+
+	plain, err := NewTable(filepath.Join(dir, "plain"), NewProjectionlessIndexer(2, 2, true), NewColumnFloat32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.ExportGeoTIFF(path, "value"); err != nil {
+		t.Fatal(err)
+	}
+	tiff = readTestTIFF(t, path)
+	if _, ok := tiff.tags[tiffTagGeoKeyDirectory]; ok {
+		t.Errorf("expected projectionless export without georeferencing")
+	}
+
+	if err := plain.ExportGeoTIFF(path, "missing"); err == nil {
+		t.Errorf("expected error exporting a missing column")
+	}
+	healpixTbl, err := NewTable(filepath.Join(dir, "healpix"), NewFlatHealpixIndexer(1, healpix.NestScheme), NewColumnFloat32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notSupported IndexerNotSupportedError
+	if err := healpixTbl.ExportGeoTIFF(path, "value"); !errors.As(err, &notSupported) {
+		t.Errorf("expected healpix export to be unsupported, got %v", err)
+	}
+}
@@ -1,6 +1,11 @@
 package pixidb
 
 import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"slices"
+
 	"github.com/owlpinetech/flatsphere"
 	"github.com/owlpinetech/healpix"
 )
@@ -9,11 +14,109 @@ import (
 // pixel indices within a store.
 type LocationIndexer interface {
 	ToIndex(Location) (int, error)
+	// Whether ToIndex accepts the type of the location, without checking its bounds.
+	Supports(Location) bool
+	// Find the location on the sphere of the center of the pixel at the given index.
+	ToLocation(int) (SphericalLocation, error)
+	// Find the index of the pixel nearest to the given location, along with the angular
+	// distance in radians from the location to the center of that pixel.
+	NearestIndex(SphericalLocation) (int, float64, error)
 	Projection() flatsphere.Projection
 	Name() string
 	Size() int
 }
 
+// How an indexer treats projected coordinates that fall outside of its grid, such as those
+// produced by floating point rounding for data sampled exactly at the edges of a projection.
+type BoundaryMode int
+
+const (
+	// Coordinates outside of the grid produce a LocationOutOfBoundsError.
+	BoundaryError BoundaryMode = iota
+	// Coordinates outside of the grid are clamped to the nearest edge of the grid.
+	BoundaryClamp
+)
+
+// Keep fractional pixel coordinates within the grid according to the boundary mode. The
+// original location is reported in the error if the coordinates are out of bounds.
+func (b BoundaryMode) clampPixel(loc Location, xPix float64, yPix float64, grid ProjectionlessIndexer) (float64, float64, error) {
+	maxX := float64(grid.Width - 1)
+	maxY := float64(grid.Height - 1)
+	if math.IsNaN(xPix) || math.IsNaN(yPix) {
+		return 0, 0, NewLocationOutOfBoundsError(loc)
+	}
+	if b == BoundaryClamp {
+		xPix = math.Max(0, math.Min(maxX, xPix))
+		yPix = math.Max(0, math.Min(maxY, yPix))
+	} else if xPix < 0 || xPix > maxX || yPix < 0 || yPix > maxY {
+		return 0, 0, NewLocationOutOfBoundsError(loc)
+	}
+	return xPix, yPix, nil
+}
+
+// Convert fractional pixel coordinates into a location on the grid according to the boundary
+// mode. The original location is reported in the error if the coordinates are out of bounds.
+func (b BoundaryMode) toGrid(loc Location, xPix float64, yPix float64, grid ProjectionlessIndexer) (GridLocation, error) {
+	xPix, yPix, err := b.clampPixel(loc, xPix, yPix, grid)
+	if err != nil {
+		return GridLocation{}, err
+	}
+	return GridLocation{int(xPix), int(yPix)}, nil
+}
+
+// Implemented by indexers that lay their pixels out on a regular grid over a planar projection,
+// where the fractional pixel coordinates of a location are meaningful for interpolation.
+type griddedIndexer interface {
+	LocationIndexer
+	// The fractional pixel coordinates of the location, kept within the grid according to the
+	// boundary mode of the indexer.
+	fractionalPixel(SphericalLocation) (float64, float64, error)
+	pixelGrid() ProjectionlessIndexer
+}
+
+// Linearly map a coordinate on the plane of a projection onto fractional pixel coordinates of
+// a grid, such that the corners of the planar frame land on the centers of the corner pixels.
+func planarToPixel(frame flatsphere.Bounds, grid ProjectionlessIndexer, x float64, y float64) (float64, float64) {
+	xPix := ((x - frame.XMin) / frame.Width()) * float64(grid.Width-1)
+	yPix := ((y - frame.YMin) / frame.Height()) * float64(grid.Height-1)
+	return xPix, yPix
+}
+
+// The inverse of planarToPixel, mapping fractional pixel coordinates back onto the plane.
+func pixelToPlanar(frame flatsphere.Bounds, grid ProjectionlessIndexer, xPix float64, yPix float64) (float64, float64) {
+	x := frame.XMin + (xPix/float64(grid.Width-1))*frame.Width()
+	y := frame.YMin + (yPix/float64(grid.Height-1))*frame.Height()
+	return x, y
+}
+
+// Find the planar coordinate of the center of the grid pixel at the given index.
+func gridPixelCenter(frame flatsphere.Bounds, grid ProjectionlessIndexer, index int) (float64, float64, error) {
+	if index < 0 || index >= grid.Size() {
+		return 0, 0, NewLocationOutOfBoundsError(IndexLocation(index))
+	}
+	pixel := grid.gridAt(index)
+	x, y := pixelToPlanar(frame, grid, float64(pixel.X), float64(pixel.Y))
+	return x, y, nil
+}
+
+// Find the grid pixel with its center nearest to the given fractional pixel coordinates, and
+// the angular distance from the location to that center.
+func nearestGridIndex(indexer LocationIndexer, grid ProjectionlessIndexer, boundary BoundaryMode, loc SphericalLocation, xPix float64, yPix float64) (int, float64, error) {
+	pixel, err := boundary.toGrid(loc, math.Round(xPix), math.Round(yPix), grid)
+	if err != nil {
+		return -1, 0, err
+	}
+	index, err := grid.ToIndex(pixel)
+	if err != nil {
+		return -1, 0, err
+	}
+	center, err := indexer.ToLocation(index)
+	if err != nil {
+		return -1, 0, err
+	}
+	return index, loc.AngularDistance(center), nil
+}
+
 // Simple indexing into a grid, no spherical projection provided by this indexer. Supports
 // either row-major or column-major storage of the data for particular access patterns.
 type ProjectionlessIndexer struct {
@@ -42,15 +145,59 @@ func (p ProjectionlessIndexer) Size() int {
 	return p.Width * p.Height
 }
 
+// The grid coordinates of the pixel at the given index, honoring the storage order.
+func (p ProjectionlessIndexer) gridAt(index int) GridLocation {
+	if p.RowMajor {
+		return GridLocation{index % p.Width, index / p.Width}
+	}
+	return GridLocation{index / p.Height, index % p.Height}
+}
+
+// The index of the pixel at the given grid coordinates, which must be within the grid.
+func (p ProjectionlessIndexer) indexAt(loc GridLocation) int {
+	if p.RowMajor {
+		return loc.Y*p.Width + loc.X
+	}
+	return loc.X*p.Height + loc.Y
+}
+
+// The grid coordinates of the pixel at the given index, honoring the storage order. The inverse
+// of ToIndex for grid locations.
+func (p ProjectionlessIndexer) ToGrid(index int) (GridLocation, error) {
+	if index < 0 || index >= p.Size() {
+		return GridLocation{}, NewLocationOutOfBoundsError(IndexLocation(index))
+	}
+	return p.gridAt(index), nil
+}
+
+// Not supported, as there is no projection relating the grid to the sphere.
+func (p ProjectionlessIndexer) ToLocation(index int) (SphericalLocation, error) {
+	return SphericalLocation{}, NewLocationNotSupportedError(p.Name(), IndexLocation(index))
+}
+
+// Not supported, as there is no projection relating the grid to the sphere.
+func (p ProjectionlessIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	return -1, 0, NewLocationNotSupportedError(p.Name(), loc)
+}
+
+func (p ProjectionlessIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, GridLocation:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p ProjectionlessIndexer) ToIndex(loc Location) (int, error) {
 	switch val := loc.(type) {
 	case IndexLocation:
 		return int(val), nil
 	case GridLocation:
-		if p.RowMajor {
-			return val.Y*p.Width + val.X, nil
+		if val.X < 0 || val.X >= p.Width || val.Y < 0 || val.Y >= p.Height {
+			return -1, NewLocationOutOfBoundsError(loc)
 		}
-		return val.X*p.Height + val.Y, nil
+		return p.indexAt(val), nil
 	default:
 		return -1, NewLocationNotSupportedError(p.Name(), loc)
 	}
@@ -60,14 +207,15 @@ func (p ProjectionlessIndexer) ToIndex(loc Location) (int, error) {
 // Mercator diverges at the poles, two cutoff parameters are provided for the northern
 // and southern latitudes. These cutoff parallels will mark the boundaries of the top
 // and bottom of the grid respectively. Supports either row-major or column-major storage
-// of the data for particular access patterns.
+// of the data for particular access patterns. Locations beyond the cutoffs or the edges of
+// the projection are treated according to the boundary mode, which defaults to erroring.
 type MercatorCutoffIndexer struct {
-	NorthCutoff  float64 `json:"northCutoff"`
-	SouthCutoff  float64 `json:"southCutoff"`
-	southProj    float64 // precomputed projected south latitude
-	latRangeProj float64 // precomputed (North - South) latitude projected range
-	Grid         ProjectionlessIndexer
-	proj         flatsphere.Mercator
+	NorthCutoff float64           `json:"northCutoff"`
+	SouthCutoff float64           `json:"southCutoff"`
+	Boundary    BoundaryMode      `json:"boundary"`
+	frame       flatsphere.Bounds // precomputed planar region between the projected cutoffs
+	Grid        ProjectionlessIndexer
+	proj        flatsphere.Mercator
 }
 
 func NewMercatorCutoffIndexer(northCutoff float64, southCutoff float64, width int, height int, rowMajor bool) MercatorCutoffIndexer {
@@ -77,16 +225,28 @@ func NewMercatorCutoffIndexer(northCutoff float64, southCutoff float64, width in
 	proj := flatsphere.NewMercator()
 	_, southY := proj.Project(southCutoff, 0)
 	_, northY := proj.Project(northCutoff, 0)
+	bounds := proj.PlanarBounds()
 	return MercatorCutoffIndexer{
-		NorthCutoff:  northCutoff,
-		SouthCutoff:  southCutoff,
-		southProj:    southY,
-		latRangeProj: northY - southY,
-		Grid:         NewProjectionlessIndexer(width, height, rowMajor),
-		proj:         flatsphere.NewMercator(),
+		NorthCutoff: northCutoff,
+		SouthCutoff: southCutoff,
+		frame:       flatsphere.Bounds{XMin: bounds.XMin, XMax: bounds.XMax, YMin: southY, YMax: northY},
+		Grid:        NewProjectionlessIndexer(width, height, rowMajor),
+		proj:        proj,
 	}
 }
 
+// Restores the precomputed projection values alongside the serialized fields.
+func (m *MercatorCutoffIndexer) UnmarshalJSON(b []byte) error {
+	type mercatorFields MercatorCutoffIndexer
+	var fields mercatorFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*m = NewMercatorCutoffIndexer(fields.NorthCutoff, fields.SouthCutoff, fields.Grid.Width, fields.Grid.Height, fields.Grid.RowMajor)
+	m.Boundary = fields.Boundary
+	return nil
+}
+
 func (m MercatorCutoffIndexer) Name() string {
 	return "mercator-cutoff"
 }
@@ -99,6 +259,65 @@ func (m MercatorCutoffIndexer) Size() int {
 	return m.Grid.Size()
 }
 
+// Project the location onto the plane, treating latitudes beyond the cutoffs according
+// to the boundary mode.
+func (m MercatorCutoffIndexer) project(loc SphericalLocation) (float64, float64, error) {
+	lat := loc.Latitude
+	if lat > m.NorthCutoff || lat < m.SouthCutoff {
+		if m.Boundary != BoundaryClamp {
+			return 0, 0, NewLocationOutOfBoundsError(loc)
+		}
+		lat = math.Max(m.SouthCutoff, math.Min(m.NorthCutoff, lat))
+	}
+	x, y := m.proj.Project(lat, normalizeLongitude(loc.Longitude))
+	return x, y, nil
+}
+
+// The grid coordinates of the pixel at the given index, see ProjectionlessIndexer.ToGrid.
+func (m MercatorCutoffIndexer) ToGrid(index int) (GridLocation, error) {
+	return m.Grid.ToGrid(index)
+}
+
+func (m MercatorCutoffIndexer) ToLocation(index int) (SphericalLocation, error) {
+	x, y, err := gridPixelCenter(m.frame, m.Grid, index)
+	if err != nil {
+		return SphericalLocation{}, err
+	}
+	lat, lon := m.proj.Inverse(x, y)
+	return SphericalLocation{lat, lon}, nil
+}
+
+func (m MercatorCutoffIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	x, y, err := m.project(loc)
+	if err != nil {
+		return -1, 0, err
+	}
+	xPix, yPix := planarToPixel(m.frame, m.Grid, x, y)
+	return nearestGridIndex(m, m.Grid, m.Boundary, loc, xPix, yPix)
+}
+
+func (m MercatorCutoffIndexer) fractionalPixel(loc SphericalLocation) (float64, float64, error) {
+	x, y, err := m.project(loc)
+	if err != nil {
+		return 0, 0, err
+	}
+	xPix, yPix := planarToPixel(m.frame, m.Grid, x, y)
+	return m.Boundary.clampPixel(loc, xPix, yPix, m.Grid)
+}
+
+func (m MercatorCutoffIndexer) pixelGrid() ProjectionlessIndexer {
+	return m.Grid
+}
+
+func (m MercatorCutoffIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, GridLocation, SphericalLocation, ProjectedLocation, RectangularLocation:
+		return true
+	default:
+		return false
+	}
+}
+
 func (m MercatorCutoffIndexer) ToIndex(loc Location) (int, error) {
 	switch val := loc.(type) {
 	case IndexLocation:
@@ -106,16 +325,18 @@ func (m MercatorCutoffIndexer) ToIndex(loc Location) (int, error) {
 	case GridLocation:
 		return m.Grid.ToIndex(loc)
 	case SphericalLocation:
-		if val.Latitude > m.NorthCutoff || val.Latitude < m.SouthCutoff {
-			return -1, NewLocationOutOfBoundsError(loc)
+		x, y, err := m.project(val)
+		if err != nil {
+			return -1, err
 		}
-		x, y := m.proj.Project(val.Latitude, val.Longitude)
 		return m.ToIndex(ProjectedLocation{x, y})
 	case ProjectedLocation:
-		bounds := m.proj.PlanarBounds()
-		xPix := ((val.X - bounds.XMin) / bounds.Width()) * float64(m.Grid.Width-1)
-		yPix := ((val.Y - m.southProj) / m.latRangeProj) * float64(m.Grid.Height-1)
-		return m.ToIndex(GridLocation{int(xPix), int(yPix)})
+		xPix, yPix := planarToPixel(m.frame, m.Grid, val.X, val.Y)
+		grid, err := m.Boundary.toGrid(loc, xPix, yPix, m.Grid)
+		if err != nil {
+			return -1, err
+		}
+		return m.ToIndex(grid)
 	case RectangularLocation:
 		return m.ToIndex(val.ToSpherical())
 	default:
@@ -127,8 +348,11 @@ func (m MercatorCutoffIndexer) ToIndex(loc Location) (int, error) {
 // 0,0 is the bottom left corner of the projection space, i.e. (XMin, YMin) => (0, 0). Supports
 // both row-major and column-major order of the grid, which changes how efficient certain
 // consecutive x- or y-accesses are, but does not change where x,y coordinates refer to.
+// Locations beyond the edges of the projection are treated according to the boundary mode,
+// which defaults to erroring.
 type CylindricalEquirectangularIndexer struct {
-	Parallel float64 `json:"parallel"`
+	Parallel float64      `json:"parallel"`
+	Boundary BoundaryMode `json:"boundary"`
 	Grid     ProjectionlessIndexer
 	proj     flatsphere.Equirectangular
 }
@@ -143,6 +367,18 @@ func NewCylindricalEquirectangularIndexer(parallel float64, width int, height in
 	}
 }
 
+// Restores the projection focused on the serialized parallel alongside the serialized fields.
+func (c *CylindricalEquirectangularIndexer) UnmarshalJSON(b []byte) error {
+	type cylindricalFields CylindricalEquirectangularIndexer
+	var fields cylindricalFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*c = NewCylindricalEquirectangularIndexer(fields.Parallel, fields.Grid.Width, fields.Grid.Height, fields.Grid.RowMajor)
+	c.Boundary = fields.Boundary
+	return nil
+}
+
 func (c CylindricalEquirectangularIndexer) Name() string {
 	return "cylindrical-equirectangular"
 }
@@ -155,6 +391,45 @@ func (c CylindricalEquirectangularIndexer) Size() int {
 	return c.Grid.Size()
 }
 
+// The grid coordinates of the pixel at the given index, see ProjectionlessIndexer.ToGrid.
+func (c CylindricalEquirectangularIndexer) ToGrid(index int) (GridLocation, error) {
+	return c.Grid.ToGrid(index)
+}
+
+func (c CylindricalEquirectangularIndexer) ToLocation(index int) (SphericalLocation, error) {
+	x, y, err := gridPixelCenter(c.proj.PlanarBounds(), c.Grid, index)
+	if err != nil {
+		return SphericalLocation{}, err
+	}
+	lat, lon := c.proj.Inverse(x, y)
+	return SphericalLocation{lat, lon}, nil
+}
+
+func (c CylindricalEquirectangularIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	x, y := c.proj.Project(loc.Latitude, normalizeLongitude(loc.Longitude))
+	xPix, yPix := planarToPixel(c.proj.PlanarBounds(), c.Grid, x, y)
+	return nearestGridIndex(c, c.Grid, c.Boundary, loc, xPix, yPix)
+}
+
+func (c CylindricalEquirectangularIndexer) fractionalPixel(loc SphericalLocation) (float64, float64, error) {
+	x, y := c.proj.Project(loc.Latitude, normalizeLongitude(loc.Longitude))
+	xPix, yPix := planarToPixel(c.proj.PlanarBounds(), c.Grid, x, y)
+	return c.Boundary.clampPixel(loc, xPix, yPix, c.Grid)
+}
+
+func (c CylindricalEquirectangularIndexer) pixelGrid() ProjectionlessIndexer {
+	return c.Grid
+}
+
+func (c CylindricalEquirectangularIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, GridLocation, SphericalLocation, ProjectedLocation, RectangularLocation:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c CylindricalEquirectangularIndexer) ToIndex(loc Location) (int, error) {
 	switch val := loc.(type) {
 	case IndexLocation:
@@ -162,13 +437,15 @@ func (c CylindricalEquirectangularIndexer) ToIndex(loc Location) (int, error) {
 	case GridLocation:
 		return c.Grid.ToIndex(loc)
 	case SphericalLocation:
-		x, y := c.proj.Project(val.Latitude, val.Longitude)
+		x, y := c.proj.Project(val.Latitude, normalizeLongitude(val.Longitude))
 		return c.ToIndex(ProjectedLocation{x, y})
 	case ProjectedLocation:
-		bounds := c.proj.PlanarBounds()
-		xPix := ((val.X - bounds.XMin) / bounds.Width()) * float64(c.Grid.Width-1)
-		yPix := ((val.Y - bounds.YMin) / bounds.Height()) * float64(c.Grid.Height-1)
-		return c.ToIndex(GridLocation{int(xPix), int(yPix)})
+		xPix, yPix := planarToPixel(c.proj.PlanarBounds(), c.Grid, val.X, val.Y)
+		grid, err := c.Boundary.toGrid(loc, xPix, yPix, c.Grid)
+		if err != nil {
+			return -1, err
+		}
+		return c.ToIndex(grid)
 	case RectangularLocation:
 		return c.ToIndex(val.ToSpherical())
 	default:
@@ -176,6 +453,200 @@ func (c CylindricalEquirectangularIndexer) ToIndex(loc Location) (int, error) {
 	}
 }
 
+// Indexing into a grid of pixels projected via a polar stereographic projection, centered on
+// either the north or the south pole. The cutoff latitude marks the parallel furthest from the
+// pole that is still contained in the grid; the circle it projects to is inscribed in the grid,
+// and locations on the far side of it are out of bounds. Useful for polar datasets, where the
+// cylindrical projections waste most of their resolution. Supports either row-major or
+// column-major storage of the data for particular access patterns.
+type StereographicIndexer struct {
+	NorthPole bool    `json:"northPole"`
+	Cutoff    float64 `json:"cutoff"`
+	radius    float64 // precomputed projected radius of the cutoff parallel
+	Grid      ProjectionlessIndexer
+	proj      flatsphere.Stereographic
+}
+
+func NewStereographicIndexer(northPole bool, cutoff float64, width int, height int, rowMajor bool) StereographicIndexer {
+	if cutoff <= -math.Pi/2 || cutoff >= math.Pi/2 {
+		panic("pixidb: stereographic cutoff must be strictly between the poles")
+	}
+	// the south polar projection is the north polar projection mirrored through the equator
+	poleCutoff := cutoff
+	if !northPole {
+		poleCutoff = -cutoff
+	}
+	proj := flatsphere.NewStereographic()
+	x, y := proj.Project(poleCutoff, 0)
+	return StereographicIndexer{
+		NorthPole: northPole,
+		Cutoff:    cutoff,
+		radius:    math.Hypot(x, y),
+		Grid:      NewProjectionlessIndexer(width, height, rowMajor),
+		proj:      proj,
+	}
+}
+
+// Restores the precomputed projection values alongside the serialized fields.
+func (s *StereographicIndexer) UnmarshalJSON(b []byte) error {
+	type stereographicFields StereographicIndexer
+	var fields stereographicFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*s = NewStereographicIndexer(fields.NorthPole, fields.Cutoff, fields.Grid.Width, fields.Grid.Height, fields.Grid.RowMajor)
+	return nil
+}
+
+func (s StereographicIndexer) Name() string {
+	return "stereographic"
+}
+
+func (s StereographicIndexer) Projection() flatsphere.Projection {
+	return s.proj
+}
+
+func (s StereographicIndexer) Size() int {
+	return s.Grid.Size()
+}
+
+// Project the location onto the plane, erroring for latitudes beyond the cutoff.
+func (s StereographicIndexer) project(loc SphericalLocation) (float64, float64, error) {
+	lat, cutoff := loc.Latitude, s.Cutoff
+	if !s.NorthPole {
+		lat, cutoff = -lat, -cutoff
+	}
+	if lat < cutoff {
+		return 0, 0, NewLocationOutOfBoundsError(loc)
+	}
+	x, y := s.proj.Project(lat, normalizeLongitude(loc.Longitude))
+	return x, y, nil
+}
+
+// The grid coordinates of the pixel at the given index, see ProjectionlessIndexer.ToGrid.
+func (s StereographicIndexer) ToGrid(index int) (GridLocation, error) {
+	return s.Grid.ToGrid(index)
+}
+
+func (s StereographicIndexer) ToLocation(index int) (SphericalLocation, error) {
+	x, y, err := gridPixelCenter(flatsphere.NewCircleBounds(s.radius), s.Grid, index)
+	if err != nil {
+		return SphericalLocation{}, err
+	}
+	lat, lon := s.proj.Inverse(x, y)
+	if !s.NorthPole {
+		lat = -lat
+	}
+	return SphericalLocation{lat, lon}, nil
+}
+
+func (s StereographicIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	x, y, err := s.project(loc)
+	if err != nil {
+		return -1, 0, err
+	}
+	xPix, yPix := planarToPixel(flatsphere.NewCircleBounds(s.radius), s.Grid, x, y)
+	return nearestGridIndex(s, s.Grid, BoundaryError, loc, xPix, yPix)
+}
+
+func (s StereographicIndexer) fractionalPixel(loc SphericalLocation) (float64, float64, error) {
+	x, y, err := s.project(loc)
+	if err != nil {
+		return 0, 0, err
+	}
+	xPix, yPix := planarToPixel(flatsphere.NewCircleBounds(s.radius), s.Grid, x, y)
+	return BoundaryError.clampPixel(loc, xPix, yPix, s.Grid)
+}
+
+func (s StereographicIndexer) pixelGrid() ProjectionlessIndexer {
+	return s.Grid
+}
+
+func (s StereographicIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, GridLocation, SphericalLocation, ProjectedLocation, RectangularLocation:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s StereographicIndexer) ToIndex(loc Location) (int, error) {
+	switch val := loc.(type) {
+	case IndexLocation:
+		return int(val), nil
+	case GridLocation:
+		return s.Grid.ToIndex(loc)
+	case SphericalLocation:
+		x, y, err := s.project(val)
+		if err != nil {
+			return -1, err
+		}
+		return s.ToIndex(ProjectedLocation{x, y})
+	case ProjectedLocation:
+		xPix, yPix := planarToPixel(flatsphere.NewCircleBounds(s.radius), s.Grid, val.X, val.Y)
+		grid, err := BoundaryError.toGrid(loc, xPix, yPix, s.Grid)
+		if err != nil {
+			return -1, err
+		}
+		return s.ToIndex(grid)
+	case RectangularLocation:
+		return s.ToIndex(val.ToSpherical())
+	default:
+		return -1, NewLocationNotSupportedError(s.Name(), loc)
+	}
+}
+
+// Convert a location into the coordinate expected by the healpix package, which works with
+// longitudes in [0, 2Pi) rather than the [-Pi, Pi] used by the other indexers.
+func healpixCoordinate(loc SphericalLocation) healpix.SphereCoordinate {
+	lon := normalizeLongitude(loc.Longitude)
+	if lon < 0 {
+		lon += 2 * math.Pi
+	}
+	return healpix.NewLatLonCoordinate(loc.Latitude, lon)
+}
+
+// The ring scheme index of the pixel with the given nested scheme index, following the standard
+// HEALPix nest2ring algorithm. The conversion in the healpix package sends some pixels of the
+// fifth base face to the ring index of a neighbor, so it cannot be used to renumber every pixel.
+func healpixNestToRing(order healpix.HealpixOrder, nest int) int {
+	nside := order.FaceSidePixels()
+	facePixels := order.FacePixels()
+	face, pix := nest/facePixels, nest%facePixels
+
+	// the bits of the index within the face interleave the x and y coordinates within the face
+	x, y := 0, 0
+	for b := 0; b < int(order); b++ {
+		x |= ((pix >> (2 * b)) & 1) << b
+		y |= ((pix >> (2*b + 1)) & 1) << b
+	}
+
+	faceRing := [12]int{2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4}
+	facePhi := [12]int{1, 3, 5, 7, 0, 2, 4, 6, 1, 3, 5, 7}
+	ring := faceRing[face]*nside - x - y - 1
+	var ringPixels, before, shift int
+	switch {
+	case ring < nside:
+		ringPixels = ring
+		before = 2 * ring * (ring - 1)
+	case ring > 3*nside:
+		ringPixels = 4*nside - ring
+		before = order.Pixels() - 2*(ringPixels+1)*ringPixels
+	default:
+		ringPixels = nside
+		before = 2*nside*(nside-1) + (ring-nside)*4*nside
+		shift = (ring - nside) & 1
+	}
+	phi := (facePhi[face]*ringPixels + x - y + 1 + shift) / 2
+	if phi > 4*nside {
+		phi -= 4 * nside
+	} else if phi < 1 {
+		phi += 4 * nside
+	}
+	return before + phi - 1
+}
+
 // Pixelizes a sphere using the HEALPix pixelisation method. This indexer promises a
 // single resolution pixelization, where every pixel has the same angular area. Provides
 // storage options of both ring and nested schemes, for making certain data-access patterns
@@ -206,6 +677,42 @@ func (h FlatHealpixIndexer) Size() int {
 	return h.Order.Pixels()
 }
 
+func (h FlatHealpixIndexer) ToLocation(index int) (SphericalLocation, error) {
+	if index < 0 || index >= h.Size() {
+		return SphericalLocation{}, NewLocationOutOfBoundsError(IndexLocation(index))
+	}
+	var coord healpix.SphereCoordinate
+	if h.Scheme == healpix.NestScheme {
+		coord = healpix.NestPixel(index).ToSphereCoordinate(h.Order)
+	} else {
+		coord = healpix.RingPixel(index).ToSphereCoordinate(h.Order)
+	}
+	return SphericalLocation{coord.Latitude(), normalizeLongitude(coord.Longitude())}, nil
+}
+
+// Finds the pixel containing the location, which for HEALPix is near enough to nearest
+// given the equal area and near-uniform shape of the pixels.
+func (h FlatHealpixIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	index, err := h.ToIndex(loc)
+	if err != nil {
+		return -1, 0, err
+	}
+	center, err := h.ToLocation(index)
+	if err != nil {
+		return -1, 0, err
+	}
+	return index, loc.AngularDistance(center), nil
+}
+
+func (h FlatHealpixIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, RingLocation, NestLocation, UniqueLocation, SphericalLocation, ProjectedLocation, RectangularLocation:
+		return true
+	default:
+		return false
+	}
+}
+
 func (h FlatHealpixIndexer) ToIndex(loc Location) (int, error) {
 	switch val := loc.(type) {
 	case IndexLocation:
@@ -217,7 +724,7 @@ func (h FlatHealpixIndexer) ToIndex(loc Location) (int, error) {
 	case UniqueLocation:
 		return healpix.UniquePixel(int(val)).PixelId(h.Order, h.Scheme), nil
 	case SphericalLocation:
-		return healpix.NewLatLonCoordinate(val.Latitude, val.Longitude).PixelId(h.Order, h.Scheme), nil
+		return healpixCoordinate(val).PixelId(h.Order, h.Scheme), nil
 	case ProjectedLocation:
 		return healpix.NewProjectionCoordinate(val.X, val.Y).PixelId(h.Order, h.Scheme), nil
 	case RectangularLocation:
@@ -227,5 +734,123 @@ func (h FlatHealpixIndexer) ToIndex(loc Location) (int, error) {
 	}
 }
 
+// Pixelizes a sphere using the HEALPix pixelisation method at varying resolutions, for adaptive
+// maps where some regions need finer detail than others. Only the pixels given at creation are
+// stored, each identified by its unique pixel id (which encodes both the order and the nested
+// pixel index), and they are laid out in storage in ascending order of unique pixel id.
+type MultiResHealpixIndexer struct {
+	Pixels []healpix.UniquePixel  `json:"pixels"`
+	orders []healpix.HealpixOrder // distinct orders of the stored pixels, finest first
+	proj   flatsphere.HEALPixStandard
+}
+
+func NewMultiResHealpixIndexer(pixels ...healpix.UniquePixel) MultiResHealpixIndexer {
+	sorted := slices.Clone(pixels)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	orders := []healpix.HealpixOrder{}
+	for _, p := range sorted {
+		order := uniquePixelOrder(p)
+		if !slices.Contains(orders, order) {
+			orders = append(orders, order)
+		}
+	}
+	slices.SortFunc(orders, func(a, b healpix.HealpixOrder) int {
+		return int(b) - int(a)
+	})
+
+	return MultiResHealpixIndexer{
+		Pixels: sorted,
+		orders: orders,
+		proj:   flatsphere.NewHEALPixStandard(),
+	}
+}
+
+// The order of the HEALPix map that a unique pixel id belongs to. Unique ids are 4^(order+1)
+// plus the nested index of the pixel, so the order is encoded in the highest set bit.
+func uniquePixelOrder(p healpix.UniquePixel) healpix.HealpixOrder {
+	return healpix.HealpixOrder((bits.Len(uint(p))-1)/2 - 1)
+}
+
+// Restores the stored orders alongside the serialized pixel mapping.
+func (h *MultiResHealpixIndexer) UnmarshalJSON(b []byte) error {
+	type multiResFields MultiResHealpixIndexer
+	var fields multiResFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*h = NewMultiResHealpixIndexer(fields.Pixels...)
+	return nil
+}
+
+func (h MultiResHealpixIndexer) Name() string {
+	return "multires-healpix"
+}
+
+func (h MultiResHealpixIndexer) Projection() flatsphere.Projection {
+	return h.proj
+}
+
+func (h MultiResHealpixIndexer) Size() int {
+	return len(h.Pixels)
+}
+
+func (h MultiResHealpixIndexer) ToLocation(index int) (SphericalLocation, error) {
+	if index < 0 || index >= h.Size() {
+		return SphericalLocation{}, NewLocationOutOfBoundsError(IndexLocation(index))
+	}
+	pixel := h.Pixels[index]
+	coord := pixel.ToSphereCoordinate(uniquePixelOrder(pixel))
+	return SphericalLocation{coord.Latitude(), normalizeLongitude(coord.Longitude())}, nil
+}
+
+// Finds the finest stored pixel containing the location, and the distance to its center.
+func (h MultiResHealpixIndexer) NearestIndex(loc SphericalLocation) (int, float64, error) {
+	index, err := h.ToIndex(loc)
+	if err != nil {
+		return -1, 0, err
+	}
+	center, err := h.ToLocation(index)
+	if err != nil {
+		return -1, 0, err
+	}
+	return index, loc.AngularDistance(center), nil
+}
+
+func (h MultiResHealpixIndexer) Supports(loc Location) bool {
+	switch loc.(type) {
+	case IndexLocation, UniqueLocation, SphericalLocation, RectangularLocation:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h MultiResHealpixIndexer) ToIndex(loc Location) (int, error) {
+	switch val := loc.(type) {
+	case IndexLocation:
+		return int(val), nil
+	case UniqueLocation:
+		if ind, ok := slices.BinarySearch(h.Pixels, healpix.UniquePixel(val)); ok {
+			return ind, nil
+		}
+		return -1, NewLocationOutOfBoundsError(loc)
+	case SphericalLocation:
+		// prefer the finest stored pixel containing the location
+		coord := healpixCoordinate(val)
+		for _, order := range h.orders {
+			if ind, ok := slices.BinarySearch(h.Pixels, coord.ToUniquePixel(order)); ok {
+				return ind, nil
+			}
+		}
+		return -1, NewLocationOutOfBoundsError(loc)
+	case RectangularLocation:
+		return h.ToIndex(val.ToSpherical())
+	default:
+		return -1, NewLocationNotSupportedError(h.Name(), loc)
+	}
+}
+
 // TODO: example of how to get sinusoidal into a grid
 // https://modis-land.gsfc.nasa.gov/MODLAND_grid.html
@@ -3,7 +3,11 @@ package pixidb
 import (
 	"errors"
 	"math"
+	"slices"
 	"testing"
+
+	"github.com/owlpinetech/flatsphere"
+	"github.com/owlpinetech/healpix"
 )
 
 func TestProjectionlessIndexerGrid(t *testing.T) {
@@ -53,6 +57,59 @@ func TestProjectionlessIndexerGrid(t *testing.T) {
 	}
 }
 
+func TestProjectionlessIndexerToGrid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rowMajor bool
+		index    int
+		expect   GridLocation
+	}{
+		{"row first", true, 0, GridLocation{0, 0}},
+		{"row top right", true, 6, GridLocation{6, 0}},
+		{"row bottom left", true, 28, GridLocation{0, 4}},
+		{"row last", true, 34, GridLocation{6, 4}},
+		{"row interior", true, 17, GridLocation{3, 2}},
+		{"column first", false, 0, GridLocation{0, 0}},
+		{"column bottom left", false, 4, GridLocation{0, 4}},
+		{"column top right", false, 30, GridLocation{6, 0}},
+		{"column last", false, 34, GridLocation{6, 4}},
+		{"column interior", false, 17, GridLocation{3, 2}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			indexer := NewProjectionlessIndexer(7, 5, tc.rowMajor)
+			grid, err := indexer.ToGrid(tc.index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if grid != tc.expect {
+				t.Errorf("expected index %d at %v, got %v", tc.index, tc.expect, grid)
+			}
+			checkInd(t, indexer, grid, tc.index)
+		})
+	}
+
+	indexers := []interface {
+		ToGrid(int) (GridLocation, error)
+	}{
+		NewProjectionlessIndexer(7, 5, true),
+		NewMercatorCutoffIndexer(85, -85, 7, 5, false),
+		NewCylindricalEquirectangularIndexer(0, 7, 5, true),
+		NewStereographicIndexer(true, 1, 7, 5, false),
+	}
+	for _, indexer := range indexers {
+		for _, index := range []int{-1, 35} {
+			if _, err := indexer.ToGrid(index); !errors.As(err, &LocationOutOfBoundsError{}) {
+				t.Errorf("%T: expected index %d to be out of bounds, got %v", indexer, index, err)
+			}
+		}
+	}
+	grid, err := NewCylindricalEquirectangularIndexer(0, 7, 5, false).ToGrid(17)
+	if err != nil || grid != (GridLocation{3, 2}) {
+		t.Errorf("expected the cylindrical grid to place index 17 at {3 2}, got %v, %v", grid, err)
+	}
+}
+
 func TestMercatorCutoffIndexer(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -106,6 +163,260 @@ func TestCylindricalEquirectangularIndexer(t *testing.T) {
 	}
 }
 
+func TestGridIndexersOutOfBounds(t *testing.T) {
+	testCases := []struct {
+		name    string
+		indexer LocationIndexer
+	}{
+		{"projectionless row", NewProjectionlessIndexer(10, 20, true)},
+		{"projectionless column", NewProjectionlessIndexer(10, 20, false)},
+		{"mercator", NewMercatorCutoffIndexer(math.Pi/4, -math.Pi/4, 10, 20, true)},
+		{"cylindrical", NewCylindricalEquirectangularIndexer(0, 10, 20, false)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkOutOfBounds(t, tc.indexer, GridLocation{-1, 0})
+			checkOutOfBounds(t, tc.indexer, GridLocation{0, -1})
+			checkOutOfBounds(t, tc.indexer, GridLocation{10, 0})
+			checkOutOfBounds(t, tc.indexer, GridLocation{0, 20})
+			checkOutOfBounds(t, tc.indexer, GridLocation{10, 20})
+			checkOutOfBounds(t, tc.indexer, GridLocation{-5, 25})
+			checkInd(t, tc.indexer, GridLocation{0, 0}, 0)
+			checkInd(t, tc.indexer, GridLocation{9, 19}, tc.indexer.Size()-1)
+		})
+	}
+}
+
+func TestIndexerBoundaryModes(t *testing.T) {
+	width, height := 100, 50
+	cutoff := 80 * math.Pi / 180
+	mercError := NewMercatorCutoffIndexer(cutoff, -cutoff, width, height, true)
+	mercClamp := NewMercatorCutoffIndexer(cutoff, -cutoff, width, height, true)
+	mercClamp.Boundary = BoundaryClamp
+	cylError := NewCylindricalEquirectangularIndexer(0, width, height, true)
+	cylClamp := NewCylindricalEquirectangularIndexer(0, width, height, true)
+	cylClamp.Boundary = BoundaryClamp
+
+	nudge := 1e-9
+	_, mercNorthY := flatsphere.NewMercator().Project(cutoff, 0)
+
+	for _, indexer := range []LocationIndexer{mercError, mercClamp, cylError, cylClamp} {
+		// exactly on the antimeridian is always fine, regardless of mode
+		checkInd(t, indexer, SphericalLocation{0, -math.Pi}, width*((height-1)/2))
+		checkInd(t, indexer, SphericalLocation{0, math.Pi}, width*((height-1)/2)+width-1)
+	}
+
+	// just past the antimeridian in projected space
+	checkOutOfBounds(t, mercError, ProjectedLocation{math.Pi + nudge, 0})
+	checkOutOfBounds(t, mercError, ProjectedLocation{-math.Pi - nudge, 0})
+	checkOutOfBounds(t, cylError, ProjectedLocation{math.Pi + nudge, 0})
+	checkOutOfBounds(t, cylError, ProjectedLocation{-math.Pi - nudge, 0})
+	checkInd(t, mercClamp, ProjectedLocation{math.Pi + nudge, 0}, width*((height-1)/2)+width-1)
+	checkInd(t, mercClamp, ProjectedLocation{-math.Pi - nudge, 0}, width*((height-1)/2))
+	checkInd(t, cylClamp, ProjectedLocation{math.Pi + nudge, 0}, width*((height-1)/2)+width-1)
+	checkInd(t, cylClamp, ProjectedLocation{-math.Pi - nudge, 0}, width*((height-1)/2))
+
+	// at and beyond the poles (or the cutoff parallels for mercator)
+	checkInd(t, cylError, SphericalLocation{math.Pi / 2, 0}, width*(height-1)+(width-1)/2)
+	checkInd(t, cylError, SphericalLocation{-math.Pi / 2, 0}, (width-1)/2)
+	checkOutOfBounds(t, cylError, SphericalLocation{math.Pi/2 + nudge, 0})
+	checkOutOfBounds(t, cylError, SphericalLocation{-math.Pi/2 - nudge, 0})
+	checkInd(t, cylClamp, SphericalLocation{math.Pi/2 + nudge, 0}, width*(height-1)+(width-1)/2)
+	checkInd(t, cylClamp, SphericalLocation{-math.Pi/2 - nudge, 0}, (width-1)/2)
+	checkOutOfBounds(t, mercError, SphericalLocation{math.Pi / 2, 0})
+	checkOutOfBounds(t, mercError, ProjectedLocation{0, mercNorthY + nudge})
+	checkInd(t, mercClamp, SphericalLocation{math.Pi / 2, 0}, width*(height-1)+(width-1)/2)
+	checkInd(t, mercClamp, SphericalLocation{-math.Pi / 2, 0}, (width-1)/2)
+	checkInd(t, mercClamp, ProjectedLocation{0, mercNorthY + nudge}, width*(height-1)+(width-1)/2)
+
+	// nothing sensible can be done with garbage input
+	checkOutOfBounds(t, cylClamp, ProjectedLocation{math.NaN(), 0})
+}
+
+func TestIndexerLongitudeWrapping(t *testing.T) {
+	width, height := 100, 50
+	cutoff := 80 * math.Pi / 180
+	equator := width * ((height - 1) / 2)
+
+	testCases := []struct {
+		name    string
+		indexer LocationIndexer
+	}{
+		{"mercator", NewMercatorCutoffIndexer(cutoff, -cutoff, width, height, true)},
+		{"cylindrical", NewCylindricalEquirectangularIndexer(0, width, height, true)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// just past the antimeridian wraps around to the western edge, and vice versa
+			checkInd(t, tc.indexer, SphericalLocation{0, math.Pi + 0.01}, equator)
+			checkInd(t, tc.indexer, SphericalLocation{0, -math.Pi - 0.01}, equator+width-2)
+			// whole turns around the globe land back on the antimeridian
+			checkInd(t, tc.indexer, SphericalLocation{0, 3 * math.Pi}, equator)
+			checkInd(t, tc.indexer, SphericalLocation{0, -3 * math.Pi}, equator)
+			// a full turn plus a quarter lands a quarter of the way east of the prime meridian
+			checkInd(t, tc.indexer, SphericalLocation{0, 2*math.Pi + math.Pi/2}, equator+int(0.75*float64(width-1)))
+		})
+	}
+}
+
+func TestStereographicIndexer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		northPole bool
+		cutoff    float64
+		width     int
+		height    int
+	}{
+		{"north 60", true, 60 * math.Pi / 180, 101, 101},
+		{"north equator", true, 0, 201, 201},
+		{"south 50", false, -50 * math.Pi / 180, 101, 101},
+		{"south rect", false, -70 * math.Pi / 180, 151, 101},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			indexer := NewStereographicIndexer(tc.northPole, tc.cutoff, tc.width, tc.height, true)
+			centerX, centerY := (tc.width-1)/2, (tc.height-1)/2
+
+			pole := math.Pi / 2
+			if !tc.northPole {
+				pole = -pole
+			}
+			checkInd(t, indexer, ProjectedLocation{0, 0}, centerY*tc.width+centerX)
+			ind, err := indexer.ToIndex(SphericalLocation{pole, 0})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(float64(ind%tc.width-centerX)) > 1 || math.Abs(float64(ind/tc.width-centerY)) > 1 {
+				t.Errorf("expected pole to be at the center of the grid %d,%d but was at %d,%d", centerX, centerY, ind%tc.width, ind/tc.width)
+			}
+
+			// beyond the cutoff, away from the pole, is out of bounds
+			if tc.northPole {
+				checkOutOfBounds(t, indexer, SphericalLocation{tc.cutoff - 0.01, 0})
+			} else {
+				checkOutOfBounds(t, indexer, SphericalLocation{tc.cutoff + 0.01, 0})
+			}
+
+			// a ring of points along the cutoff maps to a ring of cells at the edge of the grid
+			for i := 0; i < 360; i++ {
+				lon := float64(i)*math.Pi/180 - math.Pi
+				ind, err := indexer.ToIndex(SphericalLocation{tc.cutoff, lon})
+				if err != nil {
+					t.Fatal(err)
+				}
+				dx := float64(ind%tc.width-centerX) / float64(centerX)
+				dy := float64(ind/tc.width-centerY) / float64(centerY)
+				if dist := math.Hypot(dx, dy); math.Abs(dist-1) > 0.05 {
+					t.Errorf("expected longitude %f on the cutoff to be at the edge of the grid, but was %f of the way there", lon, dist)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiResHealpixIndexer(t *testing.T) {
+	order2, order4 := healpix.HealpixOrder(2), healpix.HealpixOrder(4)
+
+	// coarse pixels over the first base pixel, and fine pixels subdividing the next coarse pixel
+	pixels := []healpix.UniquePixel{}
+	for n := order4.FacePixels() - 1; n >= 0; n-- {
+		pixels = append(pixels, healpix.NestPixel(order4.FacePixels()+n).ToUniquePixel(order4))
+	}
+	for n := 0; n < order2.FacePixels(); n++ {
+		pixels = append(pixels, healpix.NestPixel(n).ToUniquePixel(order2))
+	}
+	indexer := NewMultiResHealpixIndexer(pixels...)
+
+	if indexer.Size() != order2.FacePixels()+order4.FacePixels() {
+		t.Errorf("expected %d stored pixels, got %d", order2.FacePixels()+order4.FacePixels(), indexer.Size())
+	}
+
+	// coarse pixels sort before fine pixels, and each order is in nested order
+	for n := 0; n < order2.FacePixels(); n++ {
+		checkInd(t, indexer, UniqueLocation(healpix.NestPixel(n).ToUniquePixel(order2)), n)
+	}
+	for n := 0; n < order4.FacePixels(); n++ {
+		checkInd(t, indexer, UniqueLocation(healpix.NestPixel(order4.FacePixels()+n).ToUniquePixel(order4)), order2.FacePixels()+n)
+	}
+	checkOutOfBounds(t, indexer, UniqueLocation(healpix.NestPixel(100).ToUniquePixel(order2)))
+
+	// spherical locations find whichever stored pixel contains them
+	for _, n := range []int{0, 5, order2.FacePixels() - 1} {
+		center := healpix.NestPixel(n).ToSphereCoordinate(order2)
+		checkInd(t, indexer, SphericalLocation{center.Latitude(), center.Longitude()}, n)
+	}
+	for _, n := range []int{0, 7, order4.FacePixels() - 1} {
+		center := healpix.NestPixel(order4.FacePixels() + n).ToSphereCoordinate(order4)
+		checkInd(t, indexer, SphericalLocation{center.Latitude(), center.Longitude()}, order2.FacePixels()+n)
+	}
+	uncovered := healpix.NestPixel(100).ToSphereCoordinate(order2)
+	checkOutOfBounds(t, indexer, SphericalLocation{uncovered.Latitude(), uncovered.Longitude()})
+}
+
+func TestIndexerNearestIndex(t *testing.T) {
+	order2 := healpix.HealpixOrder(2)
+	multiPixels := []healpix.UniquePixel{}
+	for n := 0; n < order2.Pixels(); n++ {
+		multiPixels = append(multiPixels, healpix.NestPixel(n).ToUniquePixel(order2))
+	}
+
+	testCases := []struct {
+		name    string
+		indexer LocationIndexer
+		indices []int
+	}{
+		{"mercator", NewMercatorCutoffIndexer(math.Pi/3, -math.Pi/3, 100, 50, true), []int{101, 2525, 1666, 1257, 4850}},
+		{"cylindrical", NewCylindricalEquirectangularIndexer(0, 100, 50, false), []int{51, 2525, 1666, 1257, 4898}},
+		{"stereographic", NewStereographicIndexer(true, 0, 51, 51, true), []int{25*51 + 25, 20*51 + 30, 10*51 + 25, 25*51 + 45}},
+		{"healpix nest", NewFlatHealpixIndexer(order2, healpix.NestScheme), []int{0, 55, 96, 129, 191}},
+		{"healpix ring", NewFlatHealpixIndexer(order2, healpix.RingScheme), []int{0, 55, 96, 129, 191}},
+		{"multires healpix", NewMultiResHealpixIndexer(multiPixels...), []int{0, 55, 96, 129, 191}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, index := range tc.indices {
+				center, err := tc.indexer.ToLocation(index)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				nearest, dist, err := tc.indexer.NearestIndex(center)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if nearest != index || dist > 1e-9 {
+					t.Errorf("expected pixel center %v to be nearest %d at no distance, got %d at %e", center, index, nearest, dist)
+				}
+
+				nudged := SphericalLocation{center.Latitude + 1e-4, center.Longitude - 1e-4}
+				nearest, dist, err = tc.indexer.NearestIndex(nudged)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if nearest != index || dist <= 0 || dist > 2e-4 {
+					t.Errorf("expected %v to be nearest %d at a small distance, got %d at %e", nudged, index, nearest, dist)
+				}
+			}
+
+			if _, err := tc.indexer.ToLocation(tc.indexer.Size()); err == nil {
+				t.Errorf("expected error for location of index beyond the indexer size")
+			}
+		})
+	}
+
+	projectionless := NewProjectionlessIndexer(10, 10, true)
+	if _, err := projectionless.ToLocation(0); err == nil {
+		t.Errorf("expected projectionless indexer to have no spherical locations")
+	}
+	if _, _, err := projectionless.NearestIndex(SphericalLocation{}); err == nil {
+		t.Errorf("expected projectionless indexer to have no nearest index")
+	}
+}
+
 func checkOutOfBounds(t *testing.T, indexer LocationIndexer, loc Location) {
 	_, err := indexer.ToIndex(loc)
 	var locErr LocationOutOfBoundsError
@@ -122,3 +433,48 @@ func checkInd(t *testing.T, indexer LocationIndexer, loc Location, expected int)
 		t.Errorf("expected index %d for x,y = %v, got %d", expected, loc, ind)
 	}
 }
+
+func TestIndexerSupports(t *testing.T) {
+	locations := map[string]Location{
+		"index":       IndexLocation(0),
+		"ring":        RingLocation(0),
+		"nest":        NestLocation(0),
+		"unique":      UniqueLocation(4),
+		"grid":        GridLocation{0, 0},
+		"spherical":   SphericalLocation{0.1, 0.1},
+		"projected":   ProjectedLocation{0, 0},
+		"rectangular": RectangularLocation{1, 0, 0},
+		"other":       "somewhere",
+	}
+	gridded := []string{"index", "grid", "spherical", "projected", "rectangular"}
+	basePixels := []healpix.UniquePixel{}
+	for n := 0; n < 12; n++ {
+		basePixels = append(basePixels, healpix.NestPixel(n).ToUniquePixel(healpix.HealpixOrder(0)))
+	}
+	testCases := []struct {
+		indexer   LocationIndexer
+		supported []string
+	}{
+		{NewProjectionlessIndexer(4, 4, true), []string{"index", "grid"}},
+		{NewMercatorCutoffIndexer(85, -85, 4, 4, true), gridded},
+		{NewCylindricalEquirectangularIndexer(0, 4, 4, true), gridded},
+		{NewStereographicIndexer(true, 0, 4, 4, true), gridded},
+		{NewFlatHealpixIndexer(1, healpix.NestScheme), []string{"index", "ring", "nest", "unique", "spherical", "projected", "rectangular"}},
+		{NewMultiResHealpixIndexer(basePixels...), []string{"index", "unique", "spherical", "rectangular"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.indexer.Name(), func(t *testing.T) {
+			for name, loc := range locations {
+				expect := slices.Contains(tc.supported, name)
+				if tc.indexer.Supports(loc) != expect {
+					t.Errorf("expected support for %s locations to be %v", name, expect)
+				}
+				// an unsupported location is exactly one that ToIndex rejects for its type
+				_, err := tc.indexer.ToIndex(loc)
+				if rejected := errors.As(err, new(*LocationNotSupportedError)); rejected == expect {
+					t.Errorf("expected ToIndex of %s locations to agree with Supports, got %v", name, err)
+				}
+			}
+		})
+	}
+}
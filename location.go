@@ -33,14 +33,43 @@ type RectangularLocation struct {
 	Z float64
 }
 
+// The location on the sphere in the direction of the point from the origin, the inverse of
+// SphericalLocation.ToRectangular. The point need not be on the unit sphere.
 func (r RectangularLocation) ToSpherical() SphericalLocation {
-	theta := math.Atan2(math.Sqrt(r.X*r.X+r.Y*r.Y), r.Z)
-	phi := math.Atan2(r.Y, r.X)
-	if phi < 0 {
-		phi += 2 * math.Pi
+	lat := math.Atan2(r.Z, math.Sqrt(r.X*r.X+r.Y*r.Y))
+	lon := math.Atan2(r.Y, r.X) // already within [-Pi, Pi], the range the indexers expect
+	return SphericalLocation{lat, lon}
+}
+
+// Wraps a longitude in radians around the globe into the range [-Pi, Pi). Longitudes already
+// within [-Pi, Pi] are returned unchanged, so both edges of the antimeridian stay distinct.
+func normalizeLongitude(lon float64) float64 {
+	if lon >= -math.Pi && lon <= math.Pi {
+		return lon
 	}
-	if phi >= 2*math.Pi {
-		phi -= 2 * math.Pi
+	lon = math.Mod(lon+math.Pi, 2*math.Pi)
+	if lon < 0 {
+		lon += 2 * math.Pi
+	}
+	return lon - math.Pi
+}
+
+// The great-circle angle in radians between two locations on the sphere, computed with the
+// haversine formula for accuracy at small distances.
+func (a SphericalLocation) AngularDistance(b SphericalLocation) float64 {
+	sinLat := math.Sin((b.Latitude - a.Latitude) / 2)
+	sinLon := math.Sin((b.Longitude - a.Longitude) / 2)
+	h := sinLat*sinLat + math.Cos(a.Latitude)*math.Cos(b.Latitude)*sinLon*sinLon
+	return 2 * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// The point on the unit sphere at the location, with +Z through the north pole and +X through
+// latitude and longitude zero.
+func (a SphericalLocation) ToRectangular() RectangularLocation {
+	cosLat := math.Cos(a.Latitude)
+	return RectangularLocation{
+		X: cosLat * math.Cos(a.Longitude),
+		Y: cosLat * math.Sin(a.Longitude),
+		Z: math.Sin(a.Latitude),
 	}
-	return SphericalLocation{theta, phi}
 }
@@ -0,0 +1,95 @@
+package pixidb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSphericalLocationAngularDistance(t *testing.T) {
+	testCases := []struct {
+		name   string
+		a      SphericalLocation
+		b      SphericalLocation
+		expect float64
+	}{
+		{"equal", SphericalLocation{0.3, -1.2}, SphericalLocation{0.3, -1.2}, 0},
+		{"antipodal equator", SphericalLocation{0, 0}, SphericalLocation{0, math.Pi}, math.Pi},
+		{"antipodal poles", SphericalLocation{math.Pi / 2, 0}, SphericalLocation{-math.Pi / 2, 0}, math.Pi},
+		{"antipodal oblique", SphericalLocation{0.5, 1}, SphericalLocation{-0.5, 1 - math.Pi}, math.Pi},
+		{"quarter equator", SphericalLocation{0, 0}, SphericalLocation{0, math.Pi / 2}, math.Pi / 2},
+		{"quarter pole", SphericalLocation{0, 2}, SphericalLocation{math.Pi / 2, 0}, math.Pi / 2},
+		{"across antimeridian", SphericalLocation{0, math.Pi - 0.1}, SphericalLocation{0, -math.Pi + 0.1}, 0.2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := tc.a.AngularDistance(tc.b); math.Abs(d-tc.expect) > 1e-9 {
+				t.Errorf("expected %f, got %f", tc.expect, d)
+			}
+			if d := tc.b.AngularDistance(tc.a); math.Abs(d-tc.expect) > 1e-9 {
+				t.Errorf("expected %f in reverse, got %f", tc.expect, d)
+			}
+		})
+	}
+}
+
+func TestSphericalLocationToRectangular(t *testing.T) {
+	testCases := []struct {
+		loc    SphericalLocation
+		expect RectangularLocation
+	}{
+		{SphericalLocation{0, 0}, RectangularLocation{1, 0, 0}},
+		{SphericalLocation{0, math.Pi / 2}, RectangularLocation{0, 1, 0}},
+		{SphericalLocation{0, -math.Pi}, RectangularLocation{-1, 0, 0}},
+		{SphericalLocation{math.Pi / 2, 1}, RectangularLocation{0, 0, 1}},
+		{SphericalLocation{-math.Pi / 2, 0}, RectangularLocation{0, 0, -1}},
+	}
+	for _, tc := range testCases {
+		r := tc.loc.ToRectangular()
+		if math.Abs(r.X-tc.expect.X) > 1e-9 || math.Abs(r.Y-tc.expect.Y) > 1e-9 || math.Abs(r.Z-tc.expect.Z) > 1e-9 {
+			t.Errorf("expected %v to be %v, got %v", tc.loc, tc.expect, r)
+		}
+	}
+}
+
+func TestRectangularLocationToSpherical(t *testing.T) {
+	testCases := []struct {
+		name   string
+		loc    RectangularLocation
+		expect SphericalLocation
+	}{
+		{"north pole", RectangularLocation{0, 0, 1}, SphericalLocation{math.Pi / 2, 0}},
+		{"south pole", RectangularLocation{0, 0, -3}, SphericalLocation{-math.Pi / 2, 0}},
+		{"prime meridian", RectangularLocation{2, 0, 0}, SphericalLocation{0, 0}},
+		{"east", RectangularLocation{0, 1, 0}, SphericalLocation{0, math.Pi / 2}},
+		{"west", RectangularLocation{0, -1, 0}, SphericalLocation{0, -math.Pi / 2}},
+		{"antimeridian", RectangularLocation{-1, 0, 0}, SphericalLocation{0, math.Pi}},
+		{"northeast", RectangularLocation{1, 1, math.Sqrt2}, SphericalLocation{math.Pi / 4, math.Pi / 4}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.loc.ToSpherical()
+			if math.Abs(s.Latitude-tc.expect.Latitude) > 1e-9 || math.Abs(s.Longitude-tc.expect.Longitude) > 1e-9 {
+				t.Errorf("expected %v, got %v", tc.expect, s)
+			}
+			if r := tc.expect.ToRectangular().ToSpherical(); r.AngularDistance(tc.expect) > 1e-9 {
+				t.Errorf("expected %v to round trip, got %v", tc.expect, r)
+			}
+		})
+	}
+
+	// an indexer finds the same pixel from either form of the location
+	indexer := NewCylindricalEquirectangularIndexer(0, 36, 18, true)
+	for _, loc := range []SphericalLocation{{0.2, -2.5}, {-1.1, 0.7}, {1.3, 3}} {
+		expect, err := indexer.ToIndex(loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := indexer.ToIndex(loc.ToRectangular())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != expect {
+			t.Errorf("expected %v as rectangular coordinates to index pixel %d, got %d", loc, expect, index)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package pixidb
+
+import "encoding/binary"
+
+// Limits of the LZ4 block format: matches are at least four bytes long and reach back at most
+// 65535 bytes, no match starts in the last twelve bytes of a block, and the last five bytes of a
+// block are always literals.
+const (
+	lz4MinMatch     = 4
+	lz4MaxOffset    = 65535
+	lz4MatchLimit   = 12
+	lz4LastLiterals = 5
+	lz4HashBits     = 12
+)
+
+// Compress the data into a single block in the LZ4 block format, which can be decompressed by any
+// LZ4 implementation given the length of the data. Matches are found greedily through a small hash
+// table, favoring speed over the size of the block. Incompressible data comes out slightly larger
+// than it went in.
+func lz4CompressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(src)/255+16)
+	var table [1 << lz4HashBits]int // positions of earlier four byte sequences, plus one
+	anchor := 0
+	for i := 0; i+lz4MatchLimit <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		hash := (seq * 2654435761) >> (32 - lz4HashBits)
+		candidate := table[hash] - 1
+		table[hash] = i + 1
+		if candidate < 0 || i-candidate > lz4MaxOffset || binary.LittleEndian.Uint32(src[candidate:]) != seq {
+			i++
+			continue
+		}
+
+		length := lz4MinMatch
+		for i+length < len(src)-lz4LastLiterals && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = lz4AppendSequence(dst, src[anchor:i], i-candidate, length)
+		i += length
+		anchor = i
+	}
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// Append a sequence of literals followed by a match at the given offset back from the end of the
+// literals. The last sequence of a block has no match, given as a zero offset.
+func lz4AppendSequence(dst []byte, literals []byte, offset int, length int) []byte {
+	token := byte(min(len(literals), 15) << 4)
+	if offset > 0 {
+		token |= byte(min(length-lz4MinMatch, 15))
+	}
+	dst = append(dst, token)
+	if len(literals) >= 15 {
+		dst = lz4AppendLength(dst, len(literals)-15)
+	}
+	dst = append(dst, literals...)
+	if offset == 0 {
+		return dst
+	}
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offset))
+	if length-lz4MinMatch >= 15 {
+		dst = lz4AppendLength(dst, length-lz4MinMatch-15)
+	}
+	return dst
+}
+
+// Append the remainder of a length that did not fit in its half of a token.
+func lz4AppendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+// Decompress a block in the LZ4 block format into the destination, which must be exactly as long
+// as the data that was compressed. Returns ErrCompressedCorrupted if the block is malformed or does
+// not decompress to exactly the length of the destination.
+func lz4DecompressBlock(src []byte, dst []byte) error {
+	si, di := 0, 0
+	readLength := func(n int) (int, bool) {
+		for {
+			if si >= len(src) {
+				return 0, false
+			}
+			b := src[si]
+			si++
+			n += int(b)
+			if b != 255 {
+				return n, true
+			}
+		}
+	}
+
+	for si < len(src) {
+		token := src[si]
+		si++
+		literals := int(token >> 4)
+		if literals == 15 {
+			var ok bool
+			if literals, ok = readLength(literals); !ok {
+				return ErrCompressedCorrupted
+			}
+		}
+		if si+literals > len(src) || di+literals > len(dst) {
+			return ErrCompressedCorrupted
+		}
+		di += copy(dst[di:], src[si:si+literals])
+		si += literals
+		if si == len(src) {
+			break
+		}
+
+		if si+2 > len(src) {
+			return ErrCompressedCorrupted
+		}
+		offset := int(binary.LittleEndian.Uint16(src[si:]))
+		si += 2
+		length := int(token & 15)
+		if length == 15 {
+			var ok bool
+			if length, ok = readLength(length); !ok {
+				return ErrCompressedCorrupted
+			}
+		}
+		length += lz4MinMatch
+		if offset == 0 || offset > di || di+length > len(dst) {
+			return ErrCompressedCorrupted
+		}
+		// matches may overlap the bytes they produce, so copy one byte at a time
+		for k := 0; k < length; k++ {
+			dst[di+k] = dst[di-offset+k]
+		}
+		di += length
+	}
+	if di != len(dst) {
+		return ErrCompressedCorrupted
+	}
+	return nil
+}
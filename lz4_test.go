@@ -0,0 +1,72 @@
+package pixidb
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestLZ4RoundTrip(t *testing.T) {
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(7)).Read(random)
+	mask := make([]byte, 4000)
+	for i := range mask {
+		mask[i] = byte(i / 50 % 2)
+	}
+
+	testCases := []struct {
+		name       string
+		data       []byte
+		compresses bool
+	}{
+		{"empty", []byte{}, false},
+		{"short", []byte("pixidb"), false},
+		{"zeros", make([]byte, 70000), true},
+		{"repeated", bytes.Repeat([]byte("abcdefgh"), 600), true},
+		{"mask", mask, true},
+		{"random", random, false},
+		{"long literals then match", append(bytes.Clone(random[:300]), bytes.Repeat([]byte{9}, 300)...), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			compressed := lz4CompressBlock(tc.data)
+			if tc.compresses && len(compressed) >= len(tc.data) {
+				t.Errorf("expected %d bytes to compress, got %d bytes", len(tc.data), len(compressed))
+			}
+			decompressed := make([]byte, len(tc.data))
+			if err := lz4DecompressBlock(compressed, decompressed); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, tc.data) {
+				t.Errorf("expected the data back after decompressing")
+			}
+		})
+	}
+}
+
+func TestLZ4DecompressCorrupted(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 100)
+	compressed := lz4CompressBlock(data)
+
+	testCases := []struct {
+		name   string
+		block  []byte
+		length int
+	}{
+		{"truncated", compressed[:len(compressed)-3], len(data)},
+		{"too short a destination", compressed, len(data) - 1},
+		{"too long a destination", compressed, len(data) + 1},
+		{"offset before start", []byte{0x10, 'a', 0x05, 0x00, 0x00}, 10},
+		{"zero offset", []byte{0x10, 'a', 0x00, 0x00, 0x00}, 10},
+		{"unterminated length", []byte{0xf0, 0xff, 0xff}, 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := lz4DecompressBlock(tc.block, make([]byte, tc.length))
+			if !errors.Is(err, ErrCompressedCorrupted) {
+				t.Errorf("expected a corrupted compression error, got %v", err)
+			}
+		})
+	}
+}
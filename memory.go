@@ -0,0 +1,69 @@
+package pixidb
+
+import (
+	"io"
+	"sync"
+)
+
+// A data file held entirely in memory, for stores that never touch the disk. Grows as pages are
+// written past its end, like a file on disk.
+type memoryPageFile struct {
+	lock sync.RWMutex
+	data []byte
+}
+
+func (m *memoryPageFile) ReadAt(p []byte, off int64) (int, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryPageFile) WriteAt(p []byte, off int64) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if end := off + int64(len(p)); end > int64(len(m.data)) {
+		m.data = append(m.data, make([]byte, end-int64(len(m.data)))...)
+	}
+	return copy(m.data[off:], p), nil
+}
+
+func (m *memoryPageFile) Sync() error {
+	return nil
+}
+
+func (m *memoryPageFile) Truncate(size int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if size < int64(len(m.data)) {
+		m.data = m.data[:size:size]
+	} else {
+		m.data = append(m.data, make([]byte, size-int64(len(m.data)))...)
+	}
+	return nil
+}
+
+func (m *memoryPageFile) Close() error {
+	return nil
+}
+
+// Let go of the data, leaving an empty file.
+func (m *memoryPageFile) release() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data = nil
+}
+
+// Create a cached data layer like NewPagemasterPageSize whose pages are kept in memory rather
+// than in a file on disk, along with the in-memory file backing it. Must call Initialize
+// afterward, as with a new file on disk.
+func newMemoryPagemaster(maxCache int, pageSize int) (*Pagemaster, *memoryPageFile) {
+	file := &memoryPageFile{}
+	return newPagemasterDevice("", file, maxCache, pageSize), file
+}
@@ -0,0 +1,151 @@
+package pixidb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+)
+
+// The first bytes of a gzip stream, which JSON text never starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Starts the line appended to the JSON text of a metadata file holding its checksum. Compact
+// JSON escapes every newline within strings, so the line can never be part of the JSON.
+const metadataChecksumPrefix = "\n#crc32 "
+
+// Decompress the contents of a metadata file if they are gzipped, reporting whether they were,
+// and verify the checksum that follows the JSON text. Metadata written before checksums were
+// added has none, and is returned as is. Returns a MetadataCorruptedError if the checksum does
+// not match the JSON text.
+func decodeMetadata(path string, raw []byte) ([]byte, bool, error) {
+	compressed := bytes.HasPrefix(raw, gzipMagic)
+	jsonText := raw
+	if compressed {
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, true, err
+		}
+		defer reader.Close()
+		if jsonText, err = io.ReadAll(reader); err != nil {
+			return nil, true, err
+		}
+	}
+
+	start := bytes.LastIndex(jsonText, []byte(metadataChecksumPrefix))
+	if start < 0 {
+		return jsonText, compressed, nil
+	}
+	trailer := bytes.TrimSuffix(jsonText[start+len(metadataChecksumPrefix):], []byte("\n"))
+	saved, err := strconv.ParseUint(string(trailer), 16, 32)
+	if err != nil || uint32(saved) != crc32.ChecksumIEEE(jsonText[:start]) {
+		return nil, compressed, NewMetadataCorruptedError(path)
+	}
+	return jsonText[:start], compressed, nil
+}
+
+// Append the checksum line to the JSON text, then gzip it all if compress is set.
+func encodeMetadata(jsonData []byte, compress bool) ([]byte, error) {
+	encoded := fmt.Appendf(bytes.Clone(jsonData), "%s%08x\n", metadataChecksumPrefix, crc32.ChecksumIEEE(jsonData))
+	if !compress {
+		return encoded, nil
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// Read the JSON text of a metadata file, which may be gzipped, and whether it was.
+func readMetadataFile(path string) ([]byte, bool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return decodeMetadata(path, raw)
+}
+
+// Write the JSON text to a metadata file with its checksum, gzipped if compress is set.
+func writeMetadataFile(path string, jsonData []byte, compress bool) error {
+	encoded, err := encodeMetadata(jsonData, compress)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, encoded)
+}
+
+// The temporary file new metadata is written to before it replaces a metadata file.
+type metadataFile interface {
+	io.WriterAt
+	Sync() error
+	Close() error
+}
+
+func openOSMetadataFile(path string, flag int) (metadataFile, error) {
+	return os.OpenFile(path, flag, 0666)
+}
+
+// Opens the temporary files of metadata writes, replaceable so that tests can inject disk
+// failures apart from those of the data files.
+var openMetadataFile = openOSMetadataFile
+
+// Replace the contents of the file by writing and syncing the data to a temporary file beside
+// it that is then renamed over the file, so a crash part way through leaves the previous
+// contents intact rather than a truncated file.
+func replaceFile(path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := writeSynced(tempPath, data); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// Replace the contents of the file with the data and sync it to disk.
+func writeSynced(path string, data []byte) error {
+	file, err := openMetadataFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Rewrite a metadata file as the bare JSON text, after verifying its checksum, so that migrations
+// can edit it as plain JSON, reporting whether it was gzipped. Missing files are left missing.
+func writeBareMetadataFile(path string) (bool, error) {
+	jsonText, compressed, err := readMetadataFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return compressed, replaceFile(path, jsonText)
+}
+
+// Rewrite a metadata file with its checksum, gzipped if compress is set, undoing
+// writeBareMetadataFile. Missing files are left missing.
+func writeSealedMetadataFile(path string, compress bool) error {
+	jsonText, _, err := readMetadataFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return writeMetadataFile(path, jsonText, compress)
+}
@@ -0,0 +1,248 @@
+package pixidb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/maps"
+)
+
+func TestTableMetadataCompression(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_metadata_compression")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "packed")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadata("hello", "there"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadataCompression(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ext := range []string{MetadataFileExt, TableFileExt} {
+		raw, err := os.ReadFile(filepath.Join(path, "packed"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(raw, gzipMagic) {
+			t.Errorf("expected %s file to be gzipped, starts with %q", ext, raw[:min(len(raw), 8)])
+		}
+	}
+
+	reopened, err := OpenTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.store.Rows != 16 {
+		t.Errorf("expected 16 rows, got %d", reopened.store.Rows)
+	}
+	if !maps.Equal(reopened.Metadata, tbl.Metadata) {
+		t.Errorf("expected metadata %v to survive compression, got %v", tbl.Metadata, reopened.Metadata)
+	}
+	if reopened.IndexerName != tbl.IndexerName {
+		t.Errorf("expected indexer %s, got %s", tbl.IndexerName, reopened.IndexerName)
+	}
+	value, err := reopened.GetValue("value", IndexLocation(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AsInt32() != 7 {
+		t.Errorf("expected default value 7, got %d", value.AsInt32())
+	}
+
+	// later saves keep the form the table was opened with
+	if err := reopened.SetMetadata("hello", "again"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(path, "packed"+TableFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, gzipMagic) {
+		t.Errorf("expected table file to stay gzipped after a save")
+	}
+}
+
+func TestTableOpenUncompressedMetadata(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_metadata_uncompressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "plain")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadata("hello", "there"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(path, "plain"+MetadataFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		t.Fatalf("expected plain JSON metadata by default, starts with %q", raw[:min(len(raw), 8)])
+	}
+
+	// metadata written before checksums were added is bare JSON
+	for _, ext := range []string{MetadataFileExt, TableFileExt} {
+		if _, err := writeBareMetadataFile(filepath.Join(path, "plain"+ext)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := OpenTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.store.compressMeta {
+		t.Errorf("expected plain metadata to open uncompressed")
+	}
+	if !maps.Equal(reopened.Metadata, tbl.Metadata) {
+		t.Errorf("expected metadata %v, got %v", tbl.Metadata, reopened.Metadata)
+	}
+}
+
+func TestOpenCorruptedMetadata(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_metadata_corrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name string
+		ext  string
+		from string
+		to   string
+	}{
+		{"rows", MetadataFileExt, `"rows":16`, `"rows":76`},
+		{"column", MetadataFileExt, `"value"`, `"valve"`},
+		{"indexer", TableFileExt, `"width":4`, `"width":8`},
+		{"checksum", MetadataFileExt, metadataChecksumPrefix, metadataChecksumPrefix + "z"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 7))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tbl.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			filePath := filepath.Join(path, tc.name+tc.ext)
+			raw, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			corrupted := bytes.Replace(raw, []byte(tc.from), []byte(tc.to), 1)
+			if bytes.Equal(corrupted, raw) {
+				t.Fatalf("expected %q in %s", tc.from, raw)
+			}
+			if err := os.WriteFile(filePath, corrupted, 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = OpenTable(path)
+			var corruptErr MetadataCorruptedError
+			if !errors.As(err, &corruptErr) || corruptErr.Path != filePath {
+				t.Errorf("expected a corrupted metadata error for %s, got %v", filePath, err)
+			}
+		})
+	}
+}
+
+// Writes only the first half of each write before failing, as a crash part way through would.
+type tornFile struct {
+	metadataFile
+}
+
+func (f tornFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.metadataFile.WriteAt(p[:len(p)/2], off)
+	if err != nil {
+		return n, err
+	}
+	return n, errInjectedWrite
+}
+
+func TestMetadataWriteInterrupted(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_metadata_interrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "torn")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	if err := tbl.SetMetadata("survey", "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(original func(string, int) (metadataFile, error)) { openMetadataFile = original }(openMetadataFile)
+	openMetadataFile = func(path string, flag int) (metadataFile, error) {
+		file, err := openOSMetadataFile(path, flag)
+		if err != nil {
+			return nil, err
+		}
+		return tornFile{file}, nil
+	}
+	if err := tbl.SetMetadata("survey", "second"); !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected the injected failure from the metadata write, got %v", err)
+	}
+	if err := tbl.SetMetadataCompression(true); !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected the injected failure from the store metadata write, got %v", err)
+	}
+	openMetadataFile = openOSMetadataFile
+	if tbl.store.compressMeta {
+		t.Errorf("expected the compression setting reverted after the failed rewrite")
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			t.Errorf("expected the temporary file of the failed write removed, found %s", entry.Name())
+		}
+	}
+
+	reopened, err := OpenTableReadOnly(path)
+	if err != nil {
+		t.Fatalf("expected the previous metadata intact after the interrupted writes, got %v", err)
+	}
+	defer reopened.Close()
+	if survey, _ := reopened.GetMetadata("survey"); survey != "first" {
+		t.Errorf("expected the previous metadata value 'first', got '%s'", survey)
+	}
+	if reopened.store.compressMeta {
+		t.Errorf("expected the store metadata to stay uncompressed after the failed rewrite")
+	}
+}
@@ -0,0 +1,50 @@
+package pixidb
+
+// Counters describing how a store has been used since it was opened, for monitoring. Each counter
+// only ever increases while the store stays open.
+type Metrics struct {
+	RowsRead     int64 // rows read, one for each value read alone and each row visited by a scan
+	RowsWritten  int64 // rows written, one for each value written alone
+	CacheHits    int64 // page accesses served from the cache
+	CacheMisses  int64 // page accesses that read the page from disk
+	Checkpoints  int64 // checkpoints written, including those of the background checkpoint
+	BytesFlushed int64 // bytes of pages written back to the data file, including their checksums
+}
+
+// A snapshot of the usage counters of the store. Counters are read individually, so a snapshot
+// taken during concurrent access may not reflect a single instant.
+func (s *Store) Metrics() Metrics {
+	stats := s.file.Stats()
+	return Metrics{
+		RowsRead:     s.rowsRead.Load(),
+		RowsWritten:  s.rowsWritten.Load(),
+		CacheHits:    stats.Hits,
+		CacheMisses:  stats.Misses,
+		Checkpoints:  s.checkpoints.Load(),
+		BytesFlushed: stats.BytesWritten,
+	}
+}
+
+// A snapshot of the usage counters of the table, see Store.Metrics.
+func (t *Table) Metrics() Metrics {
+	return t.store.Metrics()
+}
+
+// A snapshot of the usage counters of every table in the database, by table name. Tables that
+// have not been opened since the database was opened have not been used, and report zeros.
+func (d *Database) Metrics() (map[string]Metrics, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	metrics := make(map[string]Metrics, len(d.tables))
+	for name, table := range d.tables {
+		if table == nil {
+			metrics[name] = Metrics{}
+		} else {
+			metrics[name] = table.Metrics()
+		}
+	}
+	return metrics, nil
+}
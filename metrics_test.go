@@ -0,0 +1,81 @@
+package pixidb
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestTableMetrics(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Create("grid", NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	table, err := db.Table("grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := []Location{IndexLocation(0), IndexLocation(5), IndexLocation(15)}
+	values := [][]Value{{NewInt32Value(1)}, {NewInt32Value(2)}, {NewInt32Value(3)}}
+	if _, err := db.SetRows("grid", []string{"value"}, locations, values); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetRows("grid", []string{"value"}, IndexLocation(0), IndexLocation(1), IndexLocation(2), IndexLocation(3), IndexLocation(15)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := table.Metrics()
+	expect := Metrics{
+		RowsRead:     5,
+		RowsWritten:  3,
+		CacheHits:    metrics.CacheHits,
+		CacheMisses:  1,
+		Checkpoints:  1,
+		BytesFlushed: int64(table.store.file.PageSize() + ChecksumSize),
+	}
+	if metrics != expect {
+		t.Errorf("expected metrics %+v, got %+v", expect, metrics)
+	}
+	if metrics.CacheHits < 7 {
+		t.Errorf("expected a cache hit for each access after the first, got %d", metrics.CacheHits)
+	}
+	all, err := db.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all["grid"] != metrics {
+		t.Errorf("expected the database metrics to hold the table metrics, got %+v", all)
+	}
+
+	// concurrent writes are all counted
+	var wait sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wait.Add(1)
+		go func(w int) {
+			defer wait.Done()
+			for i := 0; i < 100; i++ {
+				if err := table.store.SetValueAt("value", (w+i)%16, NewInt32Value(int32(i))); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wait.Wait()
+	if written := table.Metrics().RowsWritten; written != 803 {
+		t.Errorf("expected 803 rows written, got %d", written)
+	}
+}
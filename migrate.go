@@ -0,0 +1,138 @@
+package pixidb
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+type migration struct {
+	to int
+	fn func(path string) error
+}
+
+var (
+	migrationLock sync.RWMutex
+	migrations    = map[int]migration{}
+)
+
+func init() {
+	// stores written before the format version was recorded have the layout of version 1
+	RegisterMigration(0, 1, func(path string) error { return nil })
+	// version 2 records the page size, which was the default page size of the host until then
+	RegisterMigration(1, 2, func(path string) error {
+		metaFilePath := filepath.Join(path, filepath.Base(path)+MetadataFileExt)
+		meta, _, err := readRawMetadata(metaFilePath)
+		if err != nil {
+			return err
+		}
+		meta["pageSize"] = json.RawMessage(strconv.Itoa(DefaultPageSize()))
+		return writeRawMetadata(metaFilePath, meta)
+	})
+	// version 3 adds compressed columns, the column layout and row checksums, which each change
+	// the pages of the data file; stores written before have none of them, so their pages are
+	// already those of version 3, but builds that only read version 2 must not open the newer
+	RegisterMigration(2, 3, func(path string) error { return nil })
+}
+
+// Register a function that upgrades the files of a store in the directory at the given path from
+// one format version to a later one. Opening a store older than StoreFormatVersion runs the
+// registered migrations in sequence, recording the new version in the metadata after each one.
+// Migrations see the metadata files as plain JSON, without compression or checksum, which are
+// restored once the last migration has run.
+// Registering a second migration from the same version replaces the first. Panics if the versions
+// are not in increasing order or the target is newer than StoreFormatVersion.
+func RegisterMigration(from int, to int, fn func(path string) error) {
+	if from < 0 || from >= to || to > StoreFormatVersion {
+		panic(fmt.Sprintf("pixidb: invalid migration from format version %d to %d", from, to))
+	}
+	migrationLock.Lock()
+	defer migrationLock.Unlock()
+	migrations[from] = migration{to: to, fn: fn}
+}
+
+// Run the registered migrations on the store at the given path until its metadata records at
+// least the current format version. Metadata without a version is treated as version 0.
+func migrateStore(path string, name string, metaFilePath string) error {
+	// whether each metadata file was gzipped before the first migration stripped it
+	var compressed map[string]bool
+	for {
+		meta, version, err := readRawMetadata(metaFilePath)
+		if err != nil {
+			return err
+		}
+		if version >= StoreFormatVersion {
+			for ext, compress := range compressed {
+				if err := writeSealedMetadataFile(filepath.Join(path, name+ext), compress); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		migrationLock.RLock()
+		m, ok := migrations[version]
+		migrationLock.RUnlock()
+		if !ok {
+			return NewFormatVersionError(name, version, StoreFormatVersion)
+		}
+		first := compressed == nil
+		if first {
+			compressed = map[string]bool{}
+		}
+		for _, ext := range []string{MetadataFileExt, TableFileExt} {
+			wasCompressed, err := writeBareMetadataFile(filepath.Join(path, name+ext))
+			if err != nil {
+				return err
+			}
+			if first {
+				compressed[ext] = wasCompressed
+			}
+		}
+		if err := m.fn(path); err != nil {
+			return fmt.Errorf("pixidb: migrating store '%s' from format version %d to %d: %w", name, version, m.to, err)
+		}
+
+		// the migration may have rewritten the metadata, so only the version is replaced
+		if meta, _, err = readRawMetadata(metaFilePath); err != nil {
+			return err
+		}
+		meta["formatVersion"] = json.RawMessage(strconv.Itoa(m.to))
+		if err := writeRawMetadata(metaFilePath, meta); err != nil {
+			return err
+		}
+	}
+}
+
+func readRawMetadata(metaFilePath string) (map[string]json.RawMessage, int, error) {
+	jsonText, _, err := readMetadataFile(metaFilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	meta := map[string]json.RawMessage{}
+	if err := json.Unmarshal(jsonText, &meta); err != nil {
+		return nil, 0, err
+	}
+	version := 0
+	if raw, ok := meta["formatVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, 0, err
+		}
+	}
+	return meta, version, nil
+}
+
+// Replace the metadata in the file, keeping it gzipped if it was.
+func writeRawMetadata(metaFilePath string, meta map[string]json.RawMessage) error {
+	jsonData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, compressed, err := readMetadataFile(metaFilePath)
+	if err != nil {
+		return err
+	}
+	return writeMetadataFile(metaFilePath, jsonData, compressed)
+}
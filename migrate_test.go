@@ -0,0 +1,224 @@
+package pixidb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/maps"
+)
+
+func TestOpenStoreMigration(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "legacy")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the store metadata as an older version, written before metadata had checksums
+	metaPath := filepath.Join(path, "legacy"+MetadataFileExt)
+	metaText, _, err := readMetadataFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := fmt.Sprintf(`"formatVersion":%d`, StoreFormatVersion)
+	edited := strings.Replace(string(metaText), current, `"formatVersion":0`, 1)
+	if edited == string(metaText) {
+		t.Fatalf("format version not found in metadata %s", metaText)
+	}
+	if err := os.WriteFile(metaPath, []byte(edited), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	migrationLock.Lock()
+	saved := maps.Clone(migrations)
+	migrationLock.Unlock()
+	defer func() {
+		migrationLock.Lock()
+		migrations = saved
+		migrationLock.Unlock()
+	}()
+
+	runs := 0
+	RegisterMigration(0, 1, func(path string) error {
+		runs++
+		tablePath := filepath.Join(path, filepath.Base(path)+TableFileExt)
+		tableText, err := os.ReadFile(tablePath)
+		if err != nil {
+			return err
+		}
+		var table map[string]any
+		if err := json.Unmarshal(tableText, &table); err != nil {
+			return err
+		}
+		table["metadata"].(map[string]any)["migrated"] = "yes"
+		tableText, err = json.Marshal(table)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(tablePath, tableText, 0666)
+	})
+
+	for i := 0; i < 2; i++ {
+		reopened, err := OpenTable(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrated, _ := reopened.GetMetadata("migrated"); migrated != "yes" {
+			t.Errorf("expected migrated metadata on open %d, got '%s'", i, migrated)
+		}
+		if reopened.store.FormatVersion != StoreFormatVersion {
+			t.Errorf("expected format version %d on open %d, got %d", StoreFormatVersion, i, reopened.store.FormatVersion)
+		}
+		if err := reopened.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("expected migration to run once, ran %d times", runs)
+	}
+
+	// without a migration path the older version is rejected
+	if err := os.WriteFile(metaPath, []byte(edited), 0666); err != nil {
+		t.Fatal(err)
+	}
+	migrationLock.Lock()
+	delete(migrations, 0)
+	migrationLock.Unlock()
+	var versionErr FormatVersionError
+	if _, err := OpenStore(path); !errors.As(err, &versionErr) || versionErr.Version != 0 {
+		t.Errorf("expected format version error for version 0, got %v", err)
+	}
+}
+
+func TestOpenStoreVersion2(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_version2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "plain")
+	store, err := NewStore(path, 100, NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRowAt(42, Row(NewInt32Value(7))); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// stores of version 2 have none of the page formats added in version 3
+	metaPath := filepath.Join(path, "plain"+MetadataFileExt)
+	meta, _, err := readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta["formatVersion"] = json.RawMessage("2")
+	if err := writeRawMetadata(metaPath, meta); err != nil {
+		t.Fatal(err)
+	}
+	var versionErr FormatVersionError
+	if _, err := OpenStoreReadOnly(path); !errors.As(err, &versionErr) || versionErr.Version != 2 {
+		t.Errorf("expected a read-only open of version 2 to fail until upgraded, got %v", err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.FormatVersion != 3 {
+		t.Errorf("expected the store upgraded to version 3, got %d", reopened.FormatVersion)
+	}
+	compareRow(t, reopened, 42, Row(NewInt32Value(7)))
+}
+
+func TestOpenStoreMigrationCompressed(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_migration_compressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "packed")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadataCompression(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	metaPath := filepath.Join(path, "packed"+MetadataFileExt)
+	meta, _, err := readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta["formatVersion"] = json.RawMessage("2")
+	if err := writeRawMetadata(metaPath, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both metadata files are gzipped and checksummed again once migrated
+	for _, ext := range []string{MetadataFileExt, TableFileExt} {
+		filePath := filepath.Join(path, "packed"+ext)
+		raw, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(raw, gzipMagic) {
+			t.Errorf("expected the %s file to stay gzipped after migrating", ext)
+			continue
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonText, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(jsonText, []byte(metadataChecksumPrefix)) {
+			t.Errorf("expected the %s file to keep its checksum after migrating", ext)
+		}
+	}
+	meta, version, err := readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != StoreFormatVersion {
+		t.Errorf("expected the store upgraded to version %d, got %d", StoreFormatVersion, version)
+	}
+	if _, ok := meta["pageSize"]; !ok {
+		t.Errorf("expected the migrated metadata to keep its page size")
+	}
+}
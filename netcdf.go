@@ -0,0 +1,308 @@
+package pixidb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"math"
+	"os"
+	"slices"
+
+	"golang.org/x/exp/maps"
+)
+
+// Tags and types of the NetCDF classic file format, see
+// https://docs.unidata.ucar.edu/netcdf-c/current/file_format_specifications.html
+const (
+	cdfTagDimension int32 = 0x0A
+	cdfTagVariable  int32 = 0x0B
+	cdfTagAttribute int32 = 0x0C
+
+	cdfTypeChar   int32 = 2
+	cdfTypeFloat  int32 = 5
+	cdfTypeDouble int32 = 6
+)
+
+type cdfDimension struct {
+	name   string
+	length int
+}
+
+type cdfAttribute struct {
+	name   string
+	kind   int32
+	count  int
+	values []byte
+}
+
+func cdfTextAttribute(name string, text string) cdfAttribute {
+	return cdfAttribute{name, cdfTypeChar, len(text), []byte(text)}
+}
+
+func cdfValueAttribute(name string, kind int32, val Value) cdfAttribute {
+	return cdfAttribute{name, kind, 1, val}
+}
+
+// A variable of a NetCDF file, with the data written in big-endian order by the write function
+// after the header, without the trailing padding.
+type cdfVariable struct {
+	name  string
+	dims  []int
+	attrs []cdfAttribute
+	kind  int32
+	size  int
+	write func(*bufio.Writer) error
+}
+
+type cdfFile struct {
+	dims  []cdfDimension
+	attrs []cdfAttribute
+	vars  []cdfVariable
+}
+
+func cdfPadding(size int) int {
+	return (4 - size%4) % 4
+}
+
+// Write the file in the classic format, switching to the 64-bit offset variant of the format
+// when the variable data would not be addressable with 32-bit offsets.
+func (f cdfFile) writeTo(w *bufio.Writer) error {
+	dataSize := 0
+	for _, v := range f.vars {
+		dataSize += v.size + cdfPadding(v.size)
+	}
+	offset64 := f.headerSize(false)+dataSize > math.MaxInt32
+
+	begin := f.headerSize(offset64)
+	put := func(val int32) {
+		binary.Write(w, binary.BigEndian, val)
+	}
+	putName := func(name string) {
+		put(int32(len(name)))
+		w.WriteString(name)
+		w.Write(make([]byte, cdfPadding(len(name))))
+	}
+	putAttrs := func(attrs []cdfAttribute) {
+		if len(attrs) == 0 {
+			put(0)
+			put(0)
+			return
+		}
+		put(cdfTagAttribute)
+		put(int32(len(attrs)))
+		for _, a := range attrs {
+			putName(a.name)
+			put(a.kind)
+			put(int32(a.count))
+			w.Write(a.values)
+			w.Write(make([]byte, cdfPadding(len(a.values))))
+		}
+	}
+
+	if offset64 {
+		w.WriteString("CDF\x02")
+	} else {
+		w.WriteString("CDF\x01")
+	}
+	put(0)
+	if len(f.dims) == 0 {
+		put(0)
+		put(0)
+	} else {
+		put(cdfTagDimension)
+		put(int32(len(f.dims)))
+		for _, d := range f.dims {
+			putName(d.name)
+			put(int32(d.length))
+		}
+	}
+	putAttrs(f.attrs)
+	if len(f.vars) == 0 {
+		put(0)
+		put(0)
+	} else {
+		put(cdfTagVariable)
+		put(int32(len(f.vars)))
+		for _, v := range f.vars {
+			putName(v.name)
+			put(int32(len(v.dims)))
+			for _, d := range v.dims {
+				put(int32(d))
+			}
+			putAttrs(v.attrs)
+			put(v.kind)
+			put(int32(min(v.size+cdfPadding(v.size), math.MaxInt32)))
+			if offset64 {
+				binary.Write(w, binary.BigEndian, int64(begin))
+			} else {
+				put(int32(begin))
+			}
+			begin += v.size + cdfPadding(v.size)
+		}
+	}
+
+	for _, v := range f.vars {
+		if err := v.write(w); err != nil {
+			return err
+		}
+		w.Write(make([]byte, cdfPadding(v.size)))
+	}
+	return w.Flush()
+}
+
+// The number of bytes in the header of the file, which precedes the variable data.
+func (f cdfFile) headerSize(offset64 bool) int {
+	nameSize := func(name string) int {
+		return 4 + len(name) + cdfPadding(len(name))
+	}
+	attrsSize := func(attrs []cdfAttribute) int {
+		size := 8
+		for _, a := range attrs {
+			size += nameSize(a.name) + 8 + len(a.values) + cdfPadding(len(a.values))
+		}
+		return size
+	}
+
+	size := 8 + 8 + attrsSize(f.attrs) + 8
+	for _, d := range f.dims {
+		size += nameSize(d.name) + 4
+	}
+	for _, v := range f.vars {
+		size += nameSize(v.name) + 4 + 4*len(v.dims) + attrsSize(v.attrs) + 8
+		if offset64 {
+			size += 8
+		} else {
+			size += 4
+		}
+	}
+	return size
+}
+
+// Write a column of the table as a two dimensional NetCDF variable named after the column, sized
+// by the width and height of the grid of the indexer. Float32 columns are written as floats and
+// all other columns as doubles, with the no-data sentinel of the column as the fill value. For
+// the cylindrical and Mercator indexers, latitude and longitude coordinate variables in degrees
+// are derived from the pixel centers; the projectionless indexer is written with plain 'y' and 'x'
+// dimensions. Other indexers are not supported. The metadata of the table is written as global
+// attributes of the file.
+func (t *Table) ExportNetCDF(path string, column string) error {
+	var grid ProjectionlessIndexer
+	located := true
+	switch indexer := t.Indexer.(type) {
+	case MercatorCutoffIndexer:
+		grid = indexer.Grid
+	case CylindricalEquirectangularIndexer:
+		grid = indexer.Grid
+	case ProjectionlessIndexer:
+		grid = indexer
+		located = false
+	default:
+		return NewIndexerNotSupportedError(t.Indexer.Name(), "netcdf export")
+	}
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	columnProj, err := t.store.Projection(column)
+	if err != nil {
+		return err
+	}
+	col := t.store.FilterColumns(columnProj)[0]
+
+	var file cdfFile
+	keys := maps.Keys(t.Metadata)
+	slices.Sort(keys)
+	for _, k := range keys {
+		file.attrs = append(file.attrs, cdfTextAttribute(k, t.Metadata[k]))
+	}
+
+	if located {
+		file.attrs = append(file.attrs, cdfTextAttribute("Conventions", "CF-1.8"))
+		file.dims = []cdfDimension{{"lat", grid.Height}, {"lon", grid.Width}}
+		lats := make([]float64, grid.Height)
+		for y := range lats {
+			loc, err := t.Indexer.ToLocation(grid.indexAt(GridLocation{0, y}))
+			if err != nil {
+				return err
+			}
+			lats[y] = loc.Latitude * 180 / math.Pi
+		}
+		lons := make([]float64, grid.Width)
+		for x := range lons {
+			loc, err := t.Indexer.ToLocation(grid.indexAt(GridLocation{x, 0}))
+			if err != nil {
+				return err
+			}
+			lons[x] = loc.Longitude * 180 / math.Pi
+		}
+		file.vars = append(file.vars,
+			cdfCoordinateVariable("lat", 0, "latitude", "degrees_north", lats),
+			cdfCoordinateVariable("lon", 1, "longitude", "degrees_east", lons))
+	} else {
+		file.dims = []cdfDimension{{"y", grid.Height}, {"x", grid.Width}}
+	}
+
+	data := cdfVariable{
+		name: column,
+		dims: []int{0, 1},
+		kind: cdfTypeDouble,
+		size: grid.Size() * 8,
+	}
+	encode := func(val Value) Value {
+		return NewFloat64Value(col.Type.DecodeFloat64(val))
+	}
+	if col.Type == ColumnTypeFloat32 {
+		data.kind = cdfTypeFloat
+		data.size = grid.Size() * 4
+		encode = func(val Value) Value {
+			return val
+		}
+	}
+	if col.NoData != nil {
+		data.attrs = append(data.attrs, cdfValueAttribute("_FillValue", data.kind, encode(col.NoData)))
+	}
+	data.write = func(w *bufio.Writer) error {
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				row, err := t.store.GetRowAt(grid.indexAt(GridLocation{x, y}))
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(encode(row.Project(columnProj)[0])); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	file.vars = append(file.vars, data)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := file.writeTo(bufio.NewWriter(out)); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func cdfCoordinateVariable(name string, dim int, standardName string, units string, coords []float64) cdfVariable {
+	return cdfVariable{
+		name: name,
+		dims: []int{dim},
+		attrs: []cdfAttribute{
+			cdfTextAttribute("standard_name", standardName),
+			cdfTextAttribute("units", units),
+		},
+		kind: cdfTypeDouble,
+		size: len(coords) * 8,
+		write: func(w *bufio.Writer) error {
+			for _, c := range coords {
+				if _, err := w.Write(NewFloat64Value(c)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package pixidb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owlpinetech/healpix"
+)
+
+// Just enough of a reader for the NetCDF classic format to check the files that are written.
+type testCDFVariable struct {
+	dims  []int
+	attrs map[string][]byte
+	kind  int32
+	begin int64
+}
+
+type testCDFFile struct {
+	data  []byte
+	dims  []cdfDimension
+	attrs map[string][]byte
+	vars  map[string]testCDFVariable
+}
+
+func readTestCDF(t *testing.T, path string) testCDFFile {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:3], []byte("CDF")) {
+		t.Fatalf("missing netcdf magic number, got %v", data[:4])
+	}
+	offset64 := data[3] == 2
+	pos := 8
+	next := func() int {
+		val := int(int32(binary.BigEndian.Uint32(data[pos:])))
+		pos += 4
+		return val
+	}
+	name := func() string {
+		length := next()
+		n := string(data[pos : pos+length])
+		pos += length + cdfPadding(length)
+		return n
+	}
+	typeSize := map[int32]int{cdfTypeChar: 1, cdfTypeFloat: 4, cdfTypeDouble: 8}
+	attrs := func() map[string][]byte {
+		next()
+		count := next()
+		attrs := make(map[string][]byte, count)
+		for i := 0; i < count; i++ {
+			n := name()
+			kind := int32(next())
+			length := next() * typeSize[kind]
+			attrs[n] = data[pos : pos+length]
+			pos += length + cdfPadding(length)
+		}
+		return attrs
+	}
+
+	file := testCDFFile{data: data, vars: map[string]testCDFVariable{}}
+	next()
+	dimCount := next()
+	for i := 0; i < dimCount; i++ {
+		file.dims = append(file.dims, cdfDimension{name(), next()})
+	}
+	file.attrs = attrs()
+	next()
+	varCount := next()
+	for i := 0; i < varCount; i++ {
+		n := name()
+		v := testCDFVariable{dims: make([]int, next())}
+		for d := range v.dims {
+			v.dims[d] = next()
+		}
+		v.attrs = attrs()
+		v.kind = int32(next())
+		next()
+		if offset64 {
+			v.begin = int64(binary.BigEndian.Uint64(data[pos:]))
+			pos += 8
+		} else {
+			v.begin = int64(next())
+		}
+		file.vars[n] = v
+	}
+	return file
+}
+
+func (f testCDFFile) double(name string, index int) float64 {
+	v := f.vars[name]
+	if v.kind == cdfTypeFloat {
+		return float64(Value(f.data[v.begin+int64(index*4):]).AsFloat32())
+	}
+	return Value(f.data[v.begin+int64(index*8):]).AsFloat64()
+}
+
+func TestTableExportNetCDF(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_export_netcdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexer := NewCylindricalEquirectangularIndexer(0, 5, 3, false)
+	tbl, err := NewTable(filepath.Join(dir, "located"), indexer,
+		NewColumnFloat32("temp", 0).WithNoData(NewFloat32Value(-1)),
+		NewColumnInt16("count", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.SetMetadata("source", "unit test")
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 3; y++ {
+			if err := tbl.SetValue("temp", GridLocation{x, y}, NewFloat32Value(float32(10*y+x)+0.5)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tbl.SetValue("count", GridLocation{x, y}, NewInt16Value(int16(x-y))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	path := filepath.Join(dir, "located.nc")
+	if err := tbl.ExportNetCDF(path, "temp"); err != nil {
+		t.Fatal(err)
+	}
+	file := readTestCDF(t, path)
+	if len(file.dims) != 2 || file.dims[0] != (cdfDimension{"lat", 3}) || file.dims[1] != (cdfDimension{"lon", 5}) {
+		t.Fatalf("unexpected dimensions %v", file.dims)
+	}
+	if string(file.attrs["source"]) != "unit test" {
+		t.Errorf("expected table metadata as global attribute, got %q", file.attrs["source"])
+	}
+	temp := file.vars["temp"]
+	if temp.kind != cdfTypeFloat || len(temp.dims) != 2 || temp.dims[0] != 0 || temp.dims[1] != 1 {
+		t.Errorf("unexpected temp variable %v", temp)
+	}
+	if !bytes.Equal(temp.attrs["_FillValue"], NewFloat32Value(-1)) {
+		t.Errorf("expected fill value from no-data sentinel, got %v", temp.attrs["_FillValue"])
+	}
+	for _, expect := range []struct{ lat, lon, value float64 }{{0, 0, 0.5}, {0, 4, 4.5}, {2, 1, 21.5}, {1, 3, 13.5}} {
+		if value := file.double("temp", int(expect.lat)*5+int(expect.lon)); value != expect.value {
+			t.Errorf("expected temp %v at (%v, %v), got %v", expect.value, expect.lat, expect.lon, value)
+		}
+	}
+	for _, expect := range []struct {
+		name  string
+		index int
+		deg   float64
+	}{{"lat", 0, -90}, {"lat", 1, 0}, {"lat", 2, 90}, {"lon", 0, -180}, {"lon", 2, 0}, {"lon", 4, 180}} {
+		if deg := file.double(expect.name, expect.index); math.Abs(deg-expect.deg) > 1e-9 {
+			t.Errorf("expected %s coordinate %d to be %v, got %v", expect.name, expect.index, expect.deg, deg)
+		}
+	}
+
+	if err := tbl.ExportNetCDF(path, "count"); err != nil {
+		t.Fatal(err)
+	}
+	file = readTestCDF(t, path)
+	if file.vars["count"].kind != cdfTypeDouble {
+		t.Errorf("expected integer column to be written as doubles")
+	}
+	if value := file.double("count", 2*5+1); value != -1 {
+		t.Errorf("expected count -1, got %v", value)
+	}
+
+	plain, err := NewTable(filepath.Join(dir, "plain"), NewProjectionlessIndexer(2, 4, true), NewColumnFloat64("value", 2.25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.ExportNetCDF(path, "value"); err != nil {
+		t.Fatal(err)
+	}
+	file = readTestCDF(t, path)
+	if len(file.dims) != 2 || file.dims[0] != (cdfDimension{"y", 4}) || file.dims[1] != (cdfDimension{"x", 2}) {
+		t.Fatalf("unexpected dimensions %v", file.dims)
+	}
+	if len(file.vars) != 1 || file.double("value", 7) != 2.25 {
+		t.Errorf("unexpected projectionless variables %v", file.vars)
+	}
+
+	if err := plain.ExportNetCDF(path, "missing"); err == nil {
+		t.Errorf("expected error exporting a missing column")
+	}
+	healpixTbl, err := NewTable(filepath.Join(dir, "healpix"), NewFlatHealpixIndexer(1, healpix.NestScheme), NewColumnFloat32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notSupported IndexerNotSupportedError
+	if err := healpixTbl.ExportNetCDF(path, "value"); !errors.As(err, &notSupported) {
+		t.Errorf("expected healpix export to be unsupported, got %v", err)
+	}
+}
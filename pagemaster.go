@@ -1,11 +1,17 @@
 package pixidb
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
-	"fmt"
+	"errors"
 	"hash/crc32"
+	"io"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/exp/maps"
 )
@@ -15,6 +21,14 @@ import (
 // 4 bytes for int32 checksum in each page
 const ChecksumSize int = 4
 
+// The size in bytes of the length stored after the checksum of each page of a file whose pages
+// are encoded, see pageCodec.
+const pageLengthSize int = 4
+
+// The number of pages (or rows) processed by long running operations between
+// checks of whether their context has been cancelled.
+const ContextCheckInterval int = 64
+
 // Wrapper struct for a page that has been loaded into memory. Contains
 // a 'dirty' flag to mark the cached page as having received an update
 // in the data that needs to be flushed to disk.
@@ -23,6 +37,73 @@ type Page struct {
 	dirty bool
 }
 
+// When a Pagemaster asks the operating system to commit written pages to the disk itself. Until
+// the data file is synced, written pages may only live in the operating system's cache and can be
+// lost on power failure, but each sync waits for the disk, so more frequent syncing trades write
+// throughput for durability.
+type SyncMode int
+
+const (
+	// Never sync, leaving it to the operating system to eventually write pages to the disk. The
+	// fastest mode, and the default. Durable flushes can still be requested explicitly.
+	SyncNever SyncMode = iota
+	// Sync once after all dirty pages have been flushed, so that every checkpoint is durable.
+	// Pages written when evicted from the cache are only durable after the next checkpoint.
+	SyncOnCheckpoint
+	// Sync after every write of pages, including pages evicted from the cache, and each batch of
+	// pages written together when initializing. The slowest mode, for when no completed write
+	// may ever be lost.
+	SyncPerPage
+)
+
+// Transforms pages between their form in the cache and a shorter form stored in the file, such
+// as one with some of its bytes compressed. The stored form of a page is written after its
+// checksum and length, and the rest of the page in the file is left unwritten, so that it takes
+// no space on filesystems with sparse files.
+type pageCodec interface {
+	// The stored form of the page, which must be no longer than the page size less the length.
+	encode(page []byte) []byte
+	// Fill the page with the data of its stored form, erroring if the stored form is malformed.
+	decode(stored []byte, page []byte) error
+}
+
+// The operations the Pagemaster needs from the storage holding its pages, an open file on disk
+// by default. Each operation opens the device and closes it when done.
+type blockDevice interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+func openOSPageFile(path string, flag int) (blockDevice, error) {
+	return os.OpenFile(path, flag, 0666)
+}
+
+// A device that stays open for the life of the pagemaster, ignoring the closes after each
+// operation.
+type openDevice struct {
+	blockDevice
+}
+
+func (d openDevice) Close() error {
+	return nil
+}
+
+// Opens the data files of new pagemasters, replaceable so that tests can inject disk failures.
+var openPageFile = openOSPageFile
+
+// Counters describing how the cache of a Pagemaster has been used since it was created, for
+// tuning the number of pages allowed in the cache.
+type PagemasterStats struct {
+	Hits         int64 // page accesses served from the cache
+	Misses       int64 // page accesses that read the page from disk
+	Evictions    int64 // pages removed from the cache to make room for another page
+	WriteBacks   int64 // cached pages written to disk, either when evicted or flushed
+	BytesWritten int64 // bytes of the pages written back, including their checksums
+}
+
 // Abstracts the data access and caching in memory of a large file using
 // a fixed page size. Individual operations intended to be threadsafe and
 // allow for concurrency while maintaining efficiency. This abstraction
@@ -37,40 +118,304 @@ type Pagemaster struct {
 	lock     sync.RWMutex
 	path     string
 	pageSize int
+	closed   bool
+	readOnly bool // set at creation for devices that cannot be written, such as remote files
+	syncMode SyncMode
+	prefetch int       // pages read ahead of sequential scans, zero to disable
+	attempts int       // times writing back a dirty page being evicted is tried, see SetEvictionAttempts
+	codec    pageCodec // how pages are stored in the file, as they are in the cache when nil
+	rowCheck bool      // pages are checked row by row by their store, so reads skip page checksums
+	openFile func(path string, flag int) (blockDevice, error)
+
+	// counters are atomic since cache hits are served under the read lock
+	hits         atomic.Int64
+	misses       atomic.Int64
+	evictions    atomic.Int64
+	writeBacks   atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// The number of times writing back an evicted page is tried by default, see SetEvictionAttempts.
+const DefaultEvictionAttempts int = 3
+
+// The wait before the first retry of a failed write back, doubling for each retry after it,
+// replaceable so that tests need not wait.
+var evictionBackoff = 10 * time.Millisecond
+
+// The memory page size of the host, replaceable so that tests can stand in for other hosts.
+var hostPageSize = os.Getpagesize
+
+// The number of data bytes in each page when no page size is given, such that a page and its
+// checksum fill one memory page of this host.
+func DefaultPageSize() int {
+	return hostPageSize() - ChecksumSize
 }
 
 // Create a new cached data layer to access the file on disk location at `path`, with
 // the specified number of pages allowed in the cache. No disk side effect. Must call
 // Initialize afterward if the path is to a newly created (empty) file.
 func NewPagemaster(path string, maxCache int) *Pagemaster {
+	return NewPagemasterPageSize(path, maxCache, DefaultPageSize())
+}
+
+// Create a new cached data layer like NewPagemaster, with the given number of data bytes in
+// each page instead of the default. A file must be read with the page size it was written with.
+func NewPagemasterPageSize(path string, maxCache int, pageSize int) *Pagemaster {
 	return &Pagemaster{
-		maxCache,
-		make(map[int]*Page),
-		sync.RWMutex{},
-		path,
-		os.Getpagesize() - ChecksumSize,
+		maxCache: maxCache,
+		cache:    make(map[int]*Page),
+		path:     path,
+		pageSize: pageSize,
+		openFile: openPageFile,
+		attempts: DefaultEvictionAttempts,
+	}
+}
+
+// Create a new cached data layer like NewPagemaster, allowing as many pages in the cache as fit
+// in the given number of bytes, see CachePagesForBytes, so memory use is the same across hosts
+// with different page sizes.
+func NewPagemasterBytes(path string, maxBytes int) *Pagemaster {
+	return NewPagemaster(path, CachePagesForBytes(maxBytes, DefaultPageSize()))
+}
+
+// The number of pages of the given size to allow in the cache so that the pages it holds, with
+// their checksums, take up no more than the given number of bytes. Once full, the cache holds one
+// page beyond the number allowed, which is accounted for. At least one page is always allowed.
+func CachePagesForBytes(maxBytes int, pageSize int) int {
+	return max(1, maxBytes/(pageSize+ChecksumSize)-1)
+}
+
+// Create a cached data layer like NewPagemasterPageSize over an already open device rather than a
+// file on disk, such as the pages of a store in memory. The path only names the device in errors.
+// Must call Initialize afterward if the device is empty.
+func newPagemasterDevice(path string, device blockDevice, maxCache int, pageSize int) *Pagemaster {
+	p := NewPagemasterPageSize(path, maxCache, pageSize)
+	p.openFile = func(path string, flag int) (blockDevice, error) {
+		return openDevice{device}, nil
+	}
+	return p
+}
+
+// Change when the pagemaster syncs written pages to the disk, see SyncMode.
+func (p *Pagemaster) SetSyncMode(mode SyncMode) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.syncMode = mode
+}
+
+// Change how many times writing back a dirty page being evicted from the cache is tried before
+// the eviction fails with an EvictionError. Values below one are treated as one.
+func (p *Pagemaster) SetEvictionAttempts(attempts int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.attempts = max(attempts, 1)
+}
+
+// Change how many pages sequential scans read ahead of the page being scanned, loading them
+// in the background while the scanned page is processed. Zero or less disables read-ahead.
+func (p *Pagemaster) SetPrefetchDepth(depth int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.prefetch = max(depth, 0)
+}
+
+// The number of pages sequential scans read ahead, see SetPrefetchDepth.
+func (p *Pagemaster) PrefetchDepth() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.prefetch
+}
+
+// Load up to depth pages following the current page into the cache, ahead of them being read
+// in order, skipping those already cached. The depth is limited so that the current page and
+// those after it all fit in the cache together, and room is only ever made by evicting pages
+// outside of that window, so read-ahead never pushes out the page being read or the pages it
+// just loaded. The caller must not ask for pages past the end of the file.
+func (p *Pagemaster) Prefetch(current int, depth int) error {
+	p.lock.Lock()
+	depth = min(depth, p.maxCache-1)
+	p.lock.Unlock()
+
+	for pageIndex := current + 1; pageIndex <= current+depth; pageIndex++ {
+		if err := p.prefetchPage(pageIndex, current, current+depth); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// Load a single page for Prefetch, evicting a page outside of the window from keepFirst to
+// keepLast inclusive if the cache is full. The lock is taken per page so that reads of the
+// pages being scanned are not held up for the whole read-ahead.
+func (p *Pagemaster) prefetchPage(pageIndex int, keepFirst int, keepLast int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	if _, ok := p.cache[pageIndex]; ok {
+		return nil
+	}
+	if len(p.cache) > p.maxCache {
+		for victim := range p.cache {
+			if victim >= keepFirst && victim <= keepLast {
+				continue
+			}
+			if err := p.evictPage(victim); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	_, err := p.loadPage(pageIndex)
+	return err
+}
+
+// Writes all dirty pages to disk and empties the cache, after which every operation that
+// reads or writes pages returns ErrClosed. If a write fails the pagemaster is left open, so
+// that the close can be retried. Closing an already closed pagemaster does nothing.
+func (p *Pagemaster) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	if _, err := p.flushAllPages(context.Background(), p.syncMode != SyncNever); err != nil {
+		return err
+	}
+	p.cache = make(map[int]*Page)
+	p.closed = true
+	return nil
+}
+
+// Point the pagemaster at its data file after the file has been moved to the given path. Cached
+// pages are kept, and dirty pages are written to the new path when they are flushed.
+func (p *Pagemaster) Relocate(path string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.path = path
 }
 
 // For pagemasters created over newly created empty files, this function will initialize
 // the file with the given number of pages, each page filled with the same given template
 // of data. If a write to the file fails, all of the writes that have succeeded to that
 // point will not be undone. However, future calls to Initialize (e.g. a rety), will write
-// over any data that was written previously.
+// over any data that was written previously, and cut off any pages past the new ones.
 func (p *Pagemaster) Initialize(pages int, page []byte) error {
+	return p.InitializeCtx(context.Background(), pages, page)
+}
+
+// Same as Initialize, but periodically checks the context and stops early with the context
+// error if it has been cancelled. Pages written before cancellation are left in place.
+func (p *Pagemaster) InitializeCtx(ctx context.Context, pages int, page []byte) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	return p.writePages(ctx, 0, pages, page, true)
+}
 
-	file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE, 0666)
+// Append pages to the end of the file, writing the same given template of data to each page
+// from the index of the first new page up to, but not including, the end index. Any cached
+// copies of those pages are dropped so that they are read fresh.
+func (p *Pagemaster) Extend(from int, to int, page []byte) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	for i := from; i < to; i++ {
+		delete(p.cache, i)
+	}
+	return p.writePages(context.Background(), from, to, page, false)
+}
+
+// Cut the file off after the given number of pages, dropping any cached copies of the pages past
+// the new end without writing them back. Syncs the file unless the sync mode is SyncNever.
+func (p *Pagemaster) Truncate(pages int) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	for i := range p.cache {
+		if i >= pages {
+			delete(p.cache, i)
+		}
+	}
+
+	file, err := p.openFile(p.path, os.O_RDWR)
 	if err != nil {
-		return err
+		return p.fileError(fileOpTruncate, err)
+	}
+	defer file.Close()
+	if err := file.Truncate(int64(pages) * int64(p.pageSize+ChecksumSize)); err != nil {
+		return p.fileError(fileOpTruncate, err)
+	}
+	if p.syncMode != SyncNever {
+		return p.fileError(fileOpTruncate, file.Sync())
+	}
+	return nil
+}
+
+// Write the template page to each page from the first index up to the end index, then cut the
+// file off after the last page written if truncate is set. The pages between context checks are
+// laid out along with their checksums in one buffer and written together, so that large files
+// take few writes.
+func (p *Pagemaster) writePages(ctx context.Context, from int, to int, page []byte, truncate bool) error {
+	file, err := p.openFile(p.path, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return p.fileError(fileOpInit, err)
 	}
 	defer file.Close()
 
-	for i := 0; i < pages; i++ {
-		if err := p.writePage(file, i, page); err != nil {
+	slotSize := int64(p.pageSize + ChecksumSize)
+	encoded := p.encodePage(page)
+	var buffer []byte
+	if p.codec == nil {
+		buffer = bytes.Repeat(encoded, min(ContextCheckInterval, max(0, to-from)))
+	}
+	for i := from; i < to; i += ContextCheckInterval {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		pages := min(ContextCheckInterval, to-i)
+		if p.codec == nil {
+			if _, err := file.WriteAt(buffer[:pages*len(encoded)], int64(i)*slotSize); err != nil {
+				return p.fileError(fileOpInit, err)
+			}
+		} else {
+			// encoded pages are shorter than their place in the file, so each is written alone
+			for j := i; j < i+pages; j++ {
+				if _, err := file.WriteAt(encoded, int64(j)*slotSize); err != nil {
+					return p.fileError(fileOpInit, err)
+				}
+			}
+		}
+		if p.syncMode == SyncPerPage {
+			if err := file.Sync(); err != nil {
+				return p.fileError(fileOpInit, err)
+			}
+		}
+	}
+	// the file must reach the end of the last encoded page, which is left unwritten
+	if truncate || p.codec != nil {
+		if err := file.Truncate(int64(to) * slotSize); err != nil {
+			return p.fileError(fileOpInit, err)
+		}
+	}
+	if p.syncMode == SyncOnCheckpoint {
+		return p.fileError(fileOpInit, file.Sync())
 	}
 	return nil
 }
@@ -92,6 +437,31 @@ func (p *Pagemaster) PagesInCache() int {
 	return len(p.cache)
 }
 
+// The current number of pages in the cache that have been modified but not yet written to disk.
+func (p *Pagemaster) DirtyPages() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	dirty := 0
+	for _, page := range p.cache {
+		if page.dirty {
+			dirty++
+		}
+	}
+	return dirty
+}
+
+// A snapshot of the cache usage counters. Counters are read individually, so a snapshot taken
+// during concurrent access may not reflect a single instant.
+func (p *Pagemaster) Stats() PagemasterStats {
+	return PagemasterStats{
+		Hits:         p.hits.Load(),
+		Misses:       p.misses.Load(),
+		Evictions:    p.evictions.Load(),
+		WriteBacks:   p.writeBacks.Load(),
+		BytesWritten: p.bytesWritten.Load(),
+	}
+}
+
 // Empties the cache of all pages. Does not destroy the data in the pages,
 // so if those are still referenced elsewhere they will not be garbage collected.
 // No disk side effect.
@@ -122,6 +492,7 @@ func (p *Pagemaster) GetPage(pageIndex int) ([]byte, error) {
 	p.lock.RUnlock()
 
 	if ok {
+		p.hits.Add(1)
 		return cached.data, nil
 	}
 
@@ -132,21 +503,63 @@ func (p *Pagemaster) GetPage(pageIndex int) ([]byte, error) {
 	return page, nil
 }
 
-// Essentially the same actions as GetPage, but returns a portion of the page data
-// at the given byte offset.
+// Essentially the same actions as GetPage, but returns a copy of a portion of the page
+// data at the given byte offset. The copy is taken while holding the page lock, so the
+// returned chunk will never observe a partially applied write from another goroutine.
 func (p *Pagemaster) GetChunk(pageIndex int, offset int, size int) ([]byte, error) {
-	page, err := p.GetPage(pageIndex)
+	chunk := make([]byte, size)
+
+	p.lock.RLock()
+	cached, ok := p.cache[pageIndex]
+	if ok {
+		copy(chunk, cached.data[offset:offset+size])
+		p.lock.RUnlock()
+		p.hits.Add(1)
+		return chunk, nil
+	}
+	p.lock.RUnlock()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	page, err := p.getPage(pageIndex)
 	if err != nil {
 		return nil, err
 	}
+	copy(chunk, page.data[offset:offset+size])
+	return chunk, nil
+}
+
+// Call the view function with a portion of the page data at the given byte offset, as GetChunk
+// does but without copying it. The page lock is held while view runs, so it never observes a
+// partially applied write, and it must neither modify nor retain the chunk.
+func (p *Pagemaster) ViewChunk(pageIndex int, offset int, size int, view func([]byte)) error {
+	p.lock.RLock()
+	cached, ok := p.cache[pageIndex]
+	if ok {
+		view(cached.data[offset : offset+size])
+		p.lock.RUnlock()
+		p.hits.Add(1)
+		return nil
+	}
+	p.lock.RUnlock()
 
-	return page[offset : offset+size], nil
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	page, err := p.getPage(pageIndex)
+	if err != nil {
+		return err
+	}
+	view(page.data[offset : offset+size])
+	return nil
 }
 
 // Sets the data for the page at the given index, and marks the cache entry as dirty.
 // If the page does not yet exist in the cache, it will exist in the cache afterwards,
 // potentially unloading a different page to make room.
 func (p *Pagemaster) SetPage(pageIndex int, page []byte) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
 	// make sure to keep the cache under the max, GetPage does the trick
 	_, err := p.GetPage(pageIndex)
 	if err != nil {
@@ -161,6 +574,9 @@ func (p *Pagemaster) SetPage(pageIndex int, page []byte) error {
 
 // Similar to SetPage but only updates the specified portion of data in the page.
 func (p *Pagemaster) SetChunk(pageIndex int, offset int, chunk []byte) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	page, err := p.getPage(pageIndex)
@@ -173,6 +589,35 @@ func (p *Pagemaster) SetChunk(pageIndex int, offset int, chunk []byte) error {
 	return nil
 }
 
+// Applies the modify function to the portion of the page at the given byte offset, holding
+// the page lock for the whole read-modify-write so that no other chunk reads or writes can
+// interleave with it. The chunk passed to modify aliases the cached page and may be updated
+// in place. The page is marked dirty afterward.
+func (p *Pagemaster) ModifyChunk(pageIndex int, offset int, size int, modify func([]byte)) error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	page, err := p.getPage(pageIndex)
+	if err != nil {
+		return err
+	}
+
+	modify(page.data[offset : offset+size])
+	page.dirty = true
+	return nil
+}
+
+// Replaces the bytes of the page from the given byte offset with those returned by the update
+// function, which receives the current bytes from the offset to the end of the page. As with
+// ModifyChunk, the page lock is held across the whole read-modify-write.
+func (p *Pagemaster) UpdateChunk(pageIndex int, offset int, update func(cur []byte) []byte) error {
+	return p.ModifyChunk(pageIndex, offset, p.pageSize-offset, func(chunk []byte) {
+		copy(chunk, update(chunk))
+	})
+}
+
 // Writes the page in the cache to disk, whether it is dirty or not. Marks
 // the page as clean afterward. If the page does not exist in the cache, no
 // action is taken. If the write is unsuccessful, the page dirtiness status
@@ -180,10 +625,14 @@ func (p *Pagemaster) SetChunk(pageIndex int, offset int, chunk []byte) error {
 func (p *Pagemaster) FlushPage(pageIndex int) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
 	page, ok := p.cache[pageIndex]
 	if !ok {
 		return nil
 	}
+	p.writeBacks.Add(1)
 	err := p.openAndWritePage(pageIndex, page.data)
 	if err == nil {
 		page.dirty = true
@@ -195,47 +644,353 @@ func (p *Pagemaster) FlushPage(pageIndex int) error {
 // the file until writing is complete. If a page write files, the process is stopped
 // and an error is returned, but only the successfully written pages will be marked
 // clean. The page on which the write errored, and the remaining dirty pages, will
-// still be marked dirty if the managing process wants to retry flushing.
-func (p *Pagemaster) FlushAllPages() error {
+// still be marked dirty if the managing process wants to retry flushing. Returns the
+// number of pages written, including those written before an error.
+func (p *Pagemaster) FlushAllPages() (int, error) {
+	return p.FlushAllPagesCtx(context.Background())
+}
+
+// Same as FlushAllPages, but periodically checks the context and stops early with the
+// context error if it has been cancelled. As with a failed write, pages flushed before
+// cancellation are marked clean and the rest remain dirty.
+func (p *Pagemaster) FlushAllPagesCtx(ctx context.Context) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return 0, ErrClosed
+	}
+	return p.flushAllPages(ctx, p.syncMode != SyncNever)
+}
+
+// Same as FlushAllPagesCtx, but always syncs the data file to the disk afterward regardless of
+// the sync mode, so that every page written so far is durable once it returns. The sync happens
+// even if no pages were dirty, since pages evicted earlier may not have been synced.
+func (p *Pagemaster) FlushAllPagesDurableCtx(ctx context.Context) (int, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	if p.closed {
+		return 0, ErrClosed
+	}
+	return p.flushAllPages(ctx, true)
+}
+
+// Writes the pages at the given indices to the disk if they are cached and dirty, leaving all
+// other pages alone, then syncs according to the sync mode as FlushAllPages does. As with
+// FlushAllPages, a failed write stops the flush with the pages not yet written still dirty.
+func (p *Pagemaster) FlushPages(indices []int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	_, err := p.flushPages(context.Background(), indices, p.syncMode != SyncNever)
+	return err
+}
+
+func (p *Pagemaster) flushAllPages(ctx context.Context, sync bool) (int, error) {
+	return p.flushPages(ctx, maps.Keys(p.cache), sync)
+}
+
+// Write the dirty pages among those at the given indices, returning the number written.
+func (p *Pagemaster) flushPages(ctx context.Context, indices []int, sync bool) (int, error) {
+	// only open the file if there is something to write or sync
+	var file blockDevice
+	open := func() error {
+		if file != nil {
+			return nil
+		}
+		opened, err := p.openFile(p.path, os.O_RDWR|os.O_CREATE)
+		if err != nil {
+			return p.fileError(fileOpWrite, err)
+		}
+		file = opened
+		return nil
+	}
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	flushed := 0
+	for _, id := range indices {
+		if page, ok := p.cache[id]; ok && page.dirty {
+			if flushed%ContextCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return flushed, err
+				}
+			}
+			if err := open(); err != nil {
+				return flushed, err
+			}
+			p.writeBacks.Add(1)
+			if err := p.writePage(file, id, page.data); err != nil {
+				return flushed, err
+			}
+			flushed++
+			page.dirty = false
+		}
+	}
+
+	// pages are already synced one by one in the per page mode
+	if !sync || (flushed > 0 && p.syncMode == SyncPerPage) {
+		return flushed, nil
+	}
+	if err := open(); err != nil {
+		return flushed, err
+	}
+	return flushed, p.fileError(fileOpWrite, file.Sync())
+}
+
+// Writes the pages that are dirty when called to disk one at a time, taking the page lock only
+// while writing each page, so that other readers and writers are stalled for at most a single
+// page write rather than the whole flush. Stops early with the context error if the context is
+// cancelled, leaving the remaining pages dirty.
+func (p *Pagemaster) FlushDirtyPagesCtx(ctx context.Context) error {
+	p.lock.RLock()
+	dirty := make([]int, 0, len(p.cache))
 	for id, page := range p.cache {
 		if page.dirty {
-			err := p.openAndWritePage(id, page.data)
-			if err != nil {
-				return err
+			dirty = append(dirty, id)
+		}
+	}
+	p.lock.RUnlock()
+
+	for _, id := range dirty {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := p.flushIfDirty(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pagemaster) flushIfDirty(pageIndex int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	page, ok := p.cache[pageIndex]
+	if !ok || !page.dirty {
+		return nil
+	}
+	p.writeBacks.Add(1)
+	if err := p.openAndWritePage(pageIndex, page.data); err != nil {
+		return err
+	}
+	page.dirty = false
+	return nil
+}
+
+// Read the page at the given index from disk, bypassing the cache, and check its checksum.
+// Returns a PageCorruptedError if the checksum does not match or the page is cut short by the
+// end of the file.
+func (p *Pagemaster) VerifyPage(pageIndex int) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	_, err := p.readPage(pageIndex)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return NewPageCorruptedError(p.path, pageIndex, 0, 0)
+	}
+	return err
+}
+
+// Check the checksums of the pages from the first index up to, but not including, the end index
+// like VerifyPage, returning the indices of the corrupted pages in increasing order. Pages are
+// read from disk one after another while the given number of goroutines check them, so that
+// checksumming large files is not limited to one processor. Errors other than corruption stop
+// the scan, returning the corrupted pages found before it stopped.
+func (p *Pagemaster) VerifyPages(from int, to int, workers int) ([]int, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	file, err := p.openFile(p.path, os.O_RDONLY)
+	if err != nil {
+		return nil, p.fileError(fileOpRead, err)
+	}
+	defer file.Close()
+
+	type pageRead struct {
+		index int
+		data  []byte
+	}
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	corrupted := []int{}
+	markCorrupted := func(index int) {
+		lock.Lock()
+		defer lock.Unlock()
+		corrupted = append(corrupted, index)
+	}
+	reads := make(chan pageRead, max(1, workers))
+	for w := 0; w < max(1, workers); w++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for read := range reads {
+				if !p.checksumMatches(read.data) {
+					markCorrupted(read.index)
+				}
 			}
-			page.dirty = false
+		}()
+	}
+
+	for i := from; i < to; i++ {
+		data := make([]byte, p.pageSize+ChecksumSize)
+		_, err = file.ReadAt(data, int64(i)*int64(len(data)))
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			markCorrupted(i)
+			err = nil
+			continue
+		} else if err != nil {
+			err = p.fileError(fileOpRead, err)
+			break
+		}
+		reads <- pageRead{i, data}
+	}
+	close(reads)
+	wait.Wait()
+	slices.Sort(corrupted)
+	return corrupted, err
+}
+
+// Read the pages from the first index up to, but not including, the end index from disk one after
+// another, bypassing the cache and without checking their checksums, and call visit with the data
+// of each, which is only valid until visit returns. Pages cut short by the end of the file are
+// visited with nil data. Stops at the first error. The lock is held throughout, so visit must not call back
+// into the pagemaster.
+func (p *Pagemaster) ReadPages(from int, to int, visit func(pageIndex int, page []byte) error) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	file, err := p.openFile(p.path, os.O_RDONLY)
+	if err != nil {
+		return p.fileError(fileOpRead, err)
+	}
+	defer file.Close()
+
+	data := make([]byte, p.pageSize+ChecksumSize)
+	for i := from; i < to; i++ {
+		_, err := file.ReadAt(data, int64(i)*int64(len(data)))
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = visit(i, nil)
+		} else if err == nil {
+			err = visit(i, data[ChecksumSize:])
+		} else {
+			return p.fileError(fileOpRead, err)
+		}
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// Overwrite the page at the given index on disk with a freshly checksummed page. If the page
+// is in the cache, the cached data is written instead, as the best copy of the page, and true
+// is returned; otherwise the given replacement data is written.
+func (p *Pagemaster) RepairPage(pageIndex int, replacement []byte) (bool, error) {
+	if p.readOnly {
+		return false, ErrReadOnly
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return false, ErrClosed
+	}
+	if page, ok := p.cache[pageIndex]; ok {
+		p.writeBacks.Add(1)
+		if err := p.openAndWritePage(pageIndex, page.data); err != nil {
+			return true, err
+		}
+		page.dirty = false
+		return true, nil
+	}
+	return false, p.openAndWritePage(pageIndex, replacement)
+}
+
 func (p *Pagemaster) loadPage(pageIndex int) (*Page, error) {
+	if p.closed {
+		return nil, ErrClosed
+	}
 	if page, ok := p.cache[pageIndex]; ok {
+		p.hits.Add(1)
 		return page, nil
 	}
 
+	// clear out room in the cache if necessary, before reading the page from disk, since an
+	// eviction may let go of the lock and another goroutine may load and change the page meanwhile
+	for len(p.cache) > p.maxCache {
+		// TODO: make this into LRU/LFU/ARC cache to reduce nondeterministic thrashing
+		if err := p.evictPage(maps.Keys(p.cache)[0]); err != nil {
+			return nil, err
+		}
+		if page, ok := p.cache[pageIndex]; ok {
+			p.hits.Add(1)
+			return page, nil
+		}
+	}
+
 	// page not present in cache, get it from disk
+	p.misses.Add(1)
 	pageData, err := p.readPage(pageIndex)
 	if err != nil {
 		return nil, err
 	}
-	// load page into cache, clearing out room if necessary
-	if len(p.cache) > p.maxCache {
-		remPage := maps.Keys(p.cache)[0]
-		p.openAndWritePage(remPage, p.cache[remPage].data)
-		// TODO: make this into LRU/LFU/ARC cache to reduce nondeterministic thrashing
-		delete(p.cache, remPage)
-	}
 	p.cache[pageIndex] = &Page{pageData, false}
 	return p.cache[pageIndex], nil
 }
 
+// Drop the page from the cache, first writing it back if it is dirty. A failed write back is
+// attempted again, waiting twice as long before each attempt, up to the number of attempts set
+// with SetEvictionAttempts. If every attempt fails the page stays in the cache and an
+// EvictionError is returned, so that its changes are not lost. Must be called holding the write
+// lock, which is let go while waiting between attempts so that other reads and writes of the
+// cache are not held up; the cache may have changed by the time it returns.
+func (p *Pagemaster) evictPage(pageIndex int) error {
+	page := p.cache[pageIndex]
+	var err error
+	for attempt := 0; attempt < p.attempts && page.dirty; attempt++ {
+		if attempt > 0 {
+			p.lock.Unlock()
+			time.Sleep(evictionBackoff << (attempt - 1))
+			p.lock.Lock()
+			if p.closed {
+				return ErrClosed
+			}
+			if p.cache[pageIndex] != page {
+				// evicted by another goroutine while waiting
+				return nil
+			}
+		}
+		p.writeBacks.Add(1)
+		if err = p.openAndWritePage(pageIndex, page.data); err == nil {
+			page.dirty = false
+		}
+	}
+	if page.dirty {
+		return NewEvictionError(p.path, pageIndex, p.attempts, err)
+	}
+	p.evictions.Add(1)
+	delete(p.cache, pageIndex)
+	return nil
+}
+
 func (p *Pagemaster) getPage(pageIndex int) (*Page, error) {
 	cached, ok := p.cache[pageIndex]
 
 	if ok {
+		p.hits.Add(1)
 		return cached, nil
 	}
 
@@ -247,50 +1002,122 @@ func (p *Pagemaster) getPage(pageIndex int) (*Page, error) {
 }
 
 func (p *Pagemaster) openAndWritePage(pageIndex int, page []byte) error {
-	file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE, 0666)
+	file, err := p.openFile(p.path, os.O_RDWR|os.O_CREATE)
 	if err != nil {
-		return err
+		return p.fileError(fileOpWrite, err)
 	}
 	defer file.Close()
 
 	return p.writePage(file, pageIndex, page)
 }
 
-func (p *Pagemaster) writePage(file *os.File, pageIndex int, page []byte) error {
-	if len(page) < p.pageSize {
-		fill := make([]byte, p.pageSize-len(page))
-		page = append(page, fill...)
-	}
-
-	checksum := crc32.ChecksumIEEE(page)
+// Write the page and its checksum to the file, syncing afterward in the per page sync mode.
+func (p *Pagemaster) writePage(file blockDevice, pageIndex int, page []byte) error {
 	offset := int64(pageIndex) * int64(p.pageSize+ChecksumSize)
-	encoded := make([]byte, ChecksumSize)
-	binary.BigEndian.PutUint32(encoded, checksum)
+	encoded := p.encodePage(page)
 	if _, err := file.WriteAt(encoded, offset); err != nil {
-		return err
+		return p.fileError(fileOpWrite, err)
 	}
-	if _, err := file.WriteAt(page, offset+int64(ChecksumSize)); err != nil {
-		return err
+	p.bytesWritten.Add(int64(len(encoded)))
+	if p.syncMode == SyncPerPage {
+		return p.fileError(fileOpWrite, file.Sync())
 	}
 	return nil
 }
 
+// The operations on the data file named in a PageFileError.
+const (
+	fileOpRead     = "read"
+	fileOpWrite    = "write"
+	fileOpInit     = "init"
+	fileOpTruncate = "truncate"
+)
+
+// Wrap an error from the data file with the operation that failed and the path of the file, or
+// return nil if there is no error.
+func (p *Pagemaster) fileError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return NewPageFileError(p.path, op, err)
+}
+
+// The page as it is laid out in the file, its checksum followed by its data padded with zeros
+// to the page size. Encoded pages are instead laid out as their checksum, length and stored
+// form, without padding.
+func (p *Pagemaster) encodePage(page []byte) []byte {
+	if p.codec != nil {
+		stored := p.codec.encode(page)
+		encoded := make([]byte, ChecksumSize+pageLengthSize+len(stored))
+		binary.BigEndian.PutUint32(encoded[ChecksumSize:], uint32(len(stored)))
+		copy(encoded[ChecksumSize+pageLengthSize:], stored)
+		binary.BigEndian.PutUint32(encoded, crc32.ChecksumIEEE(encoded[ChecksumSize:]))
+		return encoded
+	}
+	encoded := make([]byte, ChecksumSize+p.pageSize)
+	copy(encoded[ChecksumSize:], page)
+	binary.BigEndian.PutUint32(encoded, crc32.ChecksumIEEE(encoded[ChecksumSize:]))
+	return encoded
+}
+
 func (p *Pagemaster) readPage(pageIndex int) ([]byte, error) {
-	file, err := os.Open(p.path)
+	file, err := p.openFile(p.path, os.O_RDONLY)
 	if err != nil {
-		return nil, err
+		return nil, p.fileError(fileOpRead, err)
 	}
 	defer file.Close()
 
 	offset := int64(pageIndex) * int64(p.pageSize+ChecksumSize)
 	page := make([]byte, p.pageSize+ChecksumSize)
 	if _, err := file.ReadAt(page, offset); err != nil {
-		return nil, err
+		return nil, p.fileError(fileOpRead, err)
+	}
+	if !p.rowCheck && !p.checksumMatches(page) {
+		stored, computed := p.checksums(page)
+		return nil, NewPageCorruptedError(p.path, pageIndex, stored, computed)
+	}
+	if p.codec == nil {
+		return page[ChecksumSize:], nil
+	}
+	length := binary.BigEndian.Uint32(page[ChecksumSize:])
+	stored := page[ChecksumSize+pageLengthSize : ChecksumSize+pageLengthSize+int(length)]
+	data := make([]byte, p.pageSize)
+	if err := p.codec.decode(stored, data); err != nil {
+		checksum := binary.BigEndian.Uint32(page)
+		return nil, NewPageCorruptedError(p.path, pageIndex, checksum, checksum)
 	}
-	savedChecksum := binary.BigEndian.Uint32(page)
-	if savedChecksum != crc32.ChecksumIEEE(page[ChecksumSize:]) {
-		// TODO: move this error into an ERRORS file
-		return nil, fmt.Errorf("pixidb: Database read revealed corrupted data on a page")
+	return data, nil
+}
+
+// Whether the checksum at the start of the page as laid out in the file matches the data it
+// covers, which for encoded pages is only the length and stored form of the page.
+func (p *Pagemaster) checksumMatches(page []byte) bool {
+	if p.codec == nil {
+		return checksumMatches(page)
+	}
+	if p.encodedEnd(page) > int64(len(page)) {
+		return false
+	}
+	stored, computed := p.checksums(page)
+	return stored == computed
+}
+
+// The checksum at the start of the page as laid out in the file and the checksum of the data it
+// covers. The data of an encoded page whose length runs past the end of the page is cut short.
+func (p *Pagemaster) checksums(page []byte) (uint32, uint32) {
+	end := int64(len(page))
+	if p.codec != nil {
+		end = min(end, p.encodedEnd(page))
 	}
-	return page[ChecksumSize:], nil
+	return binary.BigEndian.Uint32(page), crc32.ChecksumIEEE(page[ChecksumSize:end])
+}
+
+// The end of the stored form of an encoded page as laid out in the file, given by its length.
+func (p *Pagemaster) encodedEnd(page []byte) int64 {
+	return int64(ChecksumSize+pageLengthSize) + int64(binary.BigEndian.Uint32(page[ChecksumSize:]))
+}
+
+// Whether the checksum at the start of the page as laid out in the file matches its data.
+func checksumMatches(page []byte) bool {
+	return binary.BigEndian.Uint32(page) == crc32.ChecksumIEEE(page[ChecksumSize:])
 }
@@ -0,0 +1,737 @@
+package pixidb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInitializeCancel(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_initialize_cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pm := NewPagemaster(filepath.Join(dir, "cancel.dat"), MaxPagesInCache)
+	ctx := &countdownContext{Context: context.Background(), remaining: 2}
+	err = pm.InitializeCtx(ctx, 10*ContextCheckInterval, make([]byte, pm.PageSize()))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected initialize to be cancelled, got %v", err)
+	}
+
+	// the pages written before cancellation are still there, but nothing afterward
+	info, err := os.Stat(filepath.Join(dir, "cancel.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSize := int64(2*ContextCheckInterval) * int64(pm.PageSize()+ChecksumSize)
+	if info.Size() != expectSize {
+		t.Errorf("expected %d bytes written before cancellation, got %d", expectSize, info.Size())
+	}
+}
+
+func TestFlushAllPagesCancel(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_flush_cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pages := 3 * ContextCheckInterval
+	pm := NewPagemaster(filepath.Join(dir, "cancel.dat"), pages)
+	if err := pm.Initialize(pages, make([]byte, pm.PageSize())); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < pages; i++ {
+		if err := pm.SetChunk(i, 0, []byte{1, 2, 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := &countdownContext{Context: context.Background(), remaining: 1}
+	if _, err := pm.FlushAllPagesCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected flush to be cancelled, got %v", err)
+	}
+
+	dirty := 0
+	for _, page := range pm.cache {
+		if page.dirty {
+			dirty++
+		}
+	}
+	if dirty != pages-ContextCheckInterval {
+		t.Errorf("expected %d pages to remain dirty after cancellation, got %d", pages-ContextCheckInterval, dirty)
+	}
+}
+
+// A context that reports itself cancelled after its error has been checked a fixed
+// number of times, for deterministically cancelling operations partway through.
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *countdownContext) Err() error {
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return nil
+}
+
+func TestPagemasterStats(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pm := NewPagemaster(filepath.Join(dir, "stats.dat"), 2)
+	if err := pm.Initialize(5, make([]byte, pm.PageSize())); err != nil {
+		t.Fatal(err)
+	}
+	checkStats := func(expect PagemasterStats, dirty int) {
+		t.Helper()
+		if stats := pm.Stats(); stats != expect {
+			t.Errorf("expected stats %+v, got %+v", expect, stats)
+		}
+		if pm.DirtyPages() != dirty {
+			t.Errorf("expected %d dirty pages, got %d", dirty, pm.DirtyPages())
+		}
+	}
+	checkStats(PagemasterStats{}, 0)
+
+	if _, err := pm.GetChunk(0, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pm.GetChunk(0, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(PagemasterStats{Hits: 1, Misses: 1}, 0)
+
+	if err := pm.SetChunk(1, 0, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pm.GetPage(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.ModifyChunk(0, 0, 2, func(chunk []byte) { chunk[0] = 3 }); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(PagemasterStats{Hits: 3, Misses: 2}, 2)
+
+	// the cache holds one page past its maximum before evicting, so the fourth page evicts one,
+	// and every cached page is dirty so that whichever is evicted is written back
+	if err := pm.SetChunk(2, 0, []byte{4}); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(PagemasterStats{Hits: 3, Misses: 3}, 3)
+	if _, err := pm.GetChunk(3, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	stats := pm.Stats()
+	if stats.Misses != 4 || stats.Evictions != 1 || stats.WriteBacks != 1 {
+		t.Errorf("expected a miss with one eviction and write back, got %+v", stats)
+	}
+	if pm.PagesInCache() != 3 {
+		t.Errorf("expected 3 pages in cache after eviction, got %d", pm.PagesInCache())
+	}
+
+	dirty := pm.DirtyPages()
+	if _, err := pm.FlushAllPages(); err != nil {
+		t.Fatal(err)
+	}
+	writeBacks := 1 + int64(dirty)
+	checkStats(PagemasterStats{
+		Hits:         3,
+		Misses:       4,
+		Evictions:    1,
+		WriteBacks:   writeBacks,
+		BytesWritten: writeBacks * int64(pm.PageSize()+ChecksumSize),
+	}, 0)
+}
+
+// Counts the syncs made on files opened through it, across every file it opens.
+type syncCountingFile struct {
+	blockDevice
+	syncs *atomic.Int64
+}
+
+func (s syncCountingFile) Sync() error {
+	s.syncs.Add(1)
+	return s.blockDevice.Sync()
+}
+
+func TestPagemasterSyncModes(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name          string
+		mode          SyncMode
+		flushSyncs    int64
+		durableSyncs  int64
+		evictionSyncs int64
+		initSyncs     int64
+	}{
+		{"never", SyncNever, 0, 1, 0, 0},
+		{"checkpoint", SyncOnCheckpoint, 1, 1, 0, 1},
+		// the pages of an initialize are written, and synced, together
+		{"per page", SyncPerPage, 3, 3, 1, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var syncs atomic.Int64
+			pm := NewPagemaster(filepath.Join(dir, tc.name+".dat"), 3)
+			pm.openFile = func(path string, flag int) (blockDevice, error) {
+				file, err := openOSPageFile(path, flag)
+				if err != nil {
+					return nil, err
+				}
+				return syncCountingFile{file, &syncs}, nil
+			}
+			pm.SetSyncMode(tc.mode)
+
+			checkSyncs := func(action string, expect int64) {
+				t.Helper()
+				if count := syncs.Swap(0); count != expect {
+					t.Errorf("expected %d syncs on %s, got %d", expect, action, count)
+				}
+			}
+			if err := pm.Initialize(8, make([]byte, pm.PageSize())); err != nil {
+				t.Fatal(err)
+			}
+			checkSyncs("initialize", tc.initSyncs)
+
+			dirty := func() {
+				for i := 0; i < 3; i++ {
+					if err := pm.SetChunk(i, 0, []byte{byte(i + 1)}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			dirty()
+			checkSyncs("write", 0)
+			if _, err := pm.FlushAllPages(); err != nil {
+				t.Fatal(err)
+			}
+			checkSyncs("flush", tc.flushSyncs)
+
+			dirty()
+			if _, err := pm.FlushAllPagesDurableCtx(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			checkSyncs("durable flush", tc.durableSyncs)
+			if _, err := pm.FlushAllPagesDurableCtx(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			checkSyncs("durable flush without dirty pages", 1)
+
+			// the cache holds one page past its maximum, so the fifth page evicts one, and every
+			// cached page is dirty so that whichever is evicted is written back
+			dirty()
+			for i := 3; i < 5; i++ {
+				if err := pm.SetChunk(i, 0, []byte{byte(i + 1)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkSyncs("eviction", tc.evictionSyncs)
+		})
+	}
+}
+
+func TestPagemasterPrefetch(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_prefetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pm := NewPagemaster(filepath.Join(dir, "prefetch.dat"), 3)
+	if err := pm.Initialize(10, make([]byte, pm.PageSize())); err != nil {
+		t.Fatal(err)
+	}
+	// fill the cache with pages the read-ahead window does not cover
+	for _, page := range []int{7, 8, 9} {
+		if _, err := pm.GetPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pm.SetChunk(0, 0, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the depth is limited so the window of the current page and those ahead fits in the cache
+	if err := pm.Prefetch(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if pm.PagesInCache() != 4 {
+		t.Errorf("expected the cache to stay at 4 pages, got %d", pm.PagesInCache())
+	}
+	before := pm.Stats()
+	for _, page := range []int{0, 1, 2} {
+		if _, err := pm.GetPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := pm.Stats(); after.Hits-before.Hits != 3 || after.Misses != before.Misses {
+		t.Errorf("expected the current and prefetched pages to be cached, stats went from %+v to %+v", before, after)
+	}
+	if chunk, err := pm.GetChunk(0, 0, 1); err != nil || chunk[0] != 1 {
+		t.Errorf("expected the current page to keep its unflushed write, got %v, %v", chunk, err)
+	}
+
+	// prefetching pages already in the cache reads nothing
+	if err := pm.Prefetch(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pm.Stats(); stats.Misses != before.Misses {
+		t.Errorf("expected no further misses, got %+v", stats)
+	}
+}
+
+var errInjectedWrite = errors.New("injected write failure")
+var errInjectedRead = errors.New("injected read failure")
+
+// Fails the nth write made through it, counting from one, and every write after that until
+// failOn is reset. Zero never fails. Every read fails while failReads is set.
+type faultyDevice struct {
+	blockDevice
+	failOn    int
+	writes    int
+	failReads bool
+}
+
+func (f *faultyDevice) ReadAt(p []byte, off int64) (int, error) {
+	if f.failReads {
+		return 0, errInjectedRead
+	}
+	return f.blockDevice.ReadAt(p, off)
+}
+
+func (f *faultyDevice) WriteAt(p []byte, off int64) (int, error) {
+	f.writes++
+	if f.failOn > 0 && f.writes >= f.failOn {
+		return 0, errInjectedWrite
+	}
+	return f.blockDevice.WriteAt(p, off)
+}
+
+func TestPagemasterFaultyDevice(t *testing.T) {
+	device := &faultyDevice{blockDevice: &memoryPageFile{}, failOn: 2}
+	pm := newPagemasterDevice("faulty", device, 2, 64)
+
+	// the pages are written in batches between context checks, so the second batch fails
+	if err := pm.Initialize(2*ContextCheckInterval, make([]byte, pm.PageSize())); !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected the injected failure from Initialize, got %v", err)
+	}
+	device.failOn = 0
+	if err := pm.Initialize(4, make([]byte, pm.PageSize())); err != nil {
+		t.Fatalf("expected a retried Initialize to succeed, got %v", err)
+	}
+
+	if err := pm.SetChunk(1, 0, []byte{7, 7}); err != nil {
+		t.Fatal(err)
+	}
+	device.failOn = device.writes + 1
+	if _, err := pm.FlushAllPages(); !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected the injected failure from the flush, got %v", err)
+	}
+	if pm.DirtyPages() != 1 {
+		t.Errorf("expected the page to stay dirty after a failed flush, got %d dirty pages", pm.DirtyPages())
+	}
+	device.failOn = 0
+	if _, err := pm.FlushAllPages(); err != nil {
+		t.Fatal(err)
+	}
+	pm.ClearCache()
+	if chunk, err := pm.GetChunk(1, 0, 2); err != nil || chunk[0] != 7 || chunk[1] != 7 {
+		t.Errorf("expected the flushed chunk to be read back, got %v, %v", chunk, err)
+	}
+	// initializing again with fewer pages cuts off the rest
+	if err := pm.Initialize(2, make([]byte, pm.PageSize())); err != nil {
+		t.Fatal(err)
+	}
+	if size := len(device.blockDevice.(*memoryPageFile).data); size != 2*(64+ChecksumSize) {
+		t.Errorf("expected the device to hold 2 pages after initializing again, got %d bytes", size)
+	}
+}
+
+func TestPagemasterBulkInitializeLayout(t *testing.T) {
+	pages := 3*ContextCheckInterval + 5
+	template := []byte{9, 8, 7, 6, 5}
+
+	bulkDevice := &memoryPageFile{}
+	bulk := newPagemasterDevice("bulk", bulkDevice, 4, 64)
+	if err := bulk.Initialize(pages, template); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same pages written one at a time, as initializing did before pages were batched
+	singleDevice := &memoryPageFile{}
+	single := newPagemasterDevice("single", singleDevice, 4, 64)
+	for i := 0; i < pages; i++ {
+		if err := single.writePage(singleDevice, i, template); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !slices.Equal(bulkDevice.data, singleDevice.data) {
+		t.Fatalf("expected the batched pages to match pages written singly, got %d and %d bytes", len(bulkDevice.data), len(singleDevice.data))
+	}
+	expect := append(slices.Clone(template), make([]byte, 64-len(template))...)
+	for i := 0; i < pages; i++ {
+		page, err := bulk.readPage(i)
+		if err != nil {
+			t.Fatalf("page %d: %v", i, err)
+		}
+		if !slices.Equal(page, expect) {
+			t.Fatalf("page %d: expected %v, got %v", i, expect, page)
+		}
+	}
+}
+
+func benchmarkPagemasterInitialize(b *testing.B, initialize func(pm *Pagemaster, pages int) error) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_initialize_bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pm := NewPagemaster(filepath.Join(dir, "bench.dat"), MaxPagesInCache)
+	pages := 8192
+	b.SetBytes(int64(pages) * int64(pm.PageSize()+ChecksumSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := initialize(pm, pages); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPagemasterInitialize(b *testing.B) {
+	benchmarkPagemasterInitialize(b, func(pm *Pagemaster, pages int) error {
+		return pm.Initialize(pages, make([]byte, pm.PageSize()))
+	})
+}
+
+func BenchmarkPagemasterInitializePerPage(b *testing.B) {
+	benchmarkPagemasterInitialize(b, func(pm *Pagemaster, pages int) error {
+		file, err := pm.openFile(pm.path, os.O_RDWR|os.O_CREATE)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		for i := 0; i < pages; i++ {
+			if err := pm.writePage(file, i, make([]byte, pm.PageSize())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestNewPagemasterBytes(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		maxBytes int
+		pageSize int
+		expect   int
+	}{
+		{"4k pages", 256 << 20, 4092, 65535},
+		{"16k pages", 256 << 20, 16380, 16383},
+		{"partial page", 10*4096 + 100, 4092, 9},
+		{"smaller than a page", 1000, 4092, 1},
+	}
+	for _, tc := range testCases {
+		if pages := CachePagesForBytes(tc.maxBytes, tc.pageSize); pages != tc.expect {
+			t.Errorf("%s: expected %d pages for %d bytes, got %d", tc.name, tc.expect, tc.maxBytes, pages)
+		}
+	}
+
+	defer func(original func() int) { hostPageSize = original }(hostPageSize)
+	hostPageSize = func() int { return 16384 }
+	maxBytes := 8 * 16384
+	pm := NewPagemasterBytes(filepath.Join(dir, "bytes.dat"), maxBytes)
+	if pm.MaxPagesInCache() != 7 || pm.PageSize() != 16380 {
+		t.Fatalf("expected 7 pages of 16380 bytes, got %d pages of %d bytes", pm.MaxPagesInCache(), pm.PageSize())
+	}
+	if err := pm.Initialize(20, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := pm.GetChunk(i, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		if used := pm.PagesInCache() * (pm.PageSize() + ChecksumSize); used > maxBytes {
+			t.Fatalf("expected the cache to stay within %d bytes, holds %d after page %d", maxBytes, used, i)
+		}
+	}
+}
+
+// Fails the given number of writes made through it, then succeeds.
+type flakyDevice struct {
+	blockDevice
+	failures int
+}
+
+func (f *flakyDevice) WriteAt(p []byte, off int64) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		return 0, errInjectedWrite
+	}
+	return f.blockDevice.WriteAt(p, off)
+}
+
+func TestPagemasterEvictionRetry(t *testing.T) {
+	defer func(original time.Duration) { evictionBackoff = original }(evictionBackoff)
+	evictionBackoff = 0
+
+	device := &flakyDevice{blockDevice: &memoryPageFile{}}
+	pm := newPagemasterDevice("flaky", device, 1, 64)
+	if err := pm.Initialize(4, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := pm.SetChunk(i, 0, []byte{byte(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first write back fails and the retry succeeds, so loading the page works
+	device.failures = 1
+	if _, err := pm.GetChunk(2, 0, 1); err != nil {
+		t.Fatalf("expected the retried write back to succeed, got %v", err)
+	}
+	if stats := pm.Stats(); stats.WriteBacks != 2 || stats.Evictions != 1 {
+		t.Errorf("expected 2 write backs for 1 eviction, got %d for %d", stats.WriteBacks, stats.Evictions)
+	}
+
+	// once every attempt fails the page is kept rather than lost
+	if err := pm.SetChunk(2, 0, []byte{3}); err != nil {
+		t.Fatal(err)
+	}
+	pm.SetEvictionAttempts(2)
+	device.failures = 5
+	_, err := pm.GetChunk(3, 0, 1)
+	var evictErr EvictionError
+	if !errors.As(err, &evictErr) || evictErr.Attempts != 2 || evictErr.Path != "flaky" || !errors.Is(err, errInjectedWrite) {
+		t.Fatalf("expected an eviction error after 2 attempts, got %v", err)
+	}
+	if evictErr.Page < 0 || evictErr.Page > 2 {
+		t.Errorf("expected the failed page to be one of the cached pages, got %d", evictErr.Page)
+	}
+	if pm.PagesInCache() != 2 || pm.DirtyPages() != 2 {
+		t.Errorf("expected both dirty pages kept after the failed eviction, got %d pages and %d dirty", pm.PagesInCache(), pm.DirtyPages())
+	}
+
+	device.failures = 0
+	if _, err := pm.FlushAllPages(); err != nil {
+		t.Fatal(err)
+	}
+	pm.ClearCache()
+	for i := 0; i < 3; i++ {
+		if chunk, err := pm.GetChunk(i, 0, 1); err != nil || chunk[0] != byte(i+1) {
+			t.Errorf("page %d: expected %d written back, got %v, %v", i, i+1, chunk, err)
+		}
+	}
+}
+
+func TestPagemasterEvictionBackoffUnlocked(t *testing.T) {
+	defer func(original time.Duration) { evictionBackoff = original }(evictionBackoff)
+	evictionBackoff = 500 * time.Millisecond
+
+	device := &flakyDevice{blockDevice: &memoryPageFile{}}
+	pm := newPagemasterDevice("flaky", device, 1, 64)
+	if err := pm.Initialize(4, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := pm.SetChunk(i, 0, []byte{byte(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first write back fails, so the eviction waits before trying again
+	device.failures = 1
+	evicted := make(chan error)
+	go func() {
+		_, err := pm.GetChunk(2, 0, 1)
+		evicted <- err
+	}()
+	for pm.Stats().WriteBacks == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the cached pages can be read and written while the eviction waits
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := pm.GetChunk(i, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := pm.ModifyChunk(i, 1, 1, func(chunk []byte) { chunk[0] = 9 }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if waited := time.Since(start); waited >= evictionBackoff/2 {
+		t.Errorf("expected the cache to stay usable during the eviction backoff, waited %v", waited)
+	}
+
+	if err := <-evicted; err != nil {
+		t.Fatalf("expected the retried write back to succeed, got %v", err)
+	}
+	if _, err := pm.FlushAllPages(); err != nil {
+		t.Fatal(err)
+	}
+	pm.ClearCache()
+	for i := 0; i < 2; i++ {
+		if chunk, err := pm.GetChunk(i, 0, 2); err != nil || chunk[0] != byte(i+1) || chunk[1] != 9 {
+			t.Errorf("page %d: expected both writes kept, got %v, %v", i, chunk, err)
+		}
+	}
+}
+
+func TestPagemasterUpdateChunk(t *testing.T) {
+	pm := newPagemasterDevice("update", &memoryPageFile{}, 2, 64)
+	if err := pm.Initialize(2, make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent increments of the same bytes are never lost
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				err := pm.UpdateChunk(1, 10, func(cur []byte) []byte {
+					if len(cur) != 54 {
+						t.Errorf("expected the 54 bytes to the end of the page, got %d", len(cur))
+					}
+					count := binary.BigEndian.Uint16(cur) + 1
+					return binary.BigEndian.AppendUint16(nil, count)
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	chunk, err := pm.GetChunk(1, 10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := binary.BigEndian.Uint16(chunk); count != 800 || chunk[2] != 0 {
+		t.Errorf("expected 800 increments and the following byte untouched, got %d and %d", count, chunk[2])
+	}
+	if pm.DirtyPages() != 1 {
+		t.Errorf("expected the updated page dirty, got %d dirty pages", pm.DirtyPages())
+	}
+}
+
+func TestPagemasterCorruptedPageError(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_pagemaster_corrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "corrupted.dat")
+	pm := NewPagemasterPageSize(path, MaxPagesInCache, 64)
+	if err := pm.Initialize(4, make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte{7}, int64(2*(64+ChecksumSize)+ChecksumSize+9)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	expectStored := crc32.ChecksumIEEE(make([]byte, 64))
+	corruptedData := make([]byte, 64)
+	corruptedData[9] = 7
+	expectComputed := crc32.ChecksumIEEE(corruptedData)
+
+	var corrupted PageCorruptedError
+	if _, err := pm.GetChunk(2, 0, 4); !errors.As(err, &corrupted) {
+		t.Fatalf("expected a corrupted page error, got %v", err)
+	}
+	if corrupted.Page != 2 || corrupted.Path != path {
+		t.Errorf("expected page 2 of %s to be corrupted, got page %d of %s", path, corrupted.Page, corrupted.Path)
+	}
+	if corrupted.Stored != expectStored || corrupted.Computed != expectComputed {
+		t.Errorf("expected stored checksum %08x and computed %08x, got %08x and %08x",
+			expectStored, expectComputed, corrupted.Stored, corrupted.Computed)
+	}
+	if err := pm.VerifyPage(1); err != nil {
+		t.Errorf("expected page 1 to verify, got %v", err)
+	}
+}
+
+func TestPagemasterFileErrors(t *testing.T) {
+	device := &faultyDevice{blockDevice: &memoryPageFile{}, failOn: 1}
+	pm := newPagemasterDevice("faulty.dat", device, 2, 64)
+
+	checkFileError := func(err error, op string, cause error) {
+		t.Helper()
+		var fileErr PageFileError
+		if !errors.As(err, &fileErr) || fileErr.Op != op || fileErr.Path != "faulty.dat" {
+			t.Errorf("expected a %s error of faulty.dat, got %v", op, err)
+		}
+		if !errors.Is(err, cause) {
+			t.Errorf("expected the error to unwrap to %v, got %v", cause, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "faulty.dat") {
+			t.Errorf("expected the error to name the file, got %v", err)
+		}
+	}
+
+	checkFileError(pm.Initialize(4, make([]byte, pm.PageSize())), fileOpInit, errInjectedWrite)
+	device.failOn = 0
+	if err := pm.Initialize(4, make([]byte, pm.PageSize())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.SetChunk(1, 0, []byte{7}); err != nil {
+		t.Fatal(err)
+	}
+	device.failOn = device.writes + 1
+	_, err := pm.FlushAllPages()
+	checkFileError(err, fileOpWrite, errInjectedWrite)
+	device.failOn = 0
+
+	device.failReads = true
+	_, err = pm.GetChunk(3, 0, 1)
+	checkFileError(err, fileOpRead, errInjectedRead)
+	_, err = pm.VerifyPages(0, 4, 1)
+	checkFileError(err, fileOpRead, errInjectedRead)
+	device.failReads = false
+	if chunk, err := pm.GetChunk(3, 0, 1); err != nil || chunk[0] != 0 {
+		t.Errorf("expected the page to read once reads succeed, got %v, %v", chunk, err)
+	}
+}
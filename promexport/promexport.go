@@ -0,0 +1,72 @@
+// Package promexport registers the metrics of pixidb tables with Prometheus. It is a module of its
+// own, so that only programs importing it depend on the Prometheus client library.
+package promexport
+
+import (
+	"net/http"
+
+	"github.com/owlpinetech/pixidb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The counters reported by a Collector, each labelled with the table.
+var counters = []struct {
+	desc  *prometheus.Desc
+	value func(pixidb.Metrics) int64
+}{
+	{newDesc("pixidb_rows_read_total", "Rows read from the table."), func(m pixidb.Metrics) int64 { return m.RowsRead }},
+	{newDesc("pixidb_rows_written_total", "Rows written to the table."), func(m pixidb.Metrics) int64 { return m.RowsWritten }},
+	{newDesc("pixidb_cache_hits_total", "Page accesses served from the page cache."), func(m pixidb.Metrics) int64 { return m.CacheHits }},
+	{newDesc("pixidb_cache_misses_total", "Page accesses that read the page from disk."), func(m pixidb.Metrics) int64 { return m.CacheMisses }},
+	{newDesc("pixidb_checkpoints_total", "Checkpoints written."), func(m pixidb.Metrics) int64 { return m.Checkpoints }},
+	{newDesc("pixidb_flushed_bytes_total", "Bytes of pages written back to the data file."), func(m pixidb.Metrics) int64 { return m.BytesFlushed }},
+}
+
+func newDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, []string{"table"}, nil)
+}
+
+// A Prometheus collector of the metrics of every table in a database, as counters labelled with
+// the name of the table. The metrics are snapshotted on each collection.
+type Collector struct {
+	db *pixidb.Database
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+func NewCollector(db *pixidb.Database) *Collector {
+	return &Collector{db: db}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, counter := range counters {
+		ch <- counter.desc
+	}
+}
+
+// Send the counters of each table, or an invalid metric for each counter if the metrics of the
+// database cannot be read, which fails the scrape.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	metrics, err := c.db.Metrics()
+	if err != nil {
+		for _, counter := range counters {
+			ch <- prometheus.NewInvalidMetric(counter.desc, err)
+		}
+		return
+	}
+	for name, tableMetrics := range metrics {
+		for _, counter := range counters {
+			ch <- prometheus.MustNewConstMetric(counter.desc, prometheus.CounterValue, float64(counter.value(tableMetrics)), name)
+		}
+	}
+}
+
+// A handler serving the metrics of every table in the database to Prometheus scrapes, from a
+// registry of its own holding only a Collector of the database. Register a Collector with an
+// existing registry instead to serve the metrics alongside others.
+func Handler(db *pixidb.Database) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(db))
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
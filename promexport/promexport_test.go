@@ -0,0 +1,88 @@
+package promexport
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/owlpinetech/pixidb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_promexport_collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := pixidb.NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("grid", pixidb.NewProjectionlessIndexer(4, 4, true), pixidb.NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetRows("grid", []string{"value"}, pixidb.IndexLocation(0), pixidb.IndexLocation(15)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the collector sits in an existing registry alongside other metrics
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(NewCollector(db))
+	expected := `
+# HELP pixidb_rows_read_total Rows read from the table.
+# TYPE pixidb_rows_read_total counter
+pixidb_rows_read_total{table="grid"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "pixidb_rows_read_total"); err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(NewCollector(db)); count != len(counters) {
+		t.Errorf("expected %d counters for the one table, got %d", len(counters), count)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Gather(); err == nil {
+		t.Errorf("expected gathering the metrics of a closed database to fail")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_promexport_handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := pixidb.NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create("grid", pixidb.NewProjectionlessIndexer(4, 4, true), pixidb.NewColumnInt32("value", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetRows("grid", []string{"value"}, pixidb.IndexLocation(0), pixidb.IndexLocation(15)); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	Handler(db).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 200 || !strings.Contains(recorder.Body.String(), `pixidb_rows_read_total{table="grid"} 2`) {
+		t.Errorf("expected the handler to serve the metrics, got %d\n%s", recorder.Code, recorder.Body.String())
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	recorder = httptest.NewRecorder()
+	Handler(db).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 500 {
+		t.Errorf("expected the handler of a closed database to fail, got %d", recorder.Code)
+	}
+}
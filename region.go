@@ -0,0 +1,75 @@
+package pixidb
+
+import "math"
+
+// An area on the sphere, used to select the pixels whose centers fall within it.
+type Region interface {
+	Contains(SphericalLocation) bool
+}
+
+// The region between two parallels and two meridians, in radians. The box wraps across the
+// antimeridian when MinLongitude is greater than MaxLongitude. The edges are included.
+type BoundingBox struct {
+	MinLatitude  float64
+	MaxLatitude  float64
+	MinLongitude float64
+	MaxLongitude float64
+}
+
+func (b BoundingBox) Contains(loc SphericalLocation) bool {
+	if loc.Latitude < b.MinLatitude || loc.Latitude > b.MaxLatitude {
+		return false
+	}
+	lon := normalizeLongitude(loc.Longitude)
+	minLon, maxLon := normalizeLongitude(b.MinLongitude), normalizeLongitude(b.MaxLongitude)
+	if minLon <= maxLon {
+		return lon >= minLon && lon <= maxLon
+	}
+	return lon >= minLon || lon <= maxLon
+}
+
+// The region within a great-circle angle in radians of a center location, such as the disc
+// queries of HEALPix. The edge is included.
+type Disc struct {
+	Center SphericalLocation
+	Radius float64
+}
+
+func (d Disc) Contains(loc SphericalLocation) bool {
+	return d.Center.AngularDistance(loc) <= d.Radius
+}
+
+// Aggregate statistics of the decoded values of a column. Min, Max, and Mean are NaN when no
+// values were counted.
+type Stats struct {
+	Count int
+	Min   float64
+	Max   float64
+	Mean  float64
+}
+
+// Accumulates values into Stats, keeping a running sum for the mean.
+type statsAccumulator struct {
+	stats Stats
+	sum   float64
+}
+
+func newStatsAccumulator() *statsAccumulator {
+	return &statsAccumulator{stats: Stats{Min: math.Inf(1), Max: math.Inf(-1)}}
+}
+
+func (a *statsAccumulator) add(f float64) {
+	a.stats.Count++
+	a.stats.Min = math.Min(a.stats.Min, f)
+	a.stats.Max = math.Max(a.stats.Max, f)
+	a.sum += f
+}
+
+func (a *statsAccumulator) result() Stats {
+	if a.stats.Count == 0 {
+		return Stats{Min: math.NaN(), Max: math.NaN(), Mean: math.NaN()}
+	}
+	stats := a.stats
+	stats.Mean = a.sum / float64(stats.Count)
+	return stats
+}
@@ -0,0 +1,92 @@
+package pixidb
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoundingBoxContains(t *testing.T) {
+	deg := math.Pi / 180
+	box := BoundingBox{MinLatitude: -10 * deg, MaxLatitude: 10 * deg, MinLongitude: 170 * deg, MaxLongitude: -170 * deg}
+	testCases := []struct {
+		loc    SphericalLocation
+		expect bool
+	}{
+		{SphericalLocation{0, 175 * deg}, true},
+		{SphericalLocation{0, -175 * deg}, true},
+		{SphericalLocation{0, 185 * deg}, true},
+		{SphericalLocation{0, 0}, false},
+		{SphericalLocation{20 * deg, 180 * deg}, false},
+	}
+	for _, tc := range testCases {
+		if box.Contains(tc.loc) != tc.expect {
+			t.Errorf("expected %v contained in the box crossing the antimeridian to be %v", tc.loc, tc.expect)
+		}
+	}
+}
+
+func TestTableZonalStats(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_zonal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a pixel center every degree, each holding its longitude in degrees
+	deg := math.Pi / 180
+	tbl, err := NewTable(filepath.Join(dir, "ramp"), NewCylindricalEquirectangularIndexer(0, 361, 181, true),
+		NewColumnFloat64("lon", 0).WithNoData(NewFloat64Value(-999)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	for i := 0; i < tbl.RowCount(); i++ {
+		loc, err := tbl.Indexer.ToLocation(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.SetValue("lon", IndexLocation(i), NewFloat64Value(math.Round(loc.Longitude/deg))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noData, err := tbl.Indexer.ToIndex(SphericalLocation{0, 20 * deg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetValue("lon", IndexLocation(noData), NewFloat64Value(-999)); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		region Region
+		expect Stats
+	}{
+		// 11 by 11 pixels from 10 to 20 degrees east, less the no-data pixel, with a mean of
+		// (121 * 15 - 20) / 120
+		{"box", BoundingBox{-5.5 * deg, 5.5 * deg, 9.5 * deg, 20.5 * deg}, Stats{120, 10, 20, (121*15 - 20) / 120.0}},
+		// the center pixel and its four neighbors one degree away
+		{"disc", Disc{SphericalLocation{0, 15 * deg}, 1.2 * deg}, Stats{5, 14, 16, 15}},
+		{"empty", Disc{SphericalLocation{0, 15.5 * deg}, 0.1 * deg}, Stats{0, math.NaN(), math.NaN(), math.NaN()}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, err := tbl.ZonalStats("lon", tc.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			same := func(a, b float64) bool {
+				return math.Abs(a-b) < 1e-9 || (math.IsNaN(a) && math.IsNaN(b))
+			}
+			if stats.Count != tc.expect.Count || !same(stats.Min, tc.expect.Min) || !same(stats.Max, tc.expect.Max) || !same(stats.Mean, tc.expect.Mean) {
+				t.Errorf("expected %+v, got %+v", tc.expect, stats)
+			}
+		})
+	}
+
+	if _, err := tbl.ZonalStats("missing", Disc{}); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
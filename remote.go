@@ -0,0 +1,139 @@
+package pixidb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// Reads ranges of bytes of a remote object with HTTP range requests, such as a file in S3 or
+// another object store served over HTTP.
+type HTTPRangeReader struct {
+	Client *http.Client // the client making the requests, http.DefaultClient when nil
+	URL    string
+}
+
+func (r HTTPRangeReader) client() *http.Client {
+	if r.Client == nil {
+		return http.DefaultClient
+	}
+	return r.Client
+}
+
+func (r HTTPRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		// a server ignoring the range would send the whole object, which is never wanted
+		return 0, NewHTTPStatusError(r.URL, resp.StatusCode)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Read the whole remote object.
+func (r HTTPRangeReader) readAll() ([]byte, error) {
+	resp, err := r.client().Get(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewHTTPStatusError(r.URL, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// A block device that can only be read, over any source of ranges of bytes.
+type readOnlyDevice struct {
+	io.ReaderAt
+}
+
+func (r readOnlyDevice) WriteAt(p []byte, off int64) (int, error) {
+	return 0, ErrReadOnly
+}
+
+func (r readOnlyDevice) Sync() error {
+	return nil
+}
+
+func (r readOnlyDevice) Truncate(size int64) error {
+	return ErrReadOnly
+}
+
+func (r readOnlyDevice) Close() error {
+	return nil
+}
+
+// Open a read-only table whose files are served over HTTP from the directory at the given URL,
+// such as a table uploaded to S3, reading pages of the data file on demand with range requests
+// and caching them like a table on disk. The server must support range requests. Writes to the
+// table return ErrReadOnly. The client makes the requests, or http.DefaultClient when nil.
+func OpenTableRemote(tableURL string, client *http.Client) (*Table, error) {
+	base, err := url.Parse(tableURL)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(base.Path)
+	fileURL := func(ext string) HTTPRangeReader {
+		file := *base
+		file.Path = path.Join(base.Path, name+ext)
+		return HTTPRangeReader{Client: client, URL: file.String()}
+	}
+
+	storeText, err := readRemoteMetadata(fileURL(MetadataFileExt))
+	if err != nil {
+		return nil, err
+	}
+	store, err := unmarshalStore(name, tableURL, storeText)
+	if err != nil {
+		return nil, err
+	}
+	data := fileURL(DataFileExt)
+	store.file = newPagemasterDevice(data.URL, readOnlyDevice{data}, MaxPagesInCache, store.PageSize)
+	store.file.codec = newColumnGroupCodec(store.ColumnSet, store.rowsPerPage, store.Layout)
+	store.file.rowCheck = store.RowChecksums
+	store.file.readOnly = true
+
+	tableText, err := readRemoteMetadata(fileURL(TableFileExt))
+	if err != nil {
+		return nil, err
+	}
+	table := &Table{store: store}
+	if err := json.Unmarshal(tableText, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Fetch a metadata file, which may be gzipped.
+func readRemoteMetadata(file HTTPRangeReader) ([]byte, error) {
+	raw, err := file.readAll()
+	if err != nil {
+		return nil, err
+	}
+	jsonText, _, err := decodeMetadata(file.URL, raw)
+	return jsonText, err
+}
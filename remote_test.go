@@ -0,0 +1,107 @@
+package pixidb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOpenTableRemote(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local, err := NewTable(filepath.Join(dir, "grid"), NewProjectionlessIndexer(100, 100, true), NewColumnInt32("value", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < local.RowCount(); i += 7 {
+		if err := local.SetValue("value", IndexLocation(i), NewInt32Value(int32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := local.SetMetadata("source", "survey"); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file server answers range requests, standing in for an object store
+	var ranges atomic.Int64
+	files := http.FileServer(http.Dir(dir))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	remote, err := OpenTableRemote(server.URL+"/grid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	if remote.Name() != "grid" || remote.RowCount() != 10000 {
+		t.Errorf("expected the remote table grid with 10000 rows, got %s with %d", remote.Name(), remote.RowCount())
+	}
+	if source, _ := remote.GetMetadata("source"); source != "survey" {
+		t.Errorf("expected the remote metadata to be read, got %q", source)
+	}
+
+	locations := []Location{IndexLocation(0), IndexLocation(7), IndexLocation(8), IndexLocation(9996)}
+	result, err := remote.GetRows([]string{"value"}, locations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []int32{0, 7, -1, 9996} {
+		if got := result.Rows[i][0].AsInt32(); got != expect {
+			t.Errorf("expected row %d to hold %d, got %d", i, expect, got)
+		}
+	}
+	requested := ranges.Load()
+	if requested == 0 {
+		t.Fatal("expected pages to be read with range requests")
+	}
+	if _, err := remote.GetRows([]string{"value"}, locations...); err != nil {
+		t.Fatal(err)
+	}
+	if ranges.Load() != requested {
+		t.Errorf("expected cached pages to be read without more requests, went from %d to %d", requested, ranges.Load())
+	}
+
+	if err := remote.SetValue("value", IndexLocation(0), NewInt32Value(1)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly writing a value, got %v", err)
+	}
+	if err := remote.SetMetadata("source", "other"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly writing metadata, got %v", err)
+	}
+	if err := remote.Drop(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly dropping the table, got %v", err)
+	}
+
+	if _, err := OpenTableRemote(server.URL+"/missing", nil); !errors.As(err, &HTTPStatusError{}) {
+		t.Errorf("expected an HTTPStatusError for a missing table, got %v", err)
+	}
+
+	// a server that ignores ranges would send whole data files for every page
+	noRanges := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Range")
+		files.ServeHTTP(w, r)
+	}))
+	defer noRanges.Close()
+	unranged, err := OpenTableRemote(noRanges.URL+"/grid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unranged.GetValue("value", IndexLocation(0)); !errors.As(err, &HTTPStatusError{}) {
+		t.Errorf("expected an HTTPStatusError without range support, got %v", err)
+	}
+}
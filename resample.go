@@ -0,0 +1,120 @@
+package pixidb
+
+import (
+	"errors"
+	"reflect"
+)
+
+// How Resample finds the value of each destination pixel from the source table.
+type ResampleMethod int
+
+const (
+	// Use the value of the source pixel with its center nearest to the destination pixel center.
+	ResampleNearest ResampleMethod = iota
+	// Interpolate between the four source pixels surrounding the destination pixel center, see
+	// Table.SampleBilinear. Only sources with a regular projected grid support this method.
+	ResampleBilinear
+)
+
+// Fill the column of the destination table from the same column of the source table, sampling the
+// source at the center of each destination pixel. Values are converted through float64 when the
+// column types differ, and always by bilinear resampling. Destination pixels whose centers fall
+// outside of the source are left unchanged. Nothing is written to the destination if sampling
+// the source fails. If the destination is reindexed while the source is sampled, it is sampled
+// again for the new indexer.
+func Resample(dst *Table, src *Table, column string, method ResampleMethod) error {
+	for {
+		dst.lock.RLock()
+		dstIndexer, closed := dst.Indexer, dst.closed
+		dst.lock.RUnlock()
+		if closed {
+			return ErrClosed
+		}
+		dstProj, err := dst.store.Projection(column)
+		if err != nil {
+			return err
+		}
+		dstType := dst.store.FilterColumns(dstProj)[0].Type
+
+		values, err := sampleSource(src, column, method, dstIndexer, dstType)
+		if err != nil {
+			return err
+		}
+
+		dst.lock.Lock()
+		if dst.closed {
+			dst.lock.Unlock()
+			return ErrClosed
+		}
+		if !reflect.DeepEqual(dst.Indexer, dstIndexer) {
+			dst.lock.Unlock()
+			continue
+		}
+		err = dst.store.modifyEachRow(func(index int, row Row) error {
+			if values[index] != nil {
+				copy(row[dstProj[0].start:dstProj[0].start+dstProj[0].size], values[index])
+			}
+			return nil
+		})
+		dst.lock.Unlock()
+		return err
+	}
+}
+
+// Sample the column of the source table at the center of each pixel of the destination indexer,
+// encoding the values as the destination type. Pixels outside of the source are left nil. The
+// source is read locked for the whole of the sampling.
+func sampleSource(src *Table, column string, method ResampleMethod, dstIndexer LocationIndexer, dstType ColumnType) ([]Value, error) {
+	src.lock.RLock()
+	defer src.lock.RUnlock()
+	if src.closed {
+		return nil, ErrClosed
+	}
+	srcProj, err := src.store.Projection(column)
+	if err != nil {
+		return nil, err
+	}
+	srcType := src.store.FilterColumns(srcProj)[0].Type
+	if method == ResampleBilinear {
+		if _, ok := src.Indexer.(griddedIndexer); !ok {
+			return nil, NewIndexerNotSupportedError(src.Indexer.Name(), "bilinear resampling")
+		}
+	}
+
+	values := make([]Value, dstIndexer.Size())
+	for i := range values {
+		loc, err := dstIndexer.ToLocation(i)
+		if err != nil {
+			return nil, err
+		}
+		switch method {
+		case ResampleBilinear:
+			f, err := src.sampleBilinear(column, loc)
+			if err == nil {
+				values[i] = dstType.EncodeFloat64(f)
+			} else if !errors.As(err, &LocationOutOfBoundsError{}) {
+				return nil, err
+			}
+		default:
+			val, err := nearestValue(src, srcProj[0], loc)
+			if err == nil && srcType == dstType {
+				values[i] = val
+			} else if err == nil {
+				values[i] = dstType.EncodeFloat64(srcType.DecodeFloat64(val))
+			} else if !errors.As(err, &LocationOutOfBoundsError{}) {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// The value of the column in the pixel of the table nearest to the location. The caller must hold
+// the table lock.
+func nearestValue(table *Table, column ColumnProjection, loc SphericalLocation) (Value, error) {
+	index, _, err := table.Indexer.NearestIndex(loc)
+	if err != nil {
+		return nil, err
+	}
+	return table.store.getColumnAt(index, column)
+}
@@ -0,0 +1,163 @@
+package pixidb
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/owlpinetech/healpix"
+)
+
+func TestResample(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_resample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a ramp of the longitude of each source pixel center, in radians
+	src, err := NewTable(filepath.Join(dir, "src"), NewCylindricalEquirectangularIndexer(0, 72, 36, true), NewColumnFloat64("ramp", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 0; i < src.RowCount(); i++ {
+		loc, err := src.Indexer.ToLocation(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.SetValue("ramp", IndexLocation(i), NewFloat64Value(loc.Longitude)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order := healpix.HealpixOrder(2)
+	nearest, err := NewTable(filepath.Join(dir, "nearest"), NewFlatHealpixIndexer(order, healpix.NestScheme), NewColumnFloat64("ramp", -99))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nearest.Close()
+	bilinear, err := NewTable(filepath.Join(dir, "bilinear"), NewFlatHealpixIndexer(order, healpix.NestScheme), NewColumnFloat32("ramp", -99))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bilinear.Close()
+	if err := Resample(nearest, src, "ramp", ResampleNearest); err != nil {
+		t.Fatal(err)
+	}
+	if err := Resample(bilinear, src, "ramp", ResampleBilinear); err != nil {
+		t.Fatal(err)
+	}
+
+	// the centers of the edge pixels sit on the edges of the projection
+	halfPixel := math.Pi / 71
+	for _, pixel := range []int{0, 37, 64, 100, 150, 191} {
+		loc, err := nearest.Indexer.ToLocation(pixel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := nearest.GetValue("ramp", IndexLocation(pixel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(val.AsFloat64()-loc.Longitude) > halfPixel+1e-9 {
+			t.Errorf("pixel %d: expected the nearest longitude to %f, got %f", pixel, loc.Longitude, val.AsFloat64())
+		}
+
+		// interpolating a linear ramp is exact away from the seam at the antimeridian
+		val, err = bilinear.GetValue("ramp", IndexLocation(pixel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(loc.Longitude) < math.Pi-2*halfPixel && math.Abs(float64(val.AsFloat32())-loc.Longitude) > 1e-5 {
+			t.Errorf("pixel %d: expected interpolated longitude %f, got %f", pixel, loc.Longitude, val.AsFloat32())
+		}
+	}
+
+	// the projectionless grid has no locations on the sphere to sample at
+	grid, err := NewTable(filepath.Join(dir, "grid"), NewProjectionlessIndexer(4, 4, true), NewColumnFloat64("ramp", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer grid.Close()
+	if err := Resample(grid, src, "ramp", ResampleNearest); !errors.As(err, new(*LocationNotSupportedError)) {
+		t.Errorf("expected a LocationNotSupportedError, got %v", err)
+	}
+	if err := Resample(src, nearest, "ramp", ResampleBilinear); !errors.As(err, &IndexerNotSupportedError{}) {
+		t.Errorf("expected an IndexerNotSupportedError, got %v", err)
+	}
+	if err := Resample(nearest, src, "missing", ResampleNearest); err == nil {
+		t.Error("expected an error resampling a missing column")
+	}
+}
+
+func TestResampleConcurrentReindex(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_resample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	order := healpix.HealpixOrder(2)
+	src, err := NewTable(filepath.Join(dir, "src"), NewFlatHealpixIndexer(order, healpix.NestScheme), NewColumnFloat64("lon", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 0; i < src.RowCount(); i++ {
+		loc, err := src.Indexer.ToLocation(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.SetValue("lon", IndexLocation(i), NewFloat64Value(loc.Longitude)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst, err := NewTable(filepath.Join(dir, "dst"), NewFlatHealpixIndexer(order, healpix.NestScheme), NewColumnFloat64("lon", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// both tables switch schemes back and forth while resampling, which keeps each pixel at its location
+	var wg sync.WaitGroup
+	for _, table := range []*Table{src, dst} {
+		wg.Add(1)
+		go func(table *Table) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				scheme := healpix.RingScheme
+				if i%2 == 1 {
+					scheme = healpix.NestScheme
+				}
+				if err := table.ReindexHealpix(scheme); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(table)
+	}
+	for i := 0; i < 10; i++ {
+		if err := Resample(dst, src, "lon", ResampleNearest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	for i := 0; i < dst.RowCount(); i++ {
+		loc, err := dst.Indexer.ToLocation(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := dst.GetValue("lon", IndexLocation(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.AsFloat64() != loc.Longitude {
+			t.Errorf("pixel %d: expected longitude %f, got %f", i, loc.Longitude, val.AsFloat64())
+		}
+	}
+}
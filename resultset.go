@@ -0,0 +1,150 @@
+package pixidb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// The columns and rows returned by a query, with each row holding one value per column.
+type ResultSet struct {
+	Columns []Column
+	Rows    [][]Value
+}
+
+// The position of the named column in the result set.
+func (rs ResultSet) columnIndex(name string) (int, error) {
+	for i, col := range rs.Columns {
+		if col.Name == name {
+			return i, nil
+		}
+	}
+	return -1, NewColumnNotFoundError("result set", name)
+}
+
+// Decode the values of the named column across all rows into standard Go values of the type the
+// column was declared with. Values equal to the no-data sentinel of the column are nil.
+func (rs ResultSet) Column(name string) ([]any, error) {
+	index, err := rs.columnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	col := rs.Columns[index]
+	vals := make([]any, len(rs.Rows))
+	for i, row := range rs.Rows {
+		if !col.IsNoData(row[index]) {
+			vals[i] = col.Type.DecodeValue(row[index])
+		}
+	}
+	return vals, nil
+}
+
+// Decode the values of the named column across all rows as float64s, as by DecodeFloat64.
+// Values equal to the no-data sentinel of the column are NaN.
+func (rs ResultSet) Float64Column(name string) ([]float64, error) {
+	index, err := rs.columnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	col := rs.Columns[index]
+	vals := make([]float64, len(rs.Rows))
+	for i, row := range rs.Rows {
+		if col.IsNoData(row[index]) {
+			vals[i] = math.NaN()
+		} else {
+			vals[i] = col.Type.DecodeFloat64(row[index])
+		}
+	}
+	return vals, nil
+}
+
+// A single row of a result set, with the columns needed to decode its values.
+type ResultRow struct {
+	Columns []Column
+	Values  []Value
+}
+
+// The row at the given position in the result set.
+func (rs ResultSet) Row(i int) ResultRow {
+	return ResultRow{Columns: rs.Columns, Values: rs.Rows[i]}
+}
+
+// Decode the values of the row into the destinations, one per column in order, like Scan on
+// database/sql Rows. Each destination must be a pointer to the Go type of its column, such as
+// *int32 for ColumnTypeInt32 or *time.Time for ColumnTypeTimestamp, or a *any, which is set to
+// nil for values equal to the no-data sentinel of the column. Returns a ScanCountError if the
+// number of destinations does not match the columns, and a ScanError wrapping a ValueTypeError
+// for a destination of the wrong type.
+func (row ResultRow) Scan(dest ...any) error {
+	if len(dest) != len(row.Columns) {
+		return NewScanCountError(len(row.Columns), len(dest))
+	}
+	for i, col := range row.Columns {
+		if d, ok := dest[i].(*any); ok {
+			if col.IsNoData(row.Values[i]) {
+				*d = nil
+			} else {
+				*d = col.Type.DecodeValue(row.Values[i])
+			}
+			continue
+		}
+		decoded := reflect.ValueOf(col.Type.DecodeValue(row.Values[i]))
+		target := reflect.ValueOf(dest[i])
+		if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Type() != decoded.Type() {
+			return NewScanError(col.Name, NewValueTypeError("*"+col.Type.goType(), fmt.Sprintf("%T", dest[i])))
+		}
+		target.Elem().Set(decoded)
+	}
+	return nil
+}
+
+type resultColumnJSON struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type resultSetJSON struct {
+	Columns []resultColumnJSON  `json:"columns"`
+	Rows    [][]json.RawMessage `json:"rows"`
+}
+
+// Encode the result set as a JSON object holding the name and type of each column, and the rows
+// as arrays of decoded values. Integers and finite floats are written as numbers, while infinite
+// and NaN floats, timestamps and complex numbers are written as strings in the form produced by
+// FormatValue. Values equal to the no-data sentinel of their column are written as null.
+func (rs ResultSet) MarshalJSON() ([]byte, error) {
+	out := resultSetJSON{
+		Columns: make([]resultColumnJSON, len(rs.Columns)),
+		Rows:    make([][]json.RawMessage, len(rs.Rows)),
+	}
+	for i, col := range rs.Columns {
+		out.Columns[i] = resultColumnJSON{Name: col.Name, Type: col.Type.String()}
+	}
+	for i, row := range rs.Rows {
+		out.Rows[i] = make([]json.RawMessage, len(row))
+		for j, val := range row {
+			out.Rows[i][j] = jsonCell(rs.Columns[j], val)
+		}
+	}
+	return json.Marshal(out)
+}
+
+func jsonCell(col Column, val Value) json.RawMessage {
+	if col.IsNoData(val) {
+		return json.RawMessage("null")
+	}
+	text := col.Type.FormatValue(val)
+	switch col.Type {
+	case ColumnTypeFloat32, ColumnTypeFloat64:
+		if f := col.Type.DecodeFloat64(val); math.IsNaN(f) || math.IsInf(f, 0) {
+			return json.RawMessage(strconv.Quote(text))
+		}
+		return json.RawMessage(text)
+	case ColumnTypeTimestamp, ColumnTypeComplex64, ColumnTypeComplex128:
+		return json.RawMessage(strconv.Quote(text))
+	default:
+		return json.RawMessage(text)
+	}
+}
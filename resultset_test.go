@@ -0,0 +1,157 @@
+package pixidb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testResultSet() ResultSet {
+	acquired := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	return ResultSet{
+		Columns: []Column{
+			NewColumnInt16("elevation", 0).WithNoData(NewInt16Value(-9999)),
+			NewColumnFloat32("temperature", 0),
+			NewColumnUint8("class", 0),
+			NewColumnTime("acquired", time.Unix(0, 0)),
+		},
+		Rows: [][]Value{
+			{NewInt16Value(120), NewFloat32Value(21.5), NewUint8Value(3), NewTimeValue(acquired)},
+			{NewInt16Value(-9999), NewFloat32Value(-4.25), NewUint8Value(255), NewTimeValue(acquired.Add(time.Hour))},
+			{NewInt16Value(-30), NewFloat32Value(0), NewUint8Value(0), NewTimeValue(time.Unix(0, 0))},
+		},
+	}
+}
+
+func TestResultSetColumn(t *testing.T) {
+	rs := testResultSet()
+	acquired := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		expect []any
+	}{
+		{"elevation", []any{int16(120), nil, int16(-30)}},
+		{"temperature", []any{float32(21.5), float32(-4.25), float32(0)}},
+		{"class", []any{uint8(3), uint8(255), uint8(0)}},
+		{"acquired", []any{acquired, acquired.Add(time.Hour), time.Unix(0, 0).UTC()}},
+	}
+	for _, tc := range testCases {
+		vals, err := rs.Column(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(vals, tc.expect) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expect, vals)
+		}
+	}
+
+	var notFound *ColumnNotFoundError
+	if _, err := rs.Column("missing"); !errors.As(err, &notFound) {
+		t.Errorf("expected column not found error, got %v", err)
+	}
+}
+
+func TestResultSetFloat64Column(t *testing.T) {
+	rs := testResultSet()
+
+	elevations, err := rs.Float64Column("elevation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elevations[0] != 120 || !math.IsNaN(elevations[1]) || elevations[2] != -30 {
+		t.Errorf("expected elevations [120 NaN -30], got %v", elevations)
+	}
+	classes, err := rs.Float64Column("class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(classes, []float64{3, 255, 0}) {
+		t.Errorf("expected classes [3 255 0], got %v", classes)
+	}
+
+	var notFound *ColumnNotFoundError
+	if _, err := rs.Float64Column("missing"); !errors.As(err, &notFound) {
+		t.Errorf("expected column not found error, got %v", err)
+	}
+}
+
+func TestResultRowScan(t *testing.T) {
+	rs := testResultSet()
+	acquired := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	var elevation int16
+	var temperature float32
+	var class uint8
+	var when time.Time
+	if err := rs.Row(0).Scan(&elevation, &temperature, &class, &when); err != nil {
+		t.Fatal(err)
+	}
+	if elevation != 120 || temperature != 21.5 || class != 3 || !when.Equal(acquired) {
+		t.Errorf("expected 120, 21.5, 3, %v, got %d, %v, %d, %v", acquired, elevation, temperature, class, when)
+	}
+
+	var anyElevation any = "unset"
+	if err := rs.Row(1).Scan(&anyElevation, &temperature, &class, &when); err != nil {
+		t.Fatal(err)
+	}
+	if anyElevation != nil || temperature != -4.25 || class != 255 {
+		t.Errorf("expected nil, -4.25, 255, got %v, %v, %d", anyElevation, temperature, class)
+	}
+
+	var wrong float64
+	err := rs.Row(0).Scan(&elevation, &wrong, &class, &when)
+	var scanErr ScanError
+	var typeErr ValueTypeError
+	if !errors.As(err, &scanErr) || scanErr.Column != "temperature" || !errors.As(err, &typeErr) {
+		t.Errorf("expected a scan type error on temperature, got %v", err)
+	}
+	if err := rs.Row(0).Scan(elevation, &temperature, &class, &when); !errors.As(err, &typeErr) {
+		t.Errorf("expected a type error for a non-pointer destination, got %v", err)
+	}
+
+	var countErr ScanCountError
+	if err := rs.Row(0).Scan(&elevation, &temperature); !errors.As(err, &countErr) || countErr.Expected != 4 || countErr.Actual != 2 {
+		t.Errorf("expected a scan count error for 2 of 4 destinations, got %v", err)
+	}
+}
+
+func TestResultSetMarshalJSON(t *testing.T) {
+	rs := testResultSet()
+	rs.Columns = append(rs.Columns, NewColumnFloat64("ratio", 0), NewColumnComplex64("phase", 0))
+	rs.Rows[0] = append(rs.Rows[0], NewFloat64Value(0.1), NewComplex64Value(1-2i))
+	rs.Rows[1] = append(rs.Rows[1], NewFloat64Value(math.Inf(-1)), NewComplex64Value(0))
+	rs.Rows[2] = append(rs.Rows[2], NewFloat64Value(1e21), NewComplex64Value(0.5i))
+
+	encoded, err := json.Marshal(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"columns":[` +
+		`{"name":"elevation","type":"int16"},` +
+		`{"name":"temperature","type":"float32"},` +
+		`{"name":"class","type":"uint8"},` +
+		`{"name":"acquired","type":"timestamp"},` +
+		`{"name":"ratio","type":"float64"},` +
+		`{"name":"phase","type":"complex64"}],` +
+		`"rows":[` +
+		`[120,21.5,3,"2020-06-01T12:00:00Z",0.1,"(1-2i)"],` +
+		`[null,-4.25,255,"2020-06-01T13:00:00Z","-Inf","(0+0i)"],` +
+		`[-30,0,0,"1970-01-01T00:00:00Z",1e+21,"(0+0.5i)"]]}`
+	if string(encoded) != expect {
+		t.Errorf("expected json\n%s\ngot\n%s", expect, encoded)
+	}
+
+	var decoded struct {
+		Rows [][]any `json:"rows"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Rows[0][0] != float64(120) || decoded.Rows[1][0] != nil {
+		t.Errorf("expected elevations 120 and null to decode, got %v and %v", decoded.Rows[0][0], decoded.Rows[1][0])
+	}
+}
@@ -1,10 +1,18 @@
 package pixidb
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
-	"io"
+	"errors"
+	"hash/crc32"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ColumnProjection struct {
@@ -19,6 +27,24 @@ const (
 	DataFileExt     = ".dat"
 	MetadataFileExt = ".meta.json"
 	MaxPagesInCache = 64
+
+	// The version of the on-disk layout of the metadata and data files written by this package.
+	// Older stores are upgraded by the registered migrations when opened.
+	StoreFormatVersion = 3
+)
+
+// How the values of the rows on each page of the data file of a store are arranged. Rows read
+// and write the same whatever the layout, which only changes how quickly they do.
+type StoreLayout int
+
+const (
+	// Each row is kept whole, one after another, so a row is read or written in one place on its
+	// page. The default, and the best for reading and writing whole rows.
+	RowLayout StoreLayout = iota
+	// The values of each column are kept together, one column after another, so scanning a
+	// column reads its values contiguously rather than striding across the rows. The best for
+	// whole-column scans such as statistics and histograms.
+	ColumnLayout
 )
 
 // A simple set of rows, divided into fixed-size columns. The number of rows and columns both
@@ -28,23 +54,108 @@ const (
 type Store struct {
 	// The name by which the store can be referenced in queries. Also the final folder in the path
 	// in which the data file for this store is kept.
-	Name      string   `json:"-"`
-	ColumnSet []Column `json:"columns"`
-	Rows      int      `json:"rows"`
-	path      string
-	file      *Pagemaster
+	Name          string      `json:"-"`
+	FormatVersion int         `json:"formatVersion"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	ColumnSet     []Column    `json:"columns"`
+	Rows          int         `json:"rows"`
+	PageSize      int         `json:"pageSize"`
+	Layout        StoreLayout `json:"layout,omitempty"`
+	RowChecksums  bool        `json:"rowChecksums,omitempty"`
+	path          string
+	file          *Pagemaster
+	memory        *memoryPageFile // the data of a store kept in memory, nil for stores on disk
+	fileLock      *os.File        // holds the lock on the data file while open for writing, see lockDataFile
+	compressMeta  bool            // whether the metadata file is gzipped
 
 	columnMap   map[string]ColumnProjection // A way to quickly access the data mapping for a particular column name
 	rowSize     int                         // The precomputed size of each row in the store
 	rowsPerPage int                         // The precomputed number of rows in each disk page of the store
+
+	autoLock   sync.Mutex         // Guards the background checkpoint fields below
+	autoCancel context.CancelFunc // Stops the background checkpoint, nil when it is not running
+	autoDone   chan error         // Receives the first background flush error as the background checkpoint exits
+	autoErr    error              // The first background flush error from a background checkpoint that was restarted
+
+	// counters are atomic since rows are read and written concurrently, see Metrics
+	rowsRead    atomic.Int64
+	rowsWritten atomic.Int64
+	checkpoints atomic.Int64
 }
 
 func NewStore(path string, rows int, columns ...Column) (*Store, error) {
-	if len(columns) < 1 {
-		return nil, ErrZeroColumns
+	return NewStorePageSize(path, rows, DefaultPageSize(), columns...)
+}
+
+// Create a new store like NewStore, arranging the values on each page of the data file in the
+// given layout. The layout is kept in the metadata, so the store opens with it again.
+func NewStoreLayout(path string, rows int, layout StoreLayout, columns ...Column) (*Store, error) {
+	return newStore(path, rows, StoreOptions{Layout: layout}, columns)
+}
+
+// Settings of a new store beyond its rows and columns, see NewStoreOptions. The settings are kept
+// in the metadata, so the store opens with them again.
+type StoreOptions struct {
+	PageSize     int         // data bytes in each page of the data file, the host default when zero
+	Layout       StoreLayout // how the values on each page are arranged
+	RowChecksums bool        // whether each row carries its own checksum
+}
+
+// Create a new store like NewStore with the given settings. When rows carry their own checksums,
+// corruption is found row by row rather than page by page: reading a corrupted row returns a
+// RowCorruptedError while the other rows of its page still read, VerifyRows finds the corrupted
+// rows, and Repair resets only those. Each checksum takes four bytes of room on the page, and
+// writing part of a row rewrites the checksum of the whole row. Row checksums cannot be combined
+// with compressed columns.
+func NewStoreOptions(path string, rows int, opts StoreOptions, columns ...Column) (*Store, error) {
+	return newStore(path, rows, opts, columns)
+}
+
+// Create a new store like NewStore, with the given number of data bytes in each page of the data
+// file rather than the default for this host. The page size is kept in the metadata, so the store
+// reads the same on hosts with other memory page sizes. Each page must hold at least one row.
+func NewStorePageSize(path string, rows int, pageSize int, columns ...Column) (*Store, error) {
+	return newStore(path, rows, StoreOptions{PageSize: pageSize}, columns)
+}
+
+// The number of bytes the data file of a store created by NewStorePageSize with the given rows,
+// columns, and page size takes up, so that the disk a large store needs is known before creating
+// it. Returns zero if the columns cannot make a store with pages of the size.
+func EstimateStoreBytes(rows int, columns []Column, pageSize int) int64 {
+	_, rowsPerPage, err := storeLayout(pageSize, columns, false)
+	if err != nil {
+		return 0
+	}
+	return dataFileBytes(rows, rowsPerPage, pageSize)
+}
+
+// The size of the data file of a store with the given rows, each page of which holds the given
+// number of rows in the given number of data bytes following its checksum.
+func dataFileBytes(rows int, rowsPerPage int, pageSize int) int64 {
+	pages := (rows / rowsPerPage) + 1
+	return int64(pages) * int64(pageSize+ChecksumSize)
+}
+
+func newStore(path string, rows int, opts StoreOptions, columns []Column) (*Store, error) {
+	if opts.Layout != RowLayout && opts.Layout != ColumnLayout {
+		return nil, ErrInvalidLayout
+	}
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize()
+	}
+	rowSize, rowsPerPage, err := storeLayout(pageSize, columns, opts.RowChecksums)
+	if err != nil {
+		return nil, err
+	}
+	// fail before writing anything if the data file will not fit
+	if err := checkDiskSpace(path, dataFileBytes(rows, rowsPerPage, pageSize)); err != nil {
+		return nil, err
 	}
 
-	// make sure the directory exists
+	// make sure the directory exists, remembering whether it has to be removed on failure
+	_, statErr := os.Stat(path)
+	created := errors.Is(statErr, os.ErrNotExist)
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		return nil, err
 	}
@@ -53,52 +164,46 @@ func NewStore(path string, rows int, columns ...Column) (*Store, error) {
 	name := filepath.Base(path)
 
 	dataFilePath := filepath.Join(path, name+DataFileExt)
-	pagemaster := NewPagemaster(dataFilePath, MaxPagesInCache)
-
-	// determine the size of the data file and other attributes related to it
-	rowSize := 0
-	defaultRow := make([]byte, 0)
-	for _, c := range columns {
-		rowSize += c.Size()
-		defaultRow = append(defaultRow, c.Default...)
-	}
-	rowsPerPage := pagemaster.PageSize() / rowSize
-	pages := (rows / rowsPerPage) + 1
+	pagemaster := NewPagemasterPageSize(dataFilePath, MaxPagesInCache, pageSize)
+	pagemaster.codec = newColumnGroupCodec(columns, rowsPerPage, opts.Layout)
+	pagemaster.rowCheck = opts.RowChecksums
 
 	// create the metadata file, return early if that fails
 	store := &Store{
-		Name:      name,
-		ColumnSet: columns,
-		file:      pagemaster,
-		path:      path,
-		Rows:      rows,
+		Name:          name,
+		FormatVersion: StoreFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		ColumnSet:     columns,
+		file:          pagemaster,
+		path:          path,
+		Rows:          rows,
+		PageSize:      pageSize,
+		Layout:        opts.Layout,
+		RowChecksums:  opts.RowChecksums,
 
 		columnMap:   nil,
 		rowSize:     rowSize,
 		rowsPerPage: rowsPerPage,
 	}
-	jsonData, err := json.Marshal(store)
-	if err != nil {
-		return nil, err
-	}
-	metaFilePath := filepath.Join(path, name+MetadataFileExt)
-	metaFile, err := os.Create(metaFilePath)
+	fileLock, err := lockDataFile(path, store.file.path, os.O_RDWR|os.O_CREATE)
 	if err != nil {
+		// the files of a store already open for writing elsewhere are left alone
+		if !errors.As(err, &StoreLockedError{}) {
+			removePartialStore(path, name, created)
+		}
 		return nil, err
 	}
-	defer metaFile.Close()
-	if _, err = metaFile.Write(jsonData); err != nil {
+	store.fileLock = fileLock
+	if err := store.saveMetadata(); err != nil {
+		store.unlockDataFile()
+		removePartialStore(path, name, created)
 		return nil, err
 	}
 
 	// create the data file and populate it with the column defaults
-	defaultPage := make([]byte, 0)
-	for i := 0; i < rowsPerPage; i++ {
-		defaultPage = append(defaultPage, defaultRow...)
-	}
-
-	// TODO: check that there is enough disk space here and error out before attempting to create if not
-	if err := pagemaster.Initialize(pages, defaultPage); err != nil {
+	if err := store.initializeData(); err != nil {
+		store.unlockDataFile()
+		removePartialStore(path, name, created)
 		return nil, err
 	}
 
@@ -108,44 +213,381 @@ func NewStore(path string, rows int, columns ...Column) (*Store, error) {
 	return store, nil
 }
 
+// Create a store like NewStore that keeps its data in memory rather than on disk, for tests and
+// scratch data. Checkpoints do nothing, since there is no disk to write to, and dropping the
+// store frees its data. The store has no path and cannot be opened again once closed.
+func NewMemoryStore(name string, rows int, columns ...Column) (*Store, error) {
+	pageSize := DefaultPageSize()
+	rowSize, rowsPerPage, err := storeLayout(pageSize, columns, false)
+	if err != nil {
+		return nil, err
+	}
+	pagemaster, memory := newMemoryPagemaster(MaxPagesInCache, pageSize)
+	pagemaster.codec = newColumnGroupCodec(columns, rowsPerPage, RowLayout)
+	store := &Store{
+		Name:          name,
+		FormatVersion: StoreFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		ColumnSet:     columns,
+		file:          pagemaster,
+		memory:        memory,
+		Rows:          rows,
+		PageSize:      pageSize,
+
+		rowSize:     rowSize,
+		rowsPerPage: rowsPerPage,
+	}
+	if err := store.initializeData(); err != nil {
+		return nil, err
+	}
+	store.columnMap = initColumnMap(columns)
+	return store, nil
+}
+
+// Check the columns and work out the size of each row and how many rows fit on a page.
+func storeLayout(pageSize int, columns []Column, rowChecksums bool) (int, int, error) {
+	if err := validateColumns(columns); err != nil {
+		return 0, 0, err
+	}
+	if rowChecksums && hasCompressedColumns(columns) {
+		return 0, 0, ErrRowChecksumsCompressed
+	}
+	rowSize := 0
+	for _, c := range columns {
+		rowSize += c.Size()
+	}
+	rowsPerPage := pageRows(pageSize, rowSlotSize(rowSize, rowChecksums), columns)
+	if rowsPerPage < 1 {
+		return 0, 0, ErrPageTooSmall
+	}
+	return rowSize, rowsPerPage, nil
+}
+
+// The number of rows taking the given number of bytes each that fit on a page, which has less
+// room for rows when any of the columns are compressed.
+func pageRows(pageSize int, slotSize int, columns []Column) int {
+	if hasCompressedColumns(columns) {
+		pageSize -= compressedPageOverhead
+	}
+	return pageSize / slotSize
+}
+
+// The bytes each row of the given size takes on a page, including its checksum if it has one.
+func rowSlotSize(rowSize int, rowChecksums bool) int {
+	if rowChecksums {
+		return rowSize + ChecksumSize
+	}
+	return rowSize
+}
+
+// Write every page of a new data file, filled with rows of the column defaults.
+func (s *Store) initializeData() error {
+	return s.file.Initialize(s.pages(), s.defaultPage())
+}
+
+// A page filled with rows of the column defaults, arranged in the layout of the store.
+func (s *Store) defaultPage() []byte {
+	defaultRow := s.DefaultRow()
+	page := make([]byte, s.rowsPerPage*s.slotSize())
+	for slot := 0; slot < s.rowsPerPage; slot++ {
+		s.scatterRow(page, slot, defaultRow)
+		s.sealRow(page, slot, defaultRow)
+	}
+	return page
+}
+
+// The bytes each row takes on a page, including its checksum if rows have them.
+func (s *Store) slotSize() int {
+	return rowSlotSize(s.rowSize, s.RowChecksums)
+}
+
+// The offset within its page of the value of the column in the row at the given slot of the page.
+func (s *Store) valueOffset(slot int, column ColumnProjection) int {
+	return layoutOffset(s.Layout, s.rowsPerPage, s.slotSize(), slot, column)
+}
+
+// The offset of the value of the column in the row at the given slot of a page in the layout
+// holding the given number of rows, each taking the given number of bytes.
+func layoutOffset(layout StoreLayout, rowsPerPage int, slotSize int, slot int, column ColumnProjection) int {
+	if layout == ColumnLayout {
+		return rowsPerPage*column.start + slot*column.size
+	}
+	return slot*slotSize + column.start
+}
+
+// The offset within its page of the checksum of the row at the given slot of the page, which
+// follows the values of the row in the row layout, and the values of every row in the column
+// layout.
+func (s *Store) checksumOffset(slot int) int {
+	if s.Layout == ColumnLayout {
+		return s.rowsPerPage*s.rowSize + slot*ChecksumSize
+	}
+	return slot*s.slotSize() + s.rowSize
+}
+
+// The number of bytes at the start of a page holding the given number of its first rows. The
+// rows of the column layout spread across the whole page, however few are used.
+func (s *Store) pageBytes(rows int) int {
+	if s.Layout == ColumnLayout {
+		return s.rowsPerPage * s.slotSize()
+	}
+	return rows * s.slotSize()
+}
+
+// Whether the row at the given slot of the page, already gathered into the row, matches its
+// checksum. Rows are always intact in stores without row checksums.
+func (s *Store) rowIntact(page []byte, slot int, row []byte) bool {
+	if !s.RowChecksums {
+		return true
+	}
+	offset := s.checksumOffset(slot)
+	return binary.BigEndian.Uint32(page[offset:offset+ChecksumSize]) == crc32.ChecksumIEEE(row)
+}
+
+// Write the checksum of the row into the row at the given slot of the page, if rows have them.
+func (s *Store) sealRow(page []byte, slot int, row []byte) {
+	if !s.RowChecksums {
+		return
+	}
+	offset := s.checksumOffset(slot)
+	binary.BigEndian.PutUint32(page[offset:offset+ChecksumSize], crc32.ChecksumIEEE(row))
+}
+
+// Copy the values of the row at the given slot of the page into the row.
+func (s *Store) gatherRow(page []byte, slot int, row []byte) {
+	if s.Layout == RowLayout {
+		start := slot * s.slotSize()
+		copy(row, page[start:start+s.rowSize])
+		return
+	}
+	start := 0
+	for _, c := range s.ColumnSet {
+		size := c.Size()
+		offset := s.rowsPerPage*start + slot*size
+		copy(row[start:start+size], page[offset:offset+size])
+		start += size
+	}
+}
+
+// Copy the values of the row into the row at the given slot of the page.
+func (s *Store) scatterRow(page []byte, slot int, row []byte) {
+	if s.Layout == RowLayout {
+		start := slot * s.slotSize()
+		copy(page[start:start+s.rowSize], row)
+		return
+	}
+	start := 0
+	for _, c := range s.ColumnSet {
+		size := c.Size()
+		offset := s.rowsPerPage*start + slot*size
+		copy(page[offset:offset+size], row[start:start+size])
+		start += size
+	}
+}
+
+// Remove what a failed NewStore or NewTable left behind, the whole directory if it was created
+// for the store or otherwise only the files of the store.
+func removePartialStore(path string, name string, created bool) {
+	if created {
+		os.RemoveAll(path)
+		return
+	}
+	os.Remove(filepath.Join(path, name+DataFileExt))
+	os.Remove(filepath.Join(path, name+MetadataFileExt))
+}
+
+// Open the store in the given directory for reading and writing. Only one handle at a time, in
+// any process, can have a store open for writing, so this errors with a StoreLockedError while
+// the store is open for writing elsewhere, until that handle is closed. The lock is advisory, and
+// is not taken on platforms without advisory file locks.
 func OpenStore(path string) (*Store, error) {
+	return openStore(path, false)
+}
+
+// Open the store in the given directory for reading only, which is allowed while the store is
+// open for writing elsewhere. Writes return ErrReadOnly. Pages are cached by each handle, so
+// writes made through another handle are only seen once they are checkpointed and the page is
+// not already cached here. Stores written in an older format are not upgraded, and error with a
+// FormatVersionError until they are opened for writing.
+func OpenStoreReadOnly(path string) (*Store, error) {
+	return openStore(path, true)
+}
+
+func openStore(path string, readOnly bool) (*Store, error) {
 	// the name of the store is the folder that it is stored in
 	name := filepath.Base(path)
 
-	// create a new paging layer, but no need to initialize it
+	// take the lock before upgrading stores written in an older format, so that only the handle
+	// holding it rewrites their metadata, then read from the metadata file
+	metaFilePath := filepath.Join(path, name+MetadataFileExt)
 	dataFilePath := filepath.Join(path, name+DataFileExt)
-	pagemaster := NewPagemaster(dataFilePath, MaxPagesInCache)
+	var fileLock *os.File
+	if !readOnly {
+		var err error
+		fileLock, err = lockDataFile(path, dataFilePath, os.O_RDWR)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrateStore(path, name, metaFilePath); err != nil {
+			fileLock.Close()
+			return nil, err
+		}
+	}
+	store, err := readStore(name, path, metaFilePath)
+	if err != nil {
+		if fileLock != nil {
+			fileLock.Close()
+		}
+		return nil, err
+	}
+	store.fileLock = fileLock
 
-	// read from the metadata file first
-	metaFilePath := filepath.Join(path, name+MetadataFileExt)
-	metaFile, err := os.Open(metaFilePath)
+	// create a new paging layer with the page size the data file was written with
+	store.file = NewPagemasterPageSize(dataFilePath, MaxPagesInCache, store.PageSize)
+	store.file.codec = newColumnGroupCodec(store.ColumnSet, store.rowsPerPage, store.Layout)
+	store.file.rowCheck = store.RowChecksums
+	if readOnly {
+		store.file.readOnly = true
+		return store, nil
+	}
+	if err := store.restoreTail(); err != nil {
+		store.unlockDataFile()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Read the store from its metadata file.
+func readStore(name string, path string, metaFilePath string) (*Store, error) {
+	jsonText, compressed, err := readMetadataFile(metaFilePath)
 	if err != nil {
 		return nil, err
 	}
-	defer metaFile.Close()
+	store, err := unmarshalStore(name, path, jsonText)
+	if err != nil {
+		return nil, err
+	}
+	store.compressMeta = compressed
+	return store, nil
+}
 
-	jsonText, err := io.ReadAll(metaFile)
+// Returned by lockFile when another open file holds the lock.
+var errFileLocked = errors.New("file is locked")
+
+// Take the advisory lock on the data file of the store at the path, opened with the given flags,
+// so that no other handle can open the store for writing until the returned file is closed. Only
+// new stores create the data file, so opening a store without one fails. Errors with a
+// StoreLockedError if another handle holds the lock.
+func lockDataFile(path string, dataFilePath string, flag int) (*os.File, error) {
+	file, err := os.OpenFile(dataFilePath, flag, 0666)
 	if err != nil {
 		return nil, err
 	}
-	store := &Store{Name: name, file: pagemaster}
-	err = json.Unmarshal(jsonText, store)
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, errFileLocked) {
+			return nil, NewStoreLockedError(path)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// Release the lock on the data file, if it is held.
+func (s *Store) unlockDataFile() {
+	if s.fileLock != nil {
+		s.fileLock.Close()
+		s.fileLock = nil
+	}
+}
+
+// Rebuild the last page holding rows of a data file cut short after its last row, as left by a
+// failed resize or a partial copy, filling the rest of the page with the column defaults. A
+// missing unused page after it is valid, as left by Compact, and is added back by Grow when
+// needed. A file cut short before the end of its last row is left as it is for Verify and Repair
+// to find, as is one whose pages are compressed, which cannot be filled in part.
+func (s *Store) restoreTail() error {
+	info, err := os.Stat(s.file.path)
 	if err != nil {
+		return err
+	}
+	slotSize := int64(s.PageSize + ChecksumSize)
+	lastPage := s.livePages() - 1
+	if lastPage < 0 || info.Size() >= int64(lastPage+1)*slotSize {
+		return nil
+	}
+
+	start := int64(lastPage)*slotSize + int64(ChecksumSize)
+	rowsEnd := start + int64(s.pageBytes(s.Rows-lastPage*s.rowsPerPage))
+	if s.file.codec != nil || info.Size() < rowsEnd {
+		return nil
+	}
+	page := make([]byte, s.PageSize)
+	copy(page, s.defaultPage())
+	file, err := os.Open(s.file.path)
+	if err != nil {
+		return err
+	}
+	_, err = file.ReadAt(page[:info.Size()-start], start)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	_, err = s.file.RepairPage(lastPage, page)
+	return err
+}
+
+// Read a store from the text of its metadata file, leaving the caller to set up the paging layer
+// over its data file. Errors if the metadata is in an older format.
+func unmarshalStore(name string, path string, jsonText []byte) (*Store, error) {
+	store := &Store{Name: name, path: path}
+	if err := json.Unmarshal(jsonText, store); err != nil {
 		return nil, err
 	}
+	if store.FormatVersion != StoreFormatVersion {
+		return nil, NewFormatVersionError(name, store.FormatVersion, StoreFormatVersion)
+	}
+	if store.Layout != RowLayout && store.Layout != ColumnLayout {
+		return nil, ErrInvalidLayout
+	}
 
 	// determine the size of the data file and other attributes related to it
 	store.rowSize = 0
 	for _, c := range store.ColumnSet {
 		store.rowSize += c.Size()
 	}
-	store.rowsPerPage = pagemaster.PageSize() / store.rowSize
+	if store.RowChecksums && hasCompressedColumns(store.ColumnSet) {
+		return nil, ErrRowChecksumsCompressed
+	}
+	store.rowsPerPage = pageRows(store.PageSize, store.slotSize(), store.ColumnSet)
+	if store.rowsPerPage < 1 {
+		return nil, ErrPageTooSmall
+	}
 
 	// lastly, map the columns to their projection indices in the column list
 	store.columnMap = initColumnMap(store.ColumnSet)
 	return store, nil
 }
 
+// Check that the columns can make up the rows of a store, which needs at least one column and
+// a distinct, valid name for each.
+func validateColumns(columns []Column) error {
+	if len(columns) < 1 {
+		return ErrZeroColumns
+	}
+	seen := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if !ValidColumnName(c.Name) {
+			return NewInvalidColumnNameError(c.Name)
+		}
+		if seen[c.Name] {
+			return NewDuplicateColumnError(c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
 func initColumnMap(columns []Column) map[string]ColumnProjection {
 	columnMap := make(map[string]ColumnProjection)
 	columnOffset := 0
@@ -156,6 +598,33 @@ func initColumnMap(columns []Column) map[string]ColumnProjection {
 	return columnMap
 }
 
+// Save the store metadata alongside the data file. Stores in memory have nowhere to save it.
+func (s *Store) saveMetadata() error {
+	if s.memory != nil {
+		return nil
+	}
+	if s.file.readOnly {
+		return ErrReadOnly
+	}
+	jsonData, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeMetadataFile(filepath.Join(s.path, s.Name+MetadataFileExt), jsonData, s.compressMeta)
+}
+
+// Choose whether the metadata file of the store is gzipped, rewriting it in the chosen form.
+// Both forms are read when opening a store, which keeps the form it was opened with.
+func (s *Store) SetMetadataCompression(compress bool) error {
+	previous := s.compressMeta
+	s.compressMeta = compress
+	if err := s.saveMetadata(); err != nil {
+		s.compressMeta = previous
+		return err
+	}
+	return nil
+}
+
 func (s *Store) Path() string {
 	return s.path
 }
@@ -184,40 +653,917 @@ func (s *Store) FilterColumns(proj Projection) []Column {
 	return columns
 }
 
+// Ensure the row index falls within the rows of the store, so that a bad index can never
+// read or write an arbitrary page of the data file.
+func (s *Store) checkIndex(index int) error {
+	if index < 0 || index >= s.Rows {
+		return NewIndexOutOfRangeError(s.Name, index, s.Rows)
+	}
+	return nil
+}
+
 func (s *Store) GetRowAt(index int) (Row, error) {
-	pageIndex := index / s.rowsPerPage
-	rowOffset := (index % s.rowsPerPage) * s.rowSize
-	return s.file.GetChunk(pageIndex, rowOffset, s.rowSize)
+	if err := s.checkIndex(index); err != nil {
+		return nil, err
+	}
+	pageIndex, slot := index/s.rowsPerPage, index%s.rowsPerPage
+	if s.Layout == RowLayout && !s.RowChecksums {
+		row, err := s.file.GetChunk(pageIndex, slot*s.rowSize, s.rowSize)
+		if err != nil {
+			return nil, err
+		}
+		s.rowsRead.Add(1)
+		return row, nil
+	}
+	row := make(Row, s.rowSize)
+	intact := true
+	err := s.file.ViewChunk(pageIndex, 0, s.pageBytes(slot+1), func(page []byte) {
+		s.gatherRow(page, slot, row)
+		intact = s.rowIntact(page, slot, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !intact {
+		return nil, NewRowCorruptedError(s.file.path, index)
+	}
+	s.rowsRead.Add(1)
+	return row, nil
+}
+
+// The positions of the given indices, ordered by the page holding each index and otherwise kept
+// in order, so that rows can be visited a page at a time. Errors if any index is out of range.
+func (s *Store) pageOrder(indices []int) ([]int, error) {
+	for _, index := range indices {
+		if err := s.checkIndex(index); err != nil {
+			return nil, err
+		}
+	}
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return indices[a]/s.rowsPerPage - indices[b]/s.rowsPerPage
+	})
+	return order, nil
+}
+
+// Retrieve the rows at each of the given indices, returned in the same order as the indices.
+// All indices are validated before any reads happen, and the reads themselves are grouped by
+// page so each page only needs to be brought into the cache once.
+func (s *Store) GetRowsAt(indices []int) ([]Row, error) {
+	order, err := s.pageOrder(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, len(indices))
+	for _, i := range order {
+		row, err := s.GetRowAt(indices[i])
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+	return rows, nil
 }
 
-// Cheat method when a store has only a single column and we don't need
-// to do any projection (because it's the only column)
+// Read only the bytes of a single column of the row at the given index.
+func (s *Store) getColumnAt(index int, column ColumnProjection) (Value, error) {
+	if err := s.checkIndex(index); err != nil {
+		return nil, err
+	}
+	if s.RowChecksums {
+		// the whole row is needed to check its checksum
+		row, err := s.GetRowAt(index)
+		if err != nil {
+			return nil, err
+		}
+		return Value(row[column.start : column.start+column.size]), nil
+	}
+	pageIndex, slot := index/s.rowsPerPage, index%s.rowsPerPage
+	val, err := s.file.GetChunk(pageIndex, s.valueOffset(slot, column), column.size)
+	if err != nil {
+		return nil, err
+	}
+	s.rowsRead.Add(1)
+	return val, nil
+}
+
+// Read only the bytes of a single column of the rows at each of the indices, returned in the same
+// order as the indices. The reads are grouped by page as in GetRowsAt.
+func (s *Store) getColumnsAt(indices []int, column ColumnProjection) ([]Value, error) {
+	order, err := s.pageOrder(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make([]Value, len(indices))
+	for _, i := range order {
+		val, err := s.getColumnAt(indices[i], column)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+// Read the whole row at the index as a single value, for stores with only a single column, where
+// no projection is needed. Use GetColumnValueAt to read a single column of a wider row.
 func (s *Store) GetValueAt(index int) (Value, error) {
-	pageIndex := index / s.rowsPerPage
-	rowOffset := (index % s.rowsPerPage) * s.rowSize
-	return s.file.GetChunk(pageIndex, rowOffset, s.rowSize)
+	row, err := s.GetRowAt(index)
+	if err != nil {
+		return nil, err
+	}
+	return Value(row), nil
+}
+
+// Read the value of the named column in the row at the index, without reading the rest of the row.
+func (s *Store) GetColumnValueAt(column string, index int) (Value, error) {
+	proj, err := s.Projection(column)
+	if err != nil {
+		return nil, err
+	}
+	return s.getColumnAt(index, proj[0])
 }
 
 func (s *Store) SetRowAt(index int, row Row) error {
-	pageIndex := index / s.rowsPerPage
-	rowOffset := (index % s.rowsPerPage) * s.rowSize
-	return s.file.SetChunk(pageIndex, rowOffset, row)
+	if err := s.checkIndex(index); err != nil {
+		return err
+	}
+	if err := s.writeRow(index, row); err != nil {
+		return err
+	}
+	s.rowsWritten.Add(1)
+	return nil
+}
+
+// Write the row at the index, which may lie past the rows of the store on the pages of the data
+// file, arranging its values in the layout of the store and sealing it with its checksum.
+func (s *Store) writeRow(index int, row Row) error {
+	pageIndex, slot := index/s.rowsPerPage, index%s.rowsPerPage
+	if s.Layout == RowLayout && !s.RowChecksums {
+		return s.file.SetChunk(pageIndex, slot*s.rowSize, row)
+	}
+	return s.file.ModifyChunk(pageIndex, 0, s.pageBytes(slot+1), func(page []byte) {
+		s.scatterRow(page, slot, row)
+		s.sealRow(page, slot, row)
+	})
+}
+
+// Write the default value of every column into the row at the given index.
+func (s *Store) ResetRowAt(index int) error {
+	return s.SetRowAt(index, s.DefaultRow())
+}
+
+// Atomically reads, modifies, and writes back the row at the given index. The modify
+// function receives the row as it currently exists in the store and may update it in place.
+// No other reads or writes to rows on the same page will interleave with the modification.
+// A corrupted row is left as it is, without calling modify.
+func (s *Store) ModifyRowAt(index int, modify func(Row)) error {
+	if err := s.checkIndex(index); err != nil {
+		return err
+	}
+	pageIndex, slot := index/s.rowsPerPage, index%s.rowsPerPage
+	if s.Layout == RowLayout && !s.RowChecksums {
+		err := s.file.ModifyChunk(pageIndex, slot*s.rowSize, s.rowSize, func(chunk []byte) {
+			modify(Row(chunk))
+		})
+		if err != nil {
+			return err
+		}
+		s.rowsWritten.Add(1)
+		return nil
+	}
+	intact := true
+	err := s.file.ModifyChunk(pageIndex, 0, s.pageBytes(slot+1), func(page []byte) {
+		row := make(Row, s.rowSize)
+		s.gatherRow(page, slot, row)
+		if intact = s.rowIntact(page, slot, row); !intact {
+			return
+		}
+		modify(row)
+		s.scatterRow(page, slot, row)
+		s.sealRow(page, slot, row)
+	})
+	if err != nil {
+		return err
+	}
+	if !intact {
+		return NewRowCorruptedError(s.file.path, index)
+	}
+	s.rowsWritten.Add(1)
+	return nil
+}
+
+// Modify the rows at each of the given indices as in ModifyRowAt, passing modify the position of
+// the index among the indices. All indices are validated before any row is modified, and the
+// rows are modified grouped by page so each page only needs to be brought into the cache once.
+// Returns the number of rows modified, which on error may be any of them.
+func (s *Store) modifyRowsAt(indices []int, modify func(i int, row Row)) (int, error) {
+	order, err := s.pageOrder(indices)
+	if err != nil {
+		return 0, err
+	}
+
+	for modified, i := range order {
+		err := s.ModifyRowAt(indices[i], func(row Row) {
+			modify(i, row)
+		})
+		if err != nil {
+			return modified, err
+		}
+	}
+	return len(indices), nil
 }
 
+// Write the value of the named column in the row at the index, leaving the rest of the row as it
+// is. The write holds the page lock throughout, so concurrent writes to other columns of the same
+// row are never lost. Returns a ValueSizeError if the value is not the size of the column.
 func (s *Store) SetValueAt(column string, index int, val Value) error {
-	pageIndex := index / s.rowsPerPage
-	rowOffset := (index % s.rowsPerPage) * s.rowSize
-	columnOffset := rowOffset + s.columnMap[column].start
-	return s.file.SetChunk(pageIndex, columnOffset, val)
+	if err := s.checkIndex(index); err != nil {
+		return err
+	}
+	proj, err := s.Projection(column)
+	if err != nil {
+		return err
+	}
+	if len(val) != proj[0].size {
+		return NewValueSizeError(column, len(val), proj[0].size)
+	}
+	if s.RowChecksums {
+		// the checksum of the whole row changes with the value
+		return s.ModifyRowAt(index, func(row Row) {
+			copy(row[proj[0].start:], val)
+		})
+	}
+	pageIndex, slot := index/s.rowsPerPage, index%s.rowsPerPage
+	err = s.file.UpdateChunk(pageIndex, s.valueOffset(slot, proj[0]), func([]byte) []byte {
+		return val
+	})
+	if err != nil {
+		return err
+	}
+	s.rowsWritten.Add(1)
+	return nil
+}
+
+// Write every dirty page to the data file, returning the number of pages written, including
+// those written before an error. Does nothing for stores in memory.
+func (s *Store) Checkpoint() (int, error) {
+	return s.CheckpointCtx(context.Background())
+}
+
+// Same as Checkpoint, but stops early with the context error if the context is cancelled.
+func (s *Store) CheckpointCtx(ctx context.Context) (int, error) {
+	if s.memory != nil {
+		return 0, nil
+	}
+	written, err := s.file.FlushAllPagesCtx(ctx)
+	if err != nil {
+		return written, err
+	}
+	s.checkpoints.Add(1)
+	return written, nil
+}
+
+// Write the dirty pages holding the rows from the start index up to, but not including, the end
+// index to disk, leaving the dirty pages of other rows in the cache. Syncs according to the sync
+// mode as Checkpoint does, making a checkpoint of a region that was just updated quicker than
+// one of the whole store.
+func (s *Store) FlushRange(startIndex int, endIndex int) error {
+	if startIndex > endIndex {
+		return NewIndexOutOfRangeError(s.Name, startIndex, endIndex)
+	}
+	if startIndex == endIndex {
+		return nil
+	}
+	if err := s.checkIndex(startIndex); err != nil {
+		return err
+	}
+	if err := s.checkIndex(endIndex - 1); err != nil {
+		return err
+	}
+	if s.memory != nil {
+		return nil
+	}
+	pages := make([]int, 0, (endIndex-1)/s.rowsPerPage-startIndex/s.rowsPerPage+1)
+	for page := startIndex / s.rowsPerPage; page <= (endIndex-1)/s.rowsPerPage; page++ {
+		pages = append(pages, page)
+	}
+	return s.file.FlushPages(pages)
+}
+
+// Same as Checkpoint, but always syncs the data file to the disk regardless of the sync mode,
+// so that every write to the store is durable once it returns.
+func (s *Store) CheckpointDurable() (int, error) {
+	if s.memory != nil {
+		return 0, nil
+	}
+	written, err := s.file.FlushAllPagesDurableCtx(context.Background())
+	if err != nil {
+		return written, err
+	}
+	s.checkpoints.Add(1)
+	return written, nil
+}
+
+// Change when the store syncs written pages to the disk, see SyncMode.
+func (s *Store) SetSyncMode(mode SyncMode) {
+	s.file.SetSyncMode(mode)
+}
+
+// Start flushing dirty pages to disk in the background every interval, so that explicit
+// checkpoints have less to write. Pages are flushed one at a time, so writers only wait on
+// the page being written rather than on the whole flush, and background flushes interleave
+// safely with explicit checkpoints. If the background checkpoint is already running, it is
+// restarted with the new interval. An interval of zero or less stops the background checkpoint
+// instead, keeping any errors it encountered for StopAutoCheckpoint.
+func (s *Store) StartAutoCheckpoint(interval time.Duration) {
+	s.autoLock.Lock()
+	defer s.autoLock.Unlock()
+	if s.autoCancel != nil {
+		s.autoErr = errors.Join(s.autoErr, s.stopAutoCheckpoint())
+	}
+	if interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	s.autoCancel = cancel
+	s.autoDone = done
+	go func() {
+		var firstErr error
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				done <- firstErr
+				return
+			case <-ticker.C:
+				err := s.file.FlushDirtyPagesCtx(ctx)
+				if err == nil {
+					s.checkpoints.Add(1)
+				} else if !errors.Is(err, context.Canceled) && firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}()
+}
+
+// Stop the background checkpoint and wait for any flush in progress to finish, returning the
+// first error encountered by a background flush. Pages left dirty are written by the next
+// checkpoint. Does nothing if the background checkpoint is not running.
+func (s *Store) StopAutoCheckpoint() error {
+	s.autoLock.Lock()
+	defer s.autoLock.Unlock()
+	err := errors.Join(s.autoErr, s.stopAutoCheckpoint())
+	s.autoErr = nil
+	return err
 }
 
-func (s *Store) Checkpoint() error {
-	return s.file.FlushAllPages()
+func (s *Store) stopAutoCheckpoint() error {
+	if s.autoCancel == nil {
+		return nil
+	}
+	s.autoCancel()
+	err := <-s.autoDone
+	s.autoCancel = nil
+	s.autoDone = nil
+	return err
+}
+
+// Flush the store to disk and close it, after which reads and writes return ErrClosed. Closing
+// an already closed store does nothing. The background checkpoint is stopped, and any errors
+// it encountered are superseded by the final flush. If the flush fails the store stays open for
+// writing, keeping its lock, so that the close can be retried.
+func (s *Store) Close() error {
+	s.StopAutoCheckpoint()
+	if err := s.file.Close(); err != nil {
+		// the lock is kept along with the unflushed pages, until the close is retried
+		return err
+	}
+	s.unlockDataFile()
+	return nil
 }
 
+// Removes a directory and everything in it, replaceable so that tests can simulate a failure.
+var removeAll = os.RemoveAll
+
+// Remove the directory of the store along with its files, or free the data of a store in memory.
+// The cache is only emptied once the files are gone, so that the store remains usable if they
+// could not be removed.
 func (s *Store) Drop() error {
+	if s.file.readOnly {
+		return ErrReadOnly
+	}
+	s.StopAutoCheckpoint()
+	if s.memory != nil {
+		s.file.ClearCache()
+		s.memory.release()
+		return nil
+	}
+	if err := removeAll(s.path); err != nil {
+		return err
+	}
 	s.file.ClearCache()
-	return os.RemoveAll(s.path)
+	s.unlockDataFile()
+	return nil
+}
+
+// How Repair rewrites the rows of a corrupted page.
+type RepairMode int
+
+const (
+	// Reset every row on a corrupted page to the column defaults.
+	RepairResetDefault RepairMode = iota
+	// Fill every row on a corrupted page with zeroes.
+	RepairZero
+)
+
+type RepairOptions struct {
+	Mode    RepairMode
+	Workers int // goroutines checking page checksums, one per processor when zero
+}
+
+// A page that Repair found corrupted and rewrote, along with the rows of the store it holds.
+type RepairedPage struct {
+	Page      int
+	FirstRow  int
+	Rows      int
+	FromCache bool // the page was restored from an intact copy in the cache rather than rewritten
+}
+
+// A row that Repair found corrupted and rewrote, in stores whose rows carry checksums.
+type RepairedRow struct {
+	Row       int
+	FromCache bool // the row was restored from an intact copy in the cache rather than rewritten
+}
+
+type RepairReport struct {
+	Pages []RepairedPage
+	Rows  []RepairedRow // the repaired rows of stores whose rows carry checksums, instead of pages
+}
+
+// The number of pages in the data file of the store.
+func (s *Store) pages() int {
+	return (s.Rows / s.rowsPerPage) + 1
+}
+
+// The number of pages holding rows, one fewer than pages when the rows fill their last page
+// exactly, in which case the last page of the data file is unused until the store grows.
+func (s *Store) livePages() int {
+	return (s.Rows + s.rowsPerPage - 1) / s.rowsPerPage
+}
+
+// Cut the unused page off the end of the data file, if there is one, so the file holds only the
+// pages holding rows. The pages cut off start past the last row, so no rows are lost, and the
+// store grows again as usual afterward.
+func (s *Store) Compact() error {
+	return s.file.Truncate(s.livePages())
+}
+
+// Call the visit function with the value of the column in every row, in storage order, reading
+// each page of the data file once. Only the values of the column are copied from each page when
+// they are kept together by the column layout and rows have no checksums to check.
+func (s *Store) forEachValue(column ColumnProjection, visit func(index int, val Value)) error {
+	if s.Layout == ColumnLayout && !s.RowChecksums {
+		region := func(rows int) (int, int) {
+			return s.rowsPerPage * column.start, rows * column.size
+		}
+		return s.scanRegions(region, func(first int, rows int, chunk []byte) error {
+			for r := 0; r < rows; r++ {
+				visit(first+r, Value(chunk[r*column.size:(r+1)*column.size]))
+			}
+			return nil
+		})
+	}
+	return s.scanPages(func(first int, rows int, chunk []byte) error {
+		for r := 0; r < rows; r++ {
+			if err := s.checkRow(chunk, r, first+r); err != nil {
+				return err
+			}
+			start := s.valueOffset(r, column)
+			visit(first+r, Value(chunk[start:start+column.size]))
+		}
+		return nil
+	})
+}
+
+// Return a RowCorruptedError for the row at the given index if it does not match its checksum
+// at the given slot of the page.
+func (s *Store) checkRow(page []byte, slot int, index int) error {
+	if !s.RowChecksums {
+		return nil
+	}
+	row := make(Row, s.rowSize)
+	s.gatherRow(page, slot, row)
+	if !s.rowIntact(page, slot, row) {
+		return NewRowCorruptedError(s.file.path, index)
+	}
+	return nil
+}
+
+// Call the visit function with every row, in storage order, reading each page of the data file
+// once. Pages are read ahead in the background while earlier rows are visited when a prefetch
+// depth is set, see SetPrefetchDepth. The rows are copies and may be retained. Stops at the
+// first error returned by visit.
+func (s *Store) ScanRows(visit func(index int, row Row) error) error {
+	return s.scanPages(func(first int, rows int, chunk []byte) error {
+		for r := 0; r < rows; r++ {
+			row := Row(chunk[r*s.rowSize : (r+1)*s.rowSize : (r+1)*s.rowSize])
+			if s.Layout == ColumnLayout || s.RowChecksums {
+				row = make(Row, s.rowSize)
+				s.gatherRow(chunk, r, row)
+			}
+			if !s.rowIntact(chunk, r, row) {
+				return NewRowCorruptedError(s.file.path, first+r)
+			}
+			if err := visit(first+r, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Change how many pages sequential scans of the store read ahead, see Pagemaster.SetPrefetchDepth.
+func (s *Store) SetPrefetchDepth(depth int) {
+	s.file.SetPrefetchDepth(depth)
+}
+
+// Call the visit function with a copy of the rows of every page holding rows, in storage order,
+// reading ahead of the visited page when a prefetch depth is set. Read-ahead is best effort, so
+// its errors are left for the read of the page itself to report.
+func (s *Store) scanPages(visit func(first int, rows int, chunk []byte) error) error {
+	return s.scanRegions(func(rows int) (int, int) { return 0, s.pageBytes(rows) }, visit)
+}
+
+// Same as scanPages, copying only the region of each page at the offset and of the size given
+// by the region function for the number of rows on the page.
+func (s *Store) scanRegions(region func(rows int) (int, int), visit func(first int, rows int, chunk []byte) error) error {
+	lastPage := (s.Rows - 1) / s.rowsPerPage
+	var ahead chan int
+	if depth := s.file.PrefetchDepth(); depth > 0 && lastPage > 0 {
+		ahead = make(chan int, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for page := range ahead {
+				s.file.Prefetch(page, min(depth, lastPage-page))
+			}
+		}()
+		defer func() {
+			close(ahead)
+			<-done
+		}()
+	}
+
+	for page := 0; page*s.rowsPerPage < s.Rows; page++ {
+		first := page * s.rowsPerPage
+		rows := min(s.rowsPerPage, s.Rows-first)
+		offset, size := region(rows)
+		chunk, err := s.file.GetChunk(page, offset, size)
+		if err != nil {
+			return err
+		}
+		if ahead != nil && page < lastPage {
+			// skip the signal if the read-ahead is still busy, it catches up from a later page
+			select {
+			case ahead <- page:
+			default:
+			}
+		}
+		if err := visit(first, rows, chunk); err != nil {
+			return err
+		}
+		s.rowsRead.Add(int64(rows))
+	}
+	return nil
+}
+
+// Apply the modify function to every row, in storage order, updating each page of the data file
+// in place while holding the page lock once. The row passed to modify aliases the page, so it
+// must not be retained. Stops at the first error returned by modify, leaving the rows before it
+// updated.
+func (s *Store) modifyEachRow(modify func(index int, row Row) error) error {
+	for page := 0; page*s.rowsPerPage < s.Rows; page++ {
+		first := page * s.rowsPerPage
+		rows := min(s.rowsPerPage, s.Rows-first)
+		var modifyErr error
+		err := s.file.ModifyChunk(page, 0, s.pageBytes(rows), func(chunk []byte) {
+			if s.Layout == RowLayout && !s.RowChecksums {
+				for r := 0; r < rows && modifyErr == nil; r++ {
+					modifyErr = modify(first+r, Row(chunk[r*s.rowSize:(r+1)*s.rowSize]))
+				}
+				return
+			}
+			row := make(Row, s.rowSize)
+			for r := 0; r < rows && modifyErr == nil; r++ {
+				s.gatherRow(chunk, r, row)
+				if !s.rowIntact(chunk, r, row) {
+					modifyErr = NewRowCorruptedError(s.file.path, first+r)
+					return
+				}
+				modifyErr = modify(first+r, row)
+				s.scatterRow(chunk, r, row)
+				s.sealRow(chunk, r, row)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if modifyErr != nil {
+			return modifyErr
+		}
+		s.rowsWritten.Add(int64(rows))
+	}
+	return nil
+}
+
+// Replace the value of the column in every row with the result of the function applied to it,
+// working through the data file a page at a time. The function must return a value the size of
+// the column, otherwise a ValueSizeError is returned and the rows before it remain updated.
+func (s *Store) MapColumn(column string, fn func(Value) Value) error {
+	proj, err := s.Projection(column)
+	if err != nil {
+		return err
+	}
+	start, size := proj[0].start, proj[0].size
+	return s.modifyEachRow(func(index int, row Row) error {
+		result := fn(slices.Clone(Value(row[start : start+size])))
+		if len(result) != size {
+			return NewValueSizeError(column, len(result), size)
+		}
+		copy(row[start:], result)
+		return nil
+	})
+}
+
+// Compute the value of the out column in every row from the values of columns a and b in that
+// row, working through the data file a page at a time. The function must return a value the size
+// of the out column, otherwise a ValueSizeError is returned and the rows before it remain updated.
+func (s *Store) Combine(out string, a string, b string, fn func(av Value, bv Value) Value) error {
+	proj, err := s.Projection(out, a, b)
+	if err != nil {
+		return err
+	}
+	outProj, aProj, bProj := proj[0], proj[1], proj[2]
+	return s.modifyEachRow(func(index int, row Row) error {
+		av := slices.Clone(Value(row[aProj.start : aProj.start+aProj.size]))
+		bv := slices.Clone(Value(row[bProj.start : bProj.start+bProj.size]))
+		result := fn(av, bv)
+		if len(result) != outProj.size {
+			return NewValueSizeError(out, len(result), outProj.size)
+		}
+		copy(row[outProj.start:], result)
+		return nil
+	})
+}
+
+// Tally the values of the column into the given number of equal width bins spanning min to max,
+// returning the count of each bin. The max value falls into the last bin. Values outside of the
+// range, NaN values, and values matching the no-data sentinel of the column are not counted.
+func (s *Store) Histogram(column string, bins int, min float64, max float64) ([]int, error) {
+	if bins < 1 || !(min < max) {
+		return nil, ErrInvalidBins
+	}
+	proj, err := s.Projection(column)
+	if err != nil {
+		return nil, err
+	}
+	col := s.FilterColumns(proj)[0]
+
+	counts := make([]int, bins)
+	width := (max - min) / float64(bins)
+	err = s.forEachValue(proj[0], func(index int, val Value) {
+		if col.IsNoData(val) {
+			return
+		}
+		f := col.Type.DecodeFloat64(val)
+		if !(f >= min && f <= max) {
+			return
+		}
+		bin := int((f - min) / width)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Extend the store to hold the given number of rows filled with the column defaults, appending
+// pages to the data file as needed. Stores never shrink, so this does nothing if the store already
+// holds at least that many rows. The new rows are flushed to disk before the row count is saved.
+func (s *Store) Grow(newRows int) error {
+	if newRows <= s.Rows {
+		return nil
+	}
+
+	// the end of the last page is padding that isn't guaranteed to hold the defaults
+	defaultRow := s.DefaultRow()
+	lastPageEnd := min(newRows, s.livePages()*s.rowsPerPage)
+	for r := s.Rows; r < lastPageEnd; r++ {
+		if err := s.writeRow(r, defaultRow); err != nil {
+			return err
+		}
+	}
+	if _, err := s.file.FlushAllPages(); err != nil {
+		return err
+	}
+
+	oldRows, oldPages := s.Rows, s.livePages()
+	s.Rows = newRows
+	if err := s.file.Extend(oldPages, s.pages(), s.defaultPage()); err != nil {
+		s.Rows = oldRows
+		return err
+	}
+	return s.saveMetadata()
+}
+
+// Read every page of the data file holding rows from disk and check its checksum, returning the
+// indices of all corrupted pages. Only reports corruption, see Repair to fix it. Errors other than
+// corruption, such as failing to open the file, stop the scan. Checksums are checked by one
+// goroutine per processor, see VerifyWorkers.
+func (s *Store) Verify() ([]int, error) {
+	return s.VerifyWorkers(runtime.GOMAXPROCS(0))
+}
+
+// Same as Verify, checking checksums with the given number of goroutines while the pages are
+// read in order. The corrupted pages are reported in increasing order however many are used.
+// When rows carry checksums, the pages holding corrupted rows are reported, see VerifyRows.
+func (s *Store) VerifyWorkers(workers int) ([]int, error) {
+	if !s.RowChecksums {
+		return s.file.VerifyPages(0, s.livePages(), workers)
+	}
+	rows, err := s.VerifyRows()
+	if err != nil {
+		return nil, err
+	}
+	pages := []int{}
+	for _, row := range rows {
+		if page := row / s.rowsPerPage; len(pages) == 0 || pages[len(pages)-1] != page {
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// Read every page of the data file holding rows from disk and check the checksum of each row,
+// returning the indices of the corrupted rows in increasing order. Every row of a page cut short
+// is corrupted. When rows do not carry checksums, every row of each corrupted page is reported.
+func (s *Store) VerifyRows() ([]int, error) {
+	rows := []int{}
+	if !s.RowChecksums {
+		pages, err := s.Verify()
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			for r := page * s.rowsPerPage; r < min((page+1)*s.rowsPerPage, s.Rows); r++ {
+				rows = append(rows, r)
+			}
+		}
+		return rows, nil
+	}
+	rows, _, err := s.verifyRowChecksums()
+	return rows, err
+}
+
+// Check the checksum of every row on disk, returning the corrupted rows in increasing order along
+// with the pages that were cut short.
+func (s *Store) verifyRowChecksums() ([]int, map[int]bool, error) {
+	rows := []int{}
+	short := map[int]bool{}
+	row := make(Row, s.rowSize)
+	err := s.file.ReadPages(0, s.livePages(), func(pageIndex int, page []byte) error {
+		first := pageIndex * s.rowsPerPage
+		if page == nil {
+			short[pageIndex] = true
+		}
+		for r := 0; r < min(s.rowsPerPage, s.Rows-first); r++ {
+			if page != nil {
+				s.gatherRow(page, r, row)
+			}
+			if page == nil || !s.rowIntact(page, r, row) {
+				rows = append(rows, first+r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, short, nil
+}
+
+// Scan every page of the data file for corruption, rewriting each corrupted page according to
+// the repair mode so that the rest of the store remains usable. If a corrupted page is still in
+// the cache, the cached copy is written back instead, and no data is lost. The report lists the
+// repaired pages and the rows they hold, which are the rows that may have lost data. When rows
+// carry checksums, only the corrupted rows are rewritten, and the report lists them instead.
+func (s *Store) Repair(opts RepairOptions) (RepairReport, error) {
+	replacement := make([]byte, s.file.PageSize())
+	if opts.Mode == RepairResetDefault {
+		copy(replacement, s.defaultPage())
+	} else if s.RowChecksums {
+		zero := make(Row, s.rowSize)
+		for slot := 0; slot < s.rowsPerPage; slot++ {
+			s.sealRow(replacement, slot, zero)
+		}
+	}
+	if s.RowChecksums {
+		return s.repairRows(replacement)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	report := RepairReport{}
+	corrupted, err := s.VerifyWorkers(workers)
+	if err != nil {
+		return report, err
+	}
+	for _, page := range corrupted {
+		fromCache, err := s.file.RepairPage(page, replacement)
+		if err != nil {
+			return report, err
+		}
+		firstRow := page * s.rowsPerPage
+		report.Pages = append(report.Pages, RepairedPage{
+			Page:      page,
+			FirstRow:  firstRow,
+			Rows:      max(0, min(s.rowsPerPage, s.Rows-firstRow)),
+			FromCache: fromCache,
+		})
+	}
+	return report, nil
+}
+
+// Rewrite each corrupted row with its row of the replacement page, keeping the rest of its page.
+// A page cut short is rewritten whole, and a row still intact in the cache is written back as is.
+func (s *Store) repairRows(replacement []byte) (RepairReport, error) {
+	report := RepairReport{}
+	corrupted, short, err := s.verifyRowChecksums()
+	if err != nil {
+		return report, err
+	}
+	for len(corrupted) > 0 {
+		page := corrupted[0] / s.rowsPerPage
+		end := 0
+		for end < len(corrupted) && corrupted[end]/s.rowsPerPage == page {
+			end++
+		}
+		rows := corrupted[:end]
+		corrupted = corrupted[end:]
+
+		first := page * s.rowsPerPage
+		fromCache := make([]bool, len(rows))
+		if short[page] {
+			cached, err := s.file.RepairPage(page, replacement)
+			if err != nil {
+				return report, err
+			}
+			for i := range fromCache {
+				fromCache[i] = cached
+			}
+		} else {
+			count := min(s.rowsPerPage, s.Rows-first)
+			err := s.file.ModifyChunk(page, 0, s.pageBytes(count), func(chunk []byte) {
+				row := make(Row, s.rowSize)
+				for i, index := range rows {
+					slot := index - first
+					s.gatherRow(chunk, slot, row)
+					if fromCache[i] = s.rowIntact(chunk, slot, row); fromCache[i] {
+						continue
+					}
+					s.gatherRow(replacement, slot, row)
+					s.scatterRow(chunk, slot, row)
+					s.sealRow(chunk, slot, row)
+				}
+			})
+			if err != nil {
+				return report, err
+			}
+			if err := s.file.FlushPages([]int{page}); err != nil {
+				return report, err
+			}
+		}
+		for i, index := range rows {
+			report.Rows = append(report.Rows, RepairedRow{Row: index, FromCache: fromCache[i]})
+		}
+	}
+	return report, nil
 }
 
 func (s *Store) Projection(columns ...string) (Projection, error) {
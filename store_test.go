@@ -1,10 +1,18 @@
 package pixidb
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestBasicCreate(t *testing.T) {
@@ -38,31 +46,33 @@ func TestBasicCreate(t *testing.T) {
 		}, 16, (os.Getpagesize() - ChecksumSize) / 16},
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			store, err := NewStore(filepath.Join(dir, tc.name), tc.rows, tc.columns...)
-			if err != nil {
-				t.Fatal(err)
-			}
-			if store.Name != tc.name {
-				t.Errorf("expected name %s, got %s", tc.name, store.Name)
-			}
-			if store.Rows != tc.rows {
-				t.Errorf("expected rows %d, got %d", tc.rows, store.Rows)
-			}
-			if store.RowSize() != tc.expectRowSize {
-				t.Errorf("expected row size %d, got %d", tc.expectRowSize, store.RowSize())
-			}
-			if store.RowsPerPage() != tc.expectRowsPerPage {
-				t.Errorf("expected rows per page %d, got %d", tc.expectRowsPerPage, store.RowsPerPage())
-			}
+	for _, backend := range storeBackends(dir) {
+		for _, tc := range testCases {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				store, err := backend.create(tc.name, tc.rows, tc.columns...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if store.Name != tc.name {
+					t.Errorf("expected name %s, got %s", tc.name, store.Name)
+				}
+				if store.Rows != tc.rows {
+					t.Errorf("expected rows %d, got %d", tc.rows, store.Rows)
+				}
+				if store.RowSize() != tc.expectRowSize {
+					t.Errorf("expected row size %d, got %d", tc.expectRowSize, store.RowSize())
+				}
+				if store.RowsPerPage() != tc.expectRowsPerPage {
+					t.Errorf("expected rows per page %d, got %d", tc.expectRowsPerPage, store.RowsPerPage())
+				}
 
-			defRow := store.DefaultRow()
+				defRow := store.DefaultRow()
 
-			compareRow(t, store, 0, defRow)
-			compareRow(t, store, store.Rows-1, defRow)
-			compareRow(t, store, store.Rows/2, defRow)
-		})
+				compareRow(t, store, 0, defRow)
+				compareRow(t, store, store.Rows-1, defRow)
+				compareRow(t, store, store.Rows/2, defRow)
+			})
+		}
 	}
 }
 
@@ -95,10 +105,13 @@ func TestBasicOpen(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := NewStore(filepath.Join(dir, tc.name), tc.rows, tc.columns...)
+			created, err := NewStore(filepath.Join(dir, tc.name), tc.rows, tc.columns...)
 			if err != nil {
 				t.Fatal(err)
 			}
+			if err := created.Close(); err != nil {
+				t.Fatal(err)
+			}
 
 			store, err := OpenStore(filepath.Join(dir, tc.name))
 			if err != nil {
@@ -163,7 +176,7 @@ func TestBasicSetPersist(t *testing.T) {
 			store.SetRowAt(store.Rows-1, tc.setRow)
 			store.Checkpoint()
 
-			saved, err := OpenStore(filepath.Join(dir, tc.name))
+			saved, err := OpenStoreReadOnly(filepath.Join(dir, tc.name))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -208,7 +221,7 @@ func TestSetValuePersist(t *testing.T) {
 			store.SetValueAt("one", store.Rows-1, tc.setRow)
 			store.Checkpoint()
 
-			saved, err := OpenStore(filepath.Join(dir, tc.name))
+			saved, err := OpenStoreReadOnly(filepath.Join(dir, tc.name))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -220,6 +233,9 @@ func TestSetValuePersist(t *testing.T) {
 			if saved.Rows > 2 {
 				compareRow(t, saved, saved.Rows/2, defRow)
 			}
+			if val, err := saved.GetValueAt(0); err != nil || !val.Equal(tc.setRow) {
+				t.Errorf("expected the single column value %v, got %v, %v", tc.setRow, val, err)
+			}
 		})
 	}
 }
@@ -276,6 +292,320 @@ func TestStoreColumnProjection(t *testing.T) {
 	}
 }
 
+func TestStoreDuplicateColumns(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_duplicate_columns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "duplicated")
+	_, err = NewStore(path, 10, NewColumnInt16("depth", 0), NewColumnInt32("count", 0), NewColumnFloat64("depth", 0))
+	var dupErr DuplicateColumnError
+	if !errors.As(err, &dupErr) || dupErr.Column != "depth" {
+		t.Errorf("expected duplicate column error for depth, got %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected nothing created for duplicate columns, got %v", err)
+	}
+
+	// the same column may still be projected more than once
+	store, err := NewStore(path, 10, NewColumnInt16("depth", 4), NewColumnInt32("count", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	proj, err := store.Projection("depth", "count", "depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := store.GetRowAt(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := row.Project(proj)
+	if vals[0].AsInt16() != 4 || vals[2].AsInt16() != 4 {
+		t.Errorf("expected depth 4 projected twice, got %d and %d", vals[0].AsInt16(), vals[2].AsInt16())
+	}
+}
+
+func TestConcurrentModifyRow(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_concurrent_modify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, backend := range storeBackends(dir) {
+		t.Run(backend.name, func(t *testing.T) {
+			store, err := backend.create("concurrent", 1000, NewColumnInt64("count", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// every worker increments the same overlapping set of rows, spanning multiple pages
+			workers := 8
+			increments := 50
+			rows := []int{0, 1, store.RowsPerPage() - 1, store.RowsPerPage(), store.Rows - 1}
+
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < increments; i++ {
+						for _, r := range rows {
+							err := store.ModifyRowAt(r, func(row Row) {
+								binary.BigEndian.PutUint64(row, binary.BigEndian.Uint64(row)+1)
+							})
+							if err != nil {
+								t.Error(err)
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			for _, r := range rows {
+				compareRow(t, store, r, NewInt64Value(int64(workers*increments)))
+			}
+		})
+	}
+}
+
+func TestConcurrentSetValue(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_concurrent_set_value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, backend := range storeBackends(dir) {
+		t.Run(backend.name, func(t *testing.T) {
+			columns := make([]Column, 8)
+			for c := range columns {
+				columns[c] = NewColumnInt32("c"+strconv.Itoa(c), -1)
+			}
+			store, err := backend.create("concurrent", 1000, columns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// every worker writes its own column of the same rows, alongside row updates that
+			// leave the values in place
+			rows := []int{0, 1, store.RowsPerPage() - 1, store.RowsPerPage(), store.Rows - 1}
+			var wg sync.WaitGroup
+			for c := range columns {
+				wg.Add(1)
+				go func(c int) {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						for _, r := range rows {
+							if err := store.SetValueAt(columns[c].Name, r, NewInt32Value(int32(c*1000+i))); err != nil {
+								t.Error(err)
+							}
+							if err := store.ModifyRowAt(r, func(row Row) {}); err != nil {
+								t.Error(err)
+							}
+						}
+					}
+				}(c)
+			}
+			wg.Wait()
+
+			for _, r := range rows {
+				for c, col := range columns {
+					val, err := store.GetColumnValueAt(col.Name, r)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if val.AsInt32() != int32(c*1000+49) {
+						t.Errorf("row %d: expected the last write %d to column %s, got %d", r, c*1000+49, col.Name, val.AsInt32())
+					}
+				}
+			}
+
+			var sizeErr ValueSizeError
+			if err := store.SetValueAt("c0", 0, NewInt64Value(1)); !errors.As(err, &sizeErr) || sizeErr.Expected != 4 {
+				t.Errorf("expected a value size error for a value too long for the column, got %v", err)
+			}
+			var notFound *ColumnNotFoundError
+			if err := store.SetValueAt("missing", 0, NewInt32Value(1)); !errors.As(err, &notFound) {
+				t.Errorf("expected a column not found error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetRowsAt(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_get_rows_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, backend := range storeBackends(dir) {
+		t.Run(backend.name, func(t *testing.T) {
+			store, err := backend.create("rowsat", 3000, NewColumnInt32("col1", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < store.Rows; i++ {
+				if err := store.SetRowAt(i, Row(NewInt32Value(int32(i)))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// indices scattered back and forth across pages
+			indices := []int{store.Rows - 1, 0, store.RowsPerPage(), 1, store.RowsPerPage() - 1, 2 * store.RowsPerPage(), 0}
+			rows, err := store.GetRowsAt(indices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(indices) {
+				t.Fatalf("expected %d rows, got %d", len(indices), len(rows))
+			}
+			for i, index := range indices {
+				if Value(rows[i]).AsInt32() != int32(index) {
+					t.Errorf("expected row %d to hold %d, got %d", i, index, Value(rows[i]).AsInt32())
+				}
+			}
+
+			for _, bad := range []int{-1, store.Rows, store.Rows + 100} {
+				_, err := store.GetRowsAt([]int{0, bad, 1})
+				var rangeErr IndexOutOfRangeError
+				if !errors.As(err, &rangeErr) {
+					t.Errorf("expected index out of range error for %d, got %v", bad, err)
+				} else if rangeErr.Index != bad {
+					t.Errorf("expected error to name index %d, got %d", bad, rangeErr.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestRowIndexBounds(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_row_bounds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name  string
+		rows  int
+		index int
+	}{
+		{"negative", 10, -1},
+		{"zeroatempty", 0, 0},
+		{"rowsequal", 10, 10},
+		{"pagebeyond", 10, 10000},
+	}
+
+	for _, backend := range storeBackends(dir) {
+		for _, tc := range testCases {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				store, err := backend.create(tc.name, tc.rows, NewColumnInt16("col1", 1))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, err = store.GetRowAt(tc.index)
+				checkIndexOutOfRange(t, err, tc.index, tc.rows)
+				err = store.SetRowAt(tc.index, Row(NewInt16Value(2)))
+				checkIndexOutOfRange(t, err, tc.index, tc.rows)
+				err = store.SetValueAt("col1", tc.index, NewInt16Value(2))
+				checkIndexOutOfRange(t, err, tc.index, tc.rows)
+				err = store.ModifyRowAt(tc.index, func(r Row) {})
+				checkIndexOutOfRange(t, err, tc.index, tc.rows)
+			})
+		}
+	}
+}
+
+// The ways of creating a store that the store tests run against, on disk in the directory or in
+// memory.
+func storeBackends(dir string) []struct {
+	name   string
+	create func(name string, rows int, columns ...Column) (*Store, error)
+} {
+	return []struct {
+		name   string
+		create func(name string, rows int, columns ...Column) (*Store, error)
+	}{
+		{"disk", func(name string, rows int, columns ...Column) (*Store, error) {
+			return NewStore(filepath.Join(dir, name), rows, columns...)
+		}},
+		{"memory", NewMemoryStore},
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store, err := NewMemoryStore("scratch", 60000, NewColumnInt32("value", -1), NewColumnFloat32("other", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Path() != "" {
+		t.Errorf("expected no path for a store in memory, got %q", store.Path())
+	}
+
+	// enough pages that writes are evicted from the cache into memory and read back
+	if store.pages() <= MaxPagesInCache {
+		t.Fatalf("expected more than %d pages, got %d", MaxPagesInCache, store.pages())
+	}
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetValueAt("value", r, NewInt32Value(int32(r))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if store.file.DirtyPages() == 0 {
+		t.Error("expected a checkpoint of a store in memory to leave the cache alone")
+	}
+	if store.file.Stats().Evictions == 0 {
+		t.Error("expected pages to be evicted into memory")
+	}
+	for _, r := range []int{0, 1, store.RowsPerPage(), store.Rows / 2, store.Rows - 1} {
+		val, err := store.GetColumnValueAt("value", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.AsInt32() != int32(r) {
+			t.Errorf("expected row %d to hold %d, got %d", r, r, val.AsInt32())
+		}
+	}
+	if corrupted, err := store.Verify(); err != nil || len(corrupted) != 0 {
+		t.Errorf("expected no corrupted pages, got %v, %v", corrupted, err)
+	}
+
+	if err := store.Grow(store.Rows + 3000); err != nil {
+		t.Fatal(err)
+	}
+	compareRow(t, store, store.Rows-1, store.DefaultRow())
+
+	if err := store.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.memory.data) != 0 {
+		t.Errorf("expected dropping the store to free its data, still holding %d bytes", len(store.memory.data))
+	}
+	if _, err := NewMemoryStore("bad", 10); err != ErrZeroColumns {
+		t.Errorf("expected ErrZeroColumns, got %v", err)
+	}
+}
+
+func checkIndexOutOfRange(t *testing.T, err error, index int, rows int) {
+	var rangeErr IndexOutOfRangeError
+	if !errors.As(err, &rangeErr) {
+		t.Errorf("expected index out of range error, got %v", err)
+	} else if rangeErr.Index != index || rangeErr.Rows != rows {
+		t.Errorf("expected error for index %d of %d rows, got %d of %d", index, rows, rangeErr.Index, rangeErr.Rows)
+	}
+}
+
 func compareRow(t *testing.T, store *Store, row int, expect []byte) {
 	actual, err := store.GetRowAt(row)
 	if err != nil {
@@ -285,3 +615,1557 @@ func compareRow(t *testing.T, store *Store, row int, expect []byte) {
 		t.Errorf("expected row %d to equal row %v, got %v", row, expect, actual)
 	}
 }
+
+func TestStoreAutoCheckpoint(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_auto_checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "auto"), 5000, NewColumnInt64("count", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.StartAutoCheckpoint(time.Millisecond)
+
+	// keep writing until the background checkpoint has flushed pages several times; the store
+	// is small enough that pages are never evicted, so every write back comes from the flusher
+	workers := 4
+	counts := make([]int64, workers)
+	deadline := time.Now().Add(5 * time.Second)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for store.file.Stats().WriteBacks < 20 && time.Now().Before(deadline) {
+				for r := w; r < store.Rows; r += workers * 97 {
+					err := store.ModifyRowAt(r, func(row Row) {
+						binary.BigEndian.PutUint64(row, binary.BigEndian.Uint64(row)+1)
+					})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				counts[w]++
+			}
+		}(w)
+	}
+	wg.Wait()
+	if store.file.Stats().WriteBacks < 20 {
+		t.Fatalf("expected the background checkpoint to flush pages, got %+v", store.file.Stats())
+	}
+
+	// restarting replaces the running flusher rather than starting a second one
+	store.StartAutoCheckpoint(time.Hour)
+
+	// an interval of zero or less stops the running flusher
+	store.StartAutoCheckpoint(0)
+	if store.autoCancel != nil {
+		t.Errorf("expected a zero interval to stop the background checkpoint")
+	}
+	store.StartAutoCheckpoint(-time.Second)
+	if store.autoCancel != nil {
+		t.Errorf("expected a negative interval to leave the background checkpoint stopped")
+	}
+	if err := store.StopAutoCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StopAutoCheckpoint(); err != nil {
+		t.Errorf("expected stopping twice to be safe, got %v", err)
+	}
+	if _, err := store.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStoreReadOnly(filepath.Join(dir, "auto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for w := 0; w < workers; w++ {
+		for r := w; r < store.Rows; r += workers * 97 {
+			compareRow(t, reopened, r, NewInt64Value(counts[w]))
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreCheckpointCount(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_checkpoint_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "count"), 5000, NewColumnInt64("count", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// two writes to each of three pages dirty three pages
+	for _, page := range []int{0, 2, 5} {
+		for _, offset := range []int{0, store.RowsPerPage() - 1} {
+			if err := store.SetValueAt("count", page*store.RowsPerPage()+offset, NewInt64Value(1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if written, err := store.Checkpoint(); err != nil || written != 3 {
+		t.Errorf("expected the checkpoint to write 3 pages, got %d, %v", written, err)
+	}
+	if written, err := store.Checkpoint(); err != nil || written != 0 {
+		t.Errorf("expected a checkpoint with nothing dirty to write no pages, got %d, %v", written, err)
+	}
+	if err := store.SetValueAt("count", store.Rows-1, NewInt64Value(1)); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := store.CheckpointDurable(); err != nil || written != 1 {
+		t.Errorf("expected the durable checkpoint to write 1 page, got %d, %v", written, err)
+	}
+
+	memory, err := NewMemoryStore("count", 5000, NewColumnInt64("count", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer memory.Close()
+	if err := memory.SetValueAt("count", 0, NewInt64Value(1)); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := memory.Checkpoint(); err != nil || written != 0 {
+		t.Errorf("expected a checkpoint of a store in memory to write no pages, got %d, %v", written, err)
+	}
+}
+
+// Overwrite some of the bytes of a page in the data file of the store, bypassing the cache.
+func corruptPage(t *testing.T, store *Store, page int) {
+	t.Helper()
+	file, err := os.OpenFile(filepath.Join(store.Path(), store.Name+DataFileExt), os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	offset := int64(page)*int64(store.file.PageSize()+ChecksumSize) + int64(ChecksumSize) + 10
+	if _, err := file.WriteAt([]byte{0xde, 0xad, 0xbe, 0xef}, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreRepair(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_repair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name      string
+		mode      RepairMode
+		cached    bool
+		expectRow Value
+	}{
+		{"reset default", RepairResetDefault, false, NewInt32Value(5)},
+		{"zero", RepairZero, false, NewInt32Value(0)},
+		{"from cache", RepairResetDefault, true, NewInt32Value(-1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewStore(filepath.Join(dir, tc.name), 3000, NewColumnInt32("value", 5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rpp := store.RowsPerPage()
+			for _, r := range []int{0, rpp - 1, rpp, 2*rpp - 1, 2 * rpp, store.Rows - 1} {
+				if err := store.SetRowAt(r, Row(NewInt32Value(-1))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := store.Checkpoint(); err != nil {
+				t.Fatal(err)
+			}
+			if !tc.cached {
+				store.file.ClearCache()
+			}
+			corruptPage(t, store, 1)
+
+			if !tc.cached {
+				var corrupted PageCorruptedError
+				if _, err := store.GetRowAt(rpp); !errors.As(err, &corrupted) || corrupted.Page != 1 {
+					t.Fatalf("expected corrupted page 1 before repair, got %v", err)
+				}
+			}
+
+			report, err := store.Repair(RepairOptions{Mode: tc.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := []RepairedPage{{Page: 1, FirstRow: rpp, Rows: rpp, FromCache: tc.cached}}
+			if !slices.Equal(report.Pages, expect) {
+				t.Errorf("expected repair report %+v, got %+v", expect, report.Pages)
+			}
+
+			// the repaired page reads fine from disk, and the other pages were untouched
+			store.file.ClearCache()
+			compareRow(t, store, 0, NewInt32Value(-1))
+			compareRow(t, store, rpp-1, NewInt32Value(-1))
+			compareRow(t, store, rpp, tc.expectRow)
+			compareRow(t, store, 2*rpp-1, tc.expectRow)
+			compareRow(t, store, 2*rpp, NewInt32Value(-1))
+			compareRow(t, store, store.Rows-1, NewInt32Value(-1))
+			if tc.mode == RepairZero {
+				compareRow(t, store, rpp+1, NewInt32Value(0))
+			} else {
+				compareRow(t, store, rpp+1, NewInt32Value(5))
+			}
+
+			report, err = store.Repair(RepairOptions{Mode: tc.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Pages) != 0 {
+				t.Errorf("expected nothing left to repair, got %+v", report.Pages)
+			}
+		})
+	}
+}
+
+func TestStoreVerify(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "verify"), 6000, NewColumnInt32("value", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted, err := store.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupted) != 0 {
+		t.Errorf("expected a fresh store to verify, got corrupted pages %v", corrupted)
+	}
+
+	corruptPage(t, store, 1)
+	corruptPage(t, store, 4)
+	corrupted, err = store.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(corrupted, []int{1, 4}) {
+		t.Errorf("expected corrupted pages [1 4], got %v", corrupted)
+	}
+
+	// verifying only reports, the pages are still corrupted afterward
+	corrupted, err = store.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(corrupted, []int{1, 4}) {
+		t.Errorf("expected corrupted pages [1 4] to remain, got %v", corrupted)
+	}
+}
+
+func TestStoreVerifyWorkers(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_verify_workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "verify"), 50000, NewColumnInt32("value", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	expect := []int{0, 3, 4, 17, 29, store.pages() - 1}
+	for _, page := range expect {
+		corruptPage(t, store, page)
+	}
+
+	serial, err := store.VerifyWorkers(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(serial, expect) {
+		t.Fatalf("expected serial verification to find %v, got %v", expect, serial)
+	}
+	for _, workers := range []int{0, 2, 3, 8, 64} {
+		parallel, err := store.VerifyWorkers(workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(parallel, serial) {
+			t.Errorf("expected %d workers to find %v like serial verification, got %v", workers, serial, parallel)
+		}
+	}
+}
+
+func benchmarkStoreVerify(b *testing.B, workers int) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_verify_bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "bench"), 4000000, NewColumnInt32("value", 5))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	b.SetBytes(int64(store.pages()) * int64(store.file.PageSize()+ChecksumSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.VerifyWorkers(workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStoreVerifySerial(b *testing.B) {
+	benchmarkStoreVerify(b, 1)
+}
+
+func BenchmarkStoreVerifyParallel(b *testing.B) {
+	benchmarkStoreVerify(b, runtime.GOMAXPROCS(0))
+}
+
+func TestStoreGrow(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_grow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "grow")
+	store, err := NewStore(path, 100, NewColumnInt32("value", 5), NewColumnUint8("flag", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpp := store.RowsPerPage()
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetRowAt(r, Row(append(NewInt32Value(int32(-r)), 0))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Grow(50); err != nil {
+		t.Fatal(err)
+	}
+	if store.Rows != 100 {
+		t.Errorf("expected growing to fewer rows to do nothing, got %d rows", store.Rows)
+	}
+
+	newRows := 2*rpp + 10
+	if err := store.Grow(newRows); err != nil {
+		t.Fatal(err)
+	}
+	if store.Rows != newRows {
+		t.Errorf("expected %d rows after growing, got %d", newRows, store.Rows)
+	}
+	if _, err := store.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStoreReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Rows != newRows {
+		t.Errorf("expected %d rows after reopen, got %d", newRows, reopened.Rows)
+	}
+	for _, r := range []int{0, 1, 99} {
+		compareRow(t, reopened, r, Row(append(NewInt32Value(int32(-r)), 0)))
+	}
+	for _, r := range []int{100, rpp - 1, rpp, 2 * rpp, newRows - 1} {
+		compareRow(t, reopened, r, Row(append(NewInt32Value(5), 1)))
+	}
+	if _, err := reopened.GetRowAt(newRows); err == nil {
+		t.Errorf("expected error reading past the grown rows")
+	}
+	corrupted, err := reopened.Verify()
+	if err != nil || len(corrupted) != 0 {
+		t.Errorf("expected grown store to verify, got %v, %v", corrupted, err)
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// rows that fill their last page exactly leave an unused page at the end of the file
+	path := filepath.Join(dir, "compact")
+	store, err := NewStorePageSize(path, 64, 64, NewColumnInt32("value", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetRowAt(r, Row(NewInt32Value(int32(-r)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dataPath := filepath.Join(path, "compact"+DataFileExt)
+	fileSize := func() int64 {
+		info, err := os.Stat(dataPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	if size := fileSize(); size != 5*int64(64+ChecksumSize) {
+		t.Fatalf("expected 5 pages before compacting, got %d bytes", size)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := store.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		if size := fileSize(); size != 4*int64(64+ChecksumSize) {
+			t.Errorf("expected 4 pages after compacting, got %d bytes", size)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if size := fileSize(); size != 4*int64(64+ChecksumSize) {
+		t.Errorf("expected the compacted file kept at 4 pages after reopening, got %d bytes", size)
+	}
+	for r := 0; r < reopened.Rows; r++ {
+		compareRow(t, reopened, r, Row(NewInt32Value(int32(-r))))
+	}
+	if corrupted, err := reopened.Verify(); err != nil || len(corrupted) != 0 {
+		t.Errorf("expected compacted store to verify, got %v, %v", corrupted, err)
+	}
+
+	if err := reopened.Grow(70); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []int{0, 63} {
+		compareRow(t, reopened, r, Row(NewInt32Value(int32(-r))))
+	}
+	for _, r := range []int{64, 69} {
+		compareRow(t, reopened, r, Row(NewInt32Value(5)))
+	}
+	if corrupted, err := reopened.Verify(); err != nil || len(corrupted) != 0 {
+		t.Errorf("expected compacted store to verify after growing, got %v, %v", corrupted, err)
+	}
+}
+
+func TestStoreHistogram(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_histogram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a uniform ramp 0..2999 spanning several pages, with a few no-data rows
+	store, err := NewStore(filepath.Join(dir, "ramp"), 3000,
+		NewColumnFloat32("ramp", 0).WithNoData(NewFloat32Value(-1)),
+		NewColumnInt16("steps", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := store.Projection("ramp", "steps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		ramp := NewFloat32Value(float32(r))
+		if r%1000 == 5 {
+			ramp = NewFloat32Value(-1)
+		}
+		err := store.ModifyRowAt(r, func(row Row) {
+			copy(row[proj[0].start:], ramp)
+			copy(row[proj[1].start:], NewInt16Value(int16(r/10-150)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		column string
+		bins   int
+		min    float64
+		max    float64
+		expect []int
+	}{
+		{"whole ramp", "ramp", 3, 0, 3000, []int{999, 999, 999}},
+		{"partial range with max in last bin", "ramp", 5, 100, 600, []int{100, 100, 100, 100, 101}},
+		{"single bin", "ramp", 1, 1000, 1999, []int{999}},
+		{"signed steps", "steps", 4, -150, 150, []int{750, 750, 750, 750}},
+		{"out of range", "steps", 2, 1000, 2000, []int{0, 0}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			counts, err := store.Histogram(tc.column, tc.bins, tc.min, tc.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(counts, tc.expect) {
+				t.Errorf("expected counts %v, got %v", tc.expect, counts)
+			}
+		})
+	}
+
+	if _, err := store.Histogram("ramp", 0, 0, 1); !errors.Is(err, ErrInvalidBins) {
+		t.Errorf("expected error for zero bins, got %v", err)
+	}
+	if _, err := store.Histogram("ramp", 2, 1, 1); !errors.Is(err, ErrInvalidBins) {
+		t.Errorf("expected error for empty range, got %v", err)
+	}
+	if _, err := store.Histogram("missing", 2, 0, 1); err == nil {
+		t.Errorf("expected error for missing column")
+	}
+}
+
+func TestStoreMapColumn(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_mapcolumn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mapped")
+	store, err := NewStore(path, 3000, NewColumnInt32("count", 0), NewColumnFloat64("level", 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := store.Projection("count", "level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		err := store.ModifyRowAt(r, func(row Row) {
+			copy(row[proj[0].start:], NewInt32Value(int32(r)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = store.MapColumn("count", func(v Value) Value {
+		return NewInt32Value(-v.AsInt32())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.MapColumn("level", func(v Value) Value {
+		return NewFloat64Value(v.AsFloat64() + 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.MapColumn("count", func(v Value) Value {
+		return NewInt16Value(1)
+	})
+	if !errors.As(err, &ValueSizeError{}) {
+		t.Errorf("expected value size error, got %v", err)
+	}
+	if err := store.MapColumn("missing", func(v Value) Value { return v }); err == nil {
+		t.Errorf("expected error for missing column")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for _, r := range []int{0, 1, 999, 2999} {
+		row, err := reopened.GetRowAt(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := Value(row[proj[0].start : proj[0].start+proj[0].size]).AsInt32()
+		level := Value(row[proj[1].start : proj[1].start+proj[1].size]).AsFloat64()
+		if count != int32(-r) {
+			t.Errorf("expected count %d at row %d, got %d", -r, r, count)
+		}
+		if level != 2.5 {
+			t.Errorf("expected level 2.5 at row %d, got %v", r, level)
+		}
+	}
+}
+
+func TestStoreCombine(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_combine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "bands"), 3000,
+		NewColumnInt32("a", 0), NewColumnInt32("b", 0), NewColumnInt32("diff", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	proj, err := store.Projection("a", "b", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		err := store.ModifyRowAt(r, func(row Row) {
+			copy(row[proj[0].start:], NewInt32Value(int32(r*3)))
+			copy(row[proj[1].start:], NewInt32Value(int32(r%7)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := store.file.Stats()
+	err = store.Combine("diff", "a", "b", func(av, bv Value) Value {
+		return NewInt32Value(av.AsInt32() - bv.AsInt32())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := store.file.Stats()
+	if touched := (after.Hits + after.Misses) - (stats.Hits + stats.Misses); touched != int64(store.pages()) {
+		t.Errorf("expected combine to touch %d pages once each, touched %d", store.pages(), touched)
+	}
+
+	for r := 0; r < store.Rows; r++ {
+		row, err := store.GetRowAt(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff := Value(row[proj[2].start : proj[2].start+proj[2].size]).AsInt32()
+		if diff != int32(r*3-r%7) {
+			t.Fatalf("expected diff %d at row %d, got %d", r*3-r%7, r, diff)
+		}
+	}
+
+	err = store.Combine("diff", "a", "b", func(av, bv Value) Value {
+		return NewInt64Value(0)
+	})
+	if !errors.As(err, &ValueSizeError{}) {
+		t.Errorf("expected value size error, got %v", err)
+	}
+	if err := store.Combine("diff", "a", "missing", func(av, bv Value) Value { return av }); err == nil {
+		t.Errorf("expected error for missing column")
+	}
+}
+
+func TestStoreFormatVersion(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_format_version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "versioned")
+	before := time.Now()
+	store, err := NewStore(path, 10, NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.FormatVersion != StoreFormatVersion {
+		t.Errorf("expected format version %d, got %d", StoreFormatVersion, reopened.FormatVersion)
+	}
+	if reopened.CreatedAt.Before(before.Add(-time.Second)) || reopened.CreatedAt.After(time.Now()) {
+		t.Errorf("expected creation time around %v, got %v", before, reopened.CreatedAt)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	metaPath := filepath.Join(path, "versioned"+MetadataFileExt)
+	metaText, _, err := readMetadataFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := fmt.Sprintf(`"formatVersion":%d`, StoreFormatVersion)
+	edited := strings.Replace(string(metaText), current, `"formatVersion":99`, 1)
+	if edited == string(metaText) {
+		t.Fatalf("format version not found in metadata %s", metaText)
+	}
+	if err := os.WriteFile(metaPath, []byte(edited), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenStore(path)
+	var versionErr FormatVersionError
+	if !errors.As(err, &versionErr) {
+		t.Fatalf("expected format version error, got %v", err)
+	}
+	if versionErr.Version != 99 || versionErr.Supported != StoreFormatVersion {
+		t.Errorf("expected version 99 against %d, got %d against %d", StoreFormatVersion, versionErr.Version, versionErr.Supported)
+	}
+}
+
+func TestStorePageSize(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_page_size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a page size unlike any host page size stands in for a store written on another host
+	path := filepath.Join(dir, "foreign")
+	store, err := NewStorePageSize(path, 1000, 500, NewColumnInt32("count", 0), NewColumnFloat64("level", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := store.Projection("count", "level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		err := store.ModifyRowAt(r, func(row Row) {
+			copy(row[proj[0].start:], NewInt32Value(int32(r)))
+			copy(row[proj[1].start:], NewFloat64Value(float64(r)/4))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(path, "foreign"+DataFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := int64((1000/41 + 1) * (500 + ChecksumSize)); info.Size() != expect {
+		t.Errorf("expected data file of %d bytes, got %d", expect, info.Size())
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.PageSize != 500 || reopened.RowsPerPage() != 41 {
+		t.Errorf("expected page size 500 with 41 rows, got %d with %d rows", reopened.PageSize, reopened.RowsPerPage())
+	}
+	for _, r := range []int{0, 40, 41, 500, 999} {
+		row, err := reopened.GetRowAt(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals := row.Project(proj)
+		if vals[0].AsInt32() != int32(r) || vals[1].AsFloat64() != float64(r)/4 {
+			t.Errorf("expected %d and %v at row %d, got %d and %v", r, float64(r)/4, r, vals[0].AsInt32(), vals[1].AsFloat64())
+		}
+	}
+
+	if _, err := NewStorePageSize(filepath.Join(dir, "tiny"), 10, 8, NewColumnFloat64("a", 0), NewColumnInt8("b", 0)); !errors.Is(err, ErrPageTooSmall) {
+		t.Errorf("expected page too small error, got %v", err)
+	}
+}
+
+func TestStorePageSizeMigration(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_page_size_migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "legacy")
+	store, err := NewStore(path, 2000, NewColumnInt64("value", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the metadata as version 1, which did not record the page size
+	metaPath := filepath.Join(path, "legacy"+MetadataFileExt)
+	meta, _, err := readRawMetadata(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(meta, "pageSize")
+	meta["formatVersion"] = []byte("1")
+	if err := writeRawMetadata(metaPath, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.PageSize != DefaultPageSize() {
+		t.Errorf("expected migrated page size %d, got %d", DefaultPageSize(), reopened.PageSize)
+	}
+	row, err := reopened.GetRowAt(1999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := Value(row).AsInt64(); val != 7 {
+		t.Errorf("expected default value 7 in last row, got %d", val)
+	}
+}
+
+func TestStoreOpenOnOtherHostPageSize(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_host_page_size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(original func() int) { hostPageSize = original }(hostPageSize)
+
+	path := filepath.Join(dir, "moved")
+	hostPageSize = func() int { return 4096 }
+	store, err := NewStore(path, 5000, NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		if err := store.SetValueAt("value", r, NewInt32Value(int32(r*3))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pageSize := range []int{16384, 65536, 1024} {
+		hostPageSize = func() int { return pageSize }
+		reopened, err := OpenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reopened.PageSize != 4096-ChecksumSize {
+			t.Errorf("expected stored page size %d on a %d byte host, got %d", 4096-ChecksumSize, pageSize, reopened.PageSize)
+		}
+		for _, r := range []int{0, 1022, 1023, 1024, 4999} {
+			row, err := reopened.GetRowAt(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val := Value(row).AsInt32(); val != int32(r*3) {
+				t.Errorf("expected %d at row %d on a %d byte host, got %d", r*3, r, pageSize, val)
+			}
+		}
+		if err := reopened.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStoreDiskSpaceCheck(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_disk_space")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(original func(string) (uint64, error)) { availableDiskSpace = original }(availableDiskSpace)
+
+	var checked string
+	availableDiskSpace = func(path string) (uint64, error) {
+		checked = path
+		return 64 * 1024, nil
+	}
+
+	path := filepath.Join(dir, "nested", "large")
+	_, err = NewStore(path, 100000, NewColumnInt64("value", 0))
+	var spaceErr InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("expected insufficient space error, got %v", err)
+	}
+	pages := int64(100000/(DefaultPageSize()/8) + 1)
+	if spaceErr.Needed != pages*int64(DefaultPageSize()+ChecksumSize) || spaceErr.Available != 64*1024 {
+		t.Errorf("expected %d bytes needed of %d available, got %d of %d", pages*int64(DefaultPageSize()+ChecksumSize), 64*1024, spaceErr.Needed, spaceErr.Available)
+	}
+	if checked != filepath.Clean(dir) {
+		t.Errorf("expected free space checked on existing directory %s, got %s", dir, checked)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected nothing created after failed space check, got %v", err)
+	}
+
+	store, err := NewStore(filepath.Join(dir, "small"), 100, NewColumnInt64("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+}
+
+// Fails every write made to files opened through it, as a full or failing disk would.
+type failingWriteFile struct {
+	blockDevice
+}
+
+func (f failingWriteFile) WriteAt(b []byte, off int64) (int, error) {
+	return 0, errors.New("injected write failure")
+}
+
+func TestNewStoreCleanup(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(original func(string, int) (blockDevice, error)) { openPageFile = original }(openPageFile)
+	openPageFile = func(path string, flag int) (blockDevice, error) {
+		file, err := openOSPageFile(path, flag)
+		if err != nil {
+			return nil, err
+		}
+		return failingWriteFile{file}, nil
+	}
+
+	path := filepath.Join(dir, "failed")
+	if _, err := NewStore(path, 5000, NewColumnInt32("value", 0)); err == nil {
+		t.Fatal("expected error from failing initialization")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected store directory removed after failure, got %v", err)
+	}
+
+	// a directory that existed beforehand is kept, with only the store files removed
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTable(existing, NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", 0)); err == nil {
+		t.Fatal("expected error from failing initialization")
+	}
+	entries, err := os.ReadDir(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty directory after failure, got %d entries", len(entries))
+	}
+
+	// so is one where the data file cannot be opened to lock it
+	unlockable := filepath.Join(dir, "unlockable")
+	if err := os.MkdirAll(filepath.Join(unlockable, "unlockable"+DataFileExt), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(unlockable, 10, NewColumnInt32("value", 0)); err == nil {
+		t.Fatal("expected error from a data file that cannot be opened")
+	}
+	if entries, err := os.ReadDir(unlockable); err != nil || len(entries) != 0 {
+		t.Errorf("expected empty directory after failing to lock, got %v, %v", entries, err)
+	}
+
+	// retrying once the disk works again starts clean
+	openPageFile = openOSPageFile
+	store, err := NewStore(path, 5000, NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+}
+
+func TestOpenStoreMissingDataFile(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_missing_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "missing")
+	store, err := NewStore(path, 10, NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dataPath := filepath.Join(path, "missing"+DataFileExt)
+	if err := os.Remove(dataPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenStore(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected opening a store without its data file to fail, got %v", err)
+	}
+	if _, err := os.Stat(dataPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no data file created by the failed open, got %v", err)
+	}
+}
+
+func TestStoreScanRowsPrefetch(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// more pages than fit in the cache, so read-ahead has to make room as it goes
+	store, err := NewStorePageSize(filepath.Join(dir, "scan"), 2000, 64, NewColumnInt32("value", 0), NewColumnInt16("other", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < store.Rows; r++ {
+		row := append(NewInt32Value(int32(r*7)), NewInt16Value(int16(-r))...)
+		if err := store.SetRowAt(r, Row(row)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func() []Row {
+		t.Helper()
+		store.file.ClearCache()
+		rows := []Row{}
+		err := store.ScanRows(func(index int, row Row) error {
+			if index != len(rows) {
+				t.Fatalf("expected row %d, got %d", len(rows), index)
+			}
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	expect := scan()
+	if len(expect) != store.Rows {
+		t.Fatalf("expected %d rows, got %d", store.Rows, len(expect))
+	}
+	for _, depth := range []int{1, 4, MaxPagesInCache * 2} {
+		store.SetPrefetchDepth(depth)
+		for i, row := range scan() {
+			if !slices.Equal(row, expect[i]) {
+				t.Fatalf("depth %d: expected row %d to be %v, got %v", depth, i, expect[i], row)
+			}
+		}
+	}
+
+	stopped := errors.New("stop")
+	visited := 0
+	err = store.ScanRows(func(index int, row Row) error {
+		visited++
+		if index == 100 {
+			return stopped
+		}
+		return nil
+	})
+	if err != stopped || visited != 101 {
+		t.Errorf("expected the scan to stop after 101 rows, got %d, %v", visited, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkStoreScan(b *testing.B, depth int) {
+	dir, err := os.MkdirTemp(".", "pixidb_bench_scan")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(filepath.Join(dir, "scan"), 200000, NewColumnFloat64("value", 0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	store.SetPrefetchDepth(depth)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// start every scan from a cold cache
+		store.file.ClearCache()
+		sum := 0.0
+		err := store.ScanRows(func(index int, row Row) error {
+			sum += Value(row).AsFloat64()
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStoreScanRows(b *testing.B) {
+	benchmarkStoreScan(b, 0)
+}
+
+func BenchmarkStoreScanRowsPrefetch(b *testing.B) {
+	benchmarkStoreScan(b, 8)
+}
+
+func TestStoreFlushRange(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_flush_range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ranges")
+	store, err := NewStore(path, 6000, NewColumnInt32("value", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpp := store.RowsPerPage()
+	flushedFrom, flushedTo := rpp/2, 2*rpp+10
+	keptFrom, keptTo := 4*rpp+3, 5*rpp-3
+	for _, r := range [][2]int{{flushedFrom, flushedTo}, {keptFrom, keptTo}} {
+		for i := r[0]; i < r[1]; i++ {
+			if err := store.SetRowAt(i, Row(NewInt32Value(int32(i)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if dirty := store.file.DirtyPages(); dirty != 4 {
+		t.Fatalf("expected 4 dirty pages, got %d", dirty)
+	}
+
+	if err := store.FlushRange(flushedFrom, flushedTo); err != nil {
+		t.Fatal(err)
+	}
+	if dirty := store.file.DirtyPages(); dirty != 1 {
+		t.Errorf("expected only the page of the other range left dirty, got %d dirty pages", dirty)
+	}
+	var rangeErr IndexOutOfRangeError
+	if err := store.FlushRange(10, store.Rows+1); !errors.As(err, &rangeErr) {
+		t.Errorf("expected an index out of range error past the end, got %v", err)
+	}
+	if err := store.FlushRange(10, 5); !errors.As(err, &rangeErr) {
+		t.Errorf("expected an index out of range error for a reversed range, got %v", err)
+	}
+
+	// dropping the cache loses whatever was not flushed, as a crash would
+	store.file.ClearCache()
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for i := 0; i < reopened.Rows; i++ {
+		row, err := reopened.GetRowAt(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := int32(5)
+		if i >= flushedFrom && i < flushedTo {
+			expect = int32(i)
+		}
+		if val := Value(row).AsInt32(); val != expect {
+			t.Fatalf("row %d: expected %d after reopening, got %d", i, expect, val)
+		}
+	}
+}
+
+func layoutColumns() []Column {
+	return []Column{
+		NewColumnInt32("id", -1),
+		NewColumnFloat64("field", 0.5),
+		NewColumnUint8("mask", 0).WithCompression(),
+		NewColumnInt16("hot", 3),
+	}
+}
+
+func TestStoreLayoutsAgree(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_layouts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := map[StoreLayout]*Store{}
+	for _, layout := range []StoreLayout{RowLayout, ColumnLayout} {
+		store, err := NewStoreLayout(filepath.Join(dir, fmt.Sprintf("layout%d", layout)), 3000, layout, layoutColumns()...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		stores[layout] = store
+
+		for r := 0; r < store.Rows; r += 3 {
+			row := Row(NewInt32Value(int32(r)))
+			row = append(row, NewFloat64Value(float64(r%11))...)
+			row = append(row, byte(r/100%2))
+			row = append(row, NewInt16Value(int16(-r))...)
+			if err := store.SetRowAt(r, row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for r := 1; r < store.Rows; r += 7 {
+			if err := store.SetValueAt("hot", r, NewInt16Value(int16(r))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.ModifyRowAt(2, func(row Row) { row[4] ^= 0xff }); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.MapColumn("field", func(v Value) Value { return NewFloat64Value(v.AsFloat64() * 2) }); err != nil {
+			t.Fatal(err)
+		}
+		err = store.Combine("id", "id", "mask", func(a Value, b Value) Value {
+			return NewInt32Value(a.AsInt32() + int32(b.AsUint8()))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Grow(3100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rowStore, columnStore := stores[RowLayout], stores[ColumnLayout]
+	if columnStore.RowsPerPage() != rowStore.RowsPerPage() {
+		t.Errorf("expected layouts to hold the same rows per page, got %d and %d", rowStore.RowsPerPage(), columnStore.RowsPerPage())
+	}
+	indices := []int{2999, 0, 1, 2, 700, 3050, 1500}
+	expected, err := rowStore.GetRowsAt(indices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := columnStore.GetRowsAt(indices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range indices {
+		if !slices.Equal(actual[i], expected[i]) {
+			t.Errorf("expected row %d to be %v in the column layout, got %v", indices[i], expected[i], actual[i])
+		}
+	}
+
+	expectHist, err := rowStore.Histogram("field", 11, 0, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualHist, err := columnStore.Histogram("field", 11, 0, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(actualHist, expectHist) {
+		t.Errorf("expected column layout histogram %v, got %v", expectHist, actualHist)
+	}
+
+	if err := columnStore.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenStore(columnStore.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Layout != ColumnLayout {
+		t.Errorf("expected the column layout after reopening, got %d", reopened.Layout)
+	}
+	err = reopened.ScanRows(func(index int, row Row) error {
+		expect, err := rowStore.GetRowAt(index)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(row, expect) {
+			t.Errorf("expected row %d to be %v in both layouts, got %v", index, expect, row)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range indices {
+		expect, _ := rowStore.GetColumnValueAt("hot", index)
+		if val, err := reopened.GetColumnValueAt("hot", index); err != nil || !val.Equal(expect) {
+			t.Errorf("expected value %v of row %d in both layouts, got %v, %v", expect, index, val, err)
+		}
+	}
+	if corrupted, err := reopened.Verify(); err != nil || len(corrupted) != 0 {
+		t.Errorf("expected the column layout store to verify, got %v, %v", corrupted, err)
+	}
+
+	if _, err := NewStoreLayout(filepath.Join(dir, "unknown"), 10, StoreLayout(7), layoutColumns()...); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("expected an invalid layout error, got %v", err)
+	}
+}
+
+func benchmarkStoreColumnScan(b *testing.B, layout StoreLayout) {
+	dir, err := os.MkdirTemp(".", "pixidb_bench_column_scan")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	columns := make([]Column, 8)
+	for i := range columns {
+		columns[i] = NewColumnFloat64(fmt.Sprintf("band%d", i), float64(i))
+	}
+	store, err := NewStoreLayout(filepath.Join(dir, "scan"), 200000, layout, columns...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	// keep every page cached, so the scan measures reading the pages rather than the disk
+	store.file.maxCache = store.pages()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Histogram("band3", 16, 0, 8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStoreColumnScanRowLayout(b *testing.B) {
+	benchmarkStoreColumnScan(b, RowLayout)
+}
+
+func BenchmarkStoreColumnScanColumnLayout(b *testing.B) {
+	benchmarkStoreColumnScan(b, ColumnLayout)
+}
+
+func TestStoreRowChecksums(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_row_checksums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	columns := []Column{NewColumnInt32("id", -1), NewColumnInt16("hot", 3)}
+	for _, layout := range []StoreLayout{RowLayout, ColumnLayout} {
+		t.Run(fmt.Sprintf("layout%d", layout), func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("rows%d", layout))
+			store, err := NewStoreOptions(path, 500, StoreOptions{PageSize: 256, Layout: layout, RowChecksums: true}, columns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect := 256 / (store.RowSize() + ChecksumSize); store.RowsPerPage() != expect {
+				t.Errorf("expected %d rows per page, got %d", expect, store.RowsPerPage())
+			}
+			row := func(r int) Row {
+				return Row(append(NewInt32Value(int32(r)), NewInt16Value(int16(-r))...))
+			}
+			for r := 0; r < store.Rows; r++ {
+				if err := store.SetRowAt(r, row(r)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.SetValueAt("hot", 7, NewInt16Value(70)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// corrupt the id of a single row in the middle of a page
+			file, err := os.OpenFile(filepath.Join(path, filepath.Base(path)+DataFileExt), os.O_RDWR, 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := 2*store.RowsPerPage() + 5
+			offset := int64(2)*int64(store.file.PageSize()+ChecksumSize) + int64(ChecksumSize) +
+				int64(store.valueOffset(5, ColumnProjection{0, 0, 4}))
+			if _, err := file.WriteAt([]byte{0xde, 0xad}, offset); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			reopened, err := OpenStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if !reopened.RowChecksums {
+				t.Fatal("expected row checksums to persist")
+			}
+			if corrupted, err := reopened.VerifyRows(); err != nil || !slices.Equal(corrupted, []int{target}) {
+				t.Errorf("expected only row %d to be corrupted, got %v, %v", target, corrupted, err)
+			}
+			if corrupted, err := reopened.Verify(); err != nil || !slices.Equal(corrupted, []int{2}) {
+				t.Errorf("expected only page 2 to be corrupted, got %v, %v", corrupted, err)
+			}
+			var rowErr RowCorruptedError
+			if _, err := reopened.GetRowAt(target); !errors.As(err, &rowErr) || rowErr.Row != target {
+				t.Errorf("expected a corrupted row error for row %d, got %v", target, err)
+			}
+			if _, err := reopened.GetColumnValueAt("hot", target); !errors.As(err, &rowErr) {
+				t.Errorf("expected a corrupted row error reading a value, got %v", err)
+			}
+			compareRow(t, reopened, target-1, row(target-1))
+			compareRow(t, reopened, target+1, row(target+1))
+			compareRow(t, reopened, 7, Row(append(NewInt32Value(7), NewInt16Value(70)...)))
+
+			report, err := reopened.Repair(RepairOptions{Mode: RepairResetDefault})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Pages) != 0 || !slices.Equal(report.Rows, []RepairedRow{{Row: target}}) {
+				t.Errorf("expected only row %d to be repaired, got %+v", target, report)
+			}
+			compareRow(t, reopened, target, reopened.DefaultRow())
+			compareRow(t, reopened, target+1, row(target+1))
+			if corrupted, err := reopened.VerifyRows(); err != nil || len(corrupted) != 0 {
+				t.Errorf("expected no corrupted rows after repair, got %v, %v", corrupted, err)
+			}
+		})
+	}
+
+	if _, err := NewStoreOptions(filepath.Join(dir, "compressed"), 10, StoreOptions{RowChecksums: true}, mixedCompressionColumns()...); !errors.Is(err, ErrRowChecksumsCompressed) {
+		t.Errorf("expected row checksums with compressed columns to be refused, got %v", err)
+	}
+}
+
+func TestOpenStoreTruncatedTail(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_truncated_tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 42 rows of 6 bytes fit on each 256 byte page
+	testCases := []struct {
+		name    string
+		rows    int
+		layout  StoreLayout
+		cut     int64
+		restore bool
+	}{
+		{"missing unused page", 504, RowLayout, 12 * 260, true},
+		{"cut after last row", 500, RowLayout, 11*260 + 4 + 38*6, true},
+		{"cut after last column", 500, ColumnLayout, 11*260 + 4 + 42*6, true},
+		{"cut inside last row", 500, RowLayout, 11*260 + 4 + 38*6 - 1, false},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("tail%d", i))
+			store, err := NewStoreOptions(path, tc.rows, StoreOptions{PageSize: 256, Layout: tc.layout},
+				NewColumnInt32("id", -1), NewColumnInt16("hot", 3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			row := func(r int) Row {
+				return Row(append(NewInt32Value(int32(r)), NewInt16Value(int16(-r))...))
+			}
+			for r := 0; r < store.Rows; r++ {
+				if err := store.SetRowAt(r, row(r)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+			dataPath := filepath.Join(path, filepath.Base(path)+DataFileExt)
+			if err := os.Truncate(dataPath, tc.cut); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := OpenStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			info, err := os.Stat(dataPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the pages holding rows are restored, as the unused page is added back by Grow
+			expectSize := tc.cut
+			if tc.restore {
+				expectSize = int64(reopened.livePages()) * 260
+			}
+			if info.Size() != expectSize {
+				t.Errorf("expected %d bytes after reopening, got %d", expectSize, info.Size())
+			}
+			corrupted, err := reopened.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.restore {
+				if !slices.Equal(corrupted, []int{11}) {
+					t.Errorf("expected the cut page to be corrupted, got %v", corrupted)
+				}
+				return
+			}
+			if len(corrupted) != 0 {
+				t.Errorf("expected no corrupted pages, got %v", corrupted)
+			}
+			for r := 0; r < reopened.Rows; r++ {
+				compareRow(t, reopened, r, row(r))
+			}
+			if err := reopened.Grow(reopened.Rows + 50); err != nil {
+				t.Fatal(err)
+			}
+			compareRow(t, reopened, reopened.Rows-1, reopened.DefaultRow())
+		})
+	}
+}
+
+func TestEstimateStoreBytes(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_store_estimate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		rows     int
+		pageSize int
+		columns  []Column
+	}{
+		{"single column", 1000, DefaultPageSize(), []Column{NewColumnInt32("a", 0)}},
+		{"rows fill pages", 42 * 5, 256, []Column{NewColumnInt32("a", 0), NewColumnInt16("b", 0)}},
+		{"odd row size", 777, 100, []Column{NewColumnUint8("a", 0), NewColumnFloat64("b", 0), NewColumnInt16("c", 0)}},
+		{"no rows", 0, 512, []Column{NewColumnFloat32("a", 1)}},
+		{"compressed", 300, 256, mixedCompressionColumns()},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			estimate := EstimateStoreBytes(tc.rows, tc.columns, tc.pageSize)
+			path := filepath.Join(dir, fmt.Sprintf("estimate%d", i))
+			store, err := NewStorePageSize(path, tc.rows, tc.pageSize, tc.columns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			info, err := os.Stat(filepath.Join(path, filepath.Base(path)+DataFileExt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if estimate != info.Size() {
+				t.Errorf("expected an estimate of %d bytes, got %d", info.Size(), estimate)
+			}
+		})
+	}
+
+	if estimate := EstimateStoreBytes(10, []Column{NewColumnFloat64("a", 0)}, 4); estimate != 0 {
+		t.Errorf("expected no estimate for pages too small for a row, got %d", estimate)
+	}
+	indexer := NewProjectionlessIndexer(30, 20, true)
+	columns := []Column{NewColumnInt32("a", 0)}
+	if estimate := EstimateTableBytes(indexer, columns); estimate != EstimateStoreBytes(600, columns, DefaultPageSize()) {
+		t.Errorf("expected the table estimate to match its store, got %d", estimate)
+	}
+}
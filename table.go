@@ -1,12 +1,22 @@
 package pixidb
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/owlpinetech/healpix"
+	"golang.org/x/exp/maps"
 )
 
 const TableFileExt string = ".tbl.json"
@@ -16,20 +26,33 @@ const (
 	CreatedAt     string = "created-at"
 )
 
-type ResultSet struct {
-	Columns []Column
-	Rows    [][]Value
-}
-
 type Table struct {
 	store       *Store
+	lock        sync.RWMutex      // guards the metadata and keeps batched writes from interleaving with reads
+	closed      bool              // set once the table is closed, guarded by the lock
+	managed     bool              // set for tables opened by a database, which must be dropped through it
 	Indexer     LocationIndexer   `json:"indexer"`
 	IndexerName string            `json:"indexerName"`
 	Metadata    map[string]string `json:"metadata"`
 }
 
 func NewTable(path string, indexer LocationIndexer, columns ...Column) (*Table, error) {
-	store, err := NewStore(path, indexer.Size(), columns...)
+	return NewTablePageSize(path, indexer, DefaultPageSize(), columns...)
+}
+
+// The number of bytes the data file of a table created by NewTable with the given indexer and
+// columns takes up, see EstimateStoreBytes. The metadata files of the table take a few hundred
+// bytes more, depending on its metadata.
+func EstimateTableBytes(indexer LocationIndexer, columns []Column) int64 {
+	return EstimateStoreBytes(indexer.Size(), columns, DefaultPageSize())
+}
+
+// Create a new table like NewTable, with the given number of data bytes in each page of the data
+// file rather than the default for this host, see NewStorePageSize.
+func NewTablePageSize(path string, indexer LocationIndexer, pageSize int, columns ...Column) (*Table, error) {
+	_, statErr := os.Stat(path)
+	createdDir := errors.Is(statErr, os.ErrNotExist)
+	store, err := NewStorePageSize(path, indexer.Size(), pageSize, columns...)
 	if err != nil {
 		return nil, err
 	}
@@ -46,32 +69,40 @@ func NewTable(path string, indexer LocationIndexer, columns ...Column) (*Table,
 	table.Metadata[CreatedAt] = string(created)
 
 	if err := table.saveTableMetadata(); err != nil {
+		store.Close()
+		os.Remove(filepath.Join(path, store.Name+TableFileExt))
+		removePartialStore(path, store.Name, createdDir)
 		return nil, err
 	}
 	return table, nil
 }
 
 func OpenTable(path string) (*Table, error) {
-	store, err := OpenStore(path)
-	if err != nil {
-		return nil, err
-	}
+	return openTable(path, false)
+}
 
-	// load the table metadata too
-	metaFilePath := filepath.Join(path, store.Name+TableFileExt)
-	metaFile, err := os.Open(metaFilePath)
+// Open the table in the given directory for reading only, which is allowed while the table is
+// open for writing elsewhere, see OpenStoreReadOnly.
+func OpenTableReadOnly(path string) (*Table, error) {
+	return openTable(path, true)
+}
+
+func openTable(path string, readOnly bool) (*Table, error) {
+	store, err := openStore(path, readOnly)
 	if err != nil {
 		return nil, err
 	}
-	defer metaFile.Close()
 
-	jsonText, err := io.ReadAll(metaFile)
+	// load the table metadata too
+	jsonText, _, err := readMetadataFile(filepath.Join(path, store.Name+TableFileExt))
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
 	table := &Table{store: store}
 	err = json.Unmarshal(jsonText, table)
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
 
@@ -86,27 +117,186 @@ func (t *Table) Name() string {
 	return t.store.Name
 }
 
+// The number of rows in the table, one for each location of its indexer.
+func (t *Table) RowCount() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.store.Rows
+}
+
+// The number of bytes the files of the table take up on disk: the data file, which is sized in
+// whole pages ahead of time, and the store and table metadata files.
+func (t *Table) StorageBytes() (int64, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	total := int64(0)
+	for _, ext := range []string{DataFileExt, MetadataFileExt, TableFileExt} {
+		info, err := os.Stat(filepath.Join(t.store.path, t.store.Name+ext))
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// Retrieve the metadata value stored under the given key, and whether the key was present.
+func (t *Table) GetMetadata(key string) (string, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	value, ok := t.Metadata[key]
+	return value, ok
+}
+
+// The keys of all the metadata of the table, including the built-in ProjectionKey and CreatedAt,
+// in sorted order.
+func (t *Table) MetadataKeys() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	keys := maps.Keys(t.Metadata)
+	slices.Sort(keys)
+	return keys
+}
+
+// A copy of all the metadata of the table.
+func (t *Table) AllMetadata() map[string]string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return maps.Clone(t.Metadata)
+}
+
 func (t *Table) SetMetadata(key string, value string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
 	t.Metadata[key] = value
 	return t.saveTableMetadata()
 }
 
+// Remove the key from the metadata and save the metadata without it. Removing a key that is not
+// present does nothing.
+func (t *Table) DeleteMetadata(key string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	if _, ok := t.Metadata[key]; !ok {
+		return nil
+	}
+	delete(t.Metadata, key)
+	return t.saveTableMetadata()
+}
+
+// Store an integer in the metadata under the given key, in base 10.
+func (t *Table) SetMetadataInt(key string, value int64) error {
+	return t.SetMetadata(key, strconv.FormatInt(value, 10))
+}
+
+// Retrieve an integer stored in the metadata under the given key. Returns a MetadataError if the
+// key is missing or its value is not a base 10 integer.
+func (t *Table) GetMetadataInt(key string) (int64, error) {
+	text, err := t.getMetadataText(key)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, NewMetadataError(key, err)
+	}
+	return value, nil
+}
+
+// Store a float in the metadata under the given key, with the fewest digits that parse back to it.
+func (t *Table) SetMetadataFloat(key string, value float64) error {
+	return t.SetMetadata(key, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Retrieve a float stored in the metadata under the given key. Returns a MetadataError if the key
+// is missing or its value is not a number.
+func (t *Table) GetMetadataFloat(key string) (float64, error) {
+	text, err := t.getMetadataText(key)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, NewMetadataError(key, err)
+	}
+	return value, nil
+}
+
+// Store a boolean in the metadata under the given key, as 'true' or 'false'.
+func (t *Table) SetMetadataBool(key string, value bool) error {
+	return t.SetMetadata(key, strconv.FormatBool(value))
+}
+
+// Retrieve a boolean stored in the metadata under the given key. Returns a MetadataError if the
+// key is missing or its value is not a boolean.
+func (t *Table) GetMetadataBool(key string) (bool, error) {
+	text, err := t.getMetadataText(key)
+	if err != nil {
+		return false, err
+	}
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, NewMetadataError(key, err)
+	}
+	return value, nil
+}
+
+// Store a time in the metadata under the given key, in RFC 3339 format with nanoseconds.
+func (t *Table) SetMetadataTime(key string, value time.Time) error {
+	return t.SetMetadata(key, value.Format(time.RFC3339Nano))
+}
+
+// Retrieve a time stored in the metadata under the given key, such as the CreatedAt time of the
+// table. Returns a MetadataError if the key is missing or its value is not an RFC 3339 time.
+func (t *Table) GetMetadataTime(key string) (time.Time, error) {
+	text, err := t.getMetadataText(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return time.Time{}, NewMetadataError(key, err)
+	}
+	return value, nil
+}
+
+func (t *Table) getMetadataText(key string) (string, error) {
+	text, ok := t.GetMetadata(key)
+	if !ok {
+		return "", NewMetadataError(key, ErrMetadataNotFound)
+	}
+	return text, nil
+}
+
 // Save the table metadata alongside the store metadata and data file.
 func (t *Table) saveTableMetadata() error {
+	if t.store.file.readOnly {
+		return ErrReadOnly
+	}
 	jsonData, err := json.Marshal(t)
 	if err != nil {
 		return err
 	}
-	tableFilePath := filepath.Join(t.store.path, t.store.Name+TableFileExt)
-	tableFile, err := os.OpenFile(tableFilePath, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		return err
+	return writeMetadataFile(filepath.Join(t.store.path, t.store.Name+TableFileExt), jsonData, t.store.compressMeta)
+}
+
+// Choose whether the metadata files of the table are gzipped, rewriting them in the chosen form.
+func (t *Table) SetMetadataCompression(compress bool) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return ErrClosed
 	}
-	defer tableFile.Close()
-	if _, err = tableFile.Write(jsonData); err != nil {
+	if err := t.store.SetMetadataCompression(compress); err != nil {
 		return err
 	}
-	return nil
+	return t.saveTableMetadata()
 }
 
 func (t *Table) UnmarshalJSON(b []byte) error {
@@ -152,6 +342,13 @@ func (t *Table) UnmarshalJSON(b []byte) error {
 			return err
 		}
 		t.Indexer = c
+	case "stereographic":
+		var s StereographicIndexer
+		err = json.Unmarshal(*objMap["indexer"], &s)
+		if err != nil {
+			return err
+		}
+		t.Indexer = s
 	case "flat-healpix":
 		var h FlatHealpixIndexer
 		err = json.Unmarshal(*objMap["indexer"], &h)
@@ -159,6 +356,13 @@ func (t *Table) UnmarshalJSON(b []byte) error {
 			return err
 		}
 		t.Indexer = h
+	case "multires-healpix":
+		var h MultiResHealpixIndexer
+		err = json.Unmarshal(*objMap["indexer"], &h)
+		if err != nil {
+			return err
+		}
+		t.Indexer = h
 	default:
 		return fmt.Errorf("pixidb: unknown table indexer scheme encountered while loading")
 	}
@@ -166,61 +370,298 @@ func (t *Table) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Delete the files of the table. Tables opened through a database return ErrManagedTable, and
+// must be dropped with Database.Drop so the database stops handing them out.
 func (t *Table) Drop() error {
+	if t.managed {
+		return ErrManagedTable
+	}
+	return t.drop()
+}
+
+func (t *Table) drop() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.store.Drop()
 }
 
 func (t *Table) GetRows(projectedColumns []string, locations ...Location) (ResultSet, error) {
+	return t.GetRowsCtx(context.Background(), projectedColumns, locations...)
+}
+
+// Same as GetRows, but periodically checks the context while reading and returns the
+// context error if it has been cancelled, which is useful for queries over large regions.
+func (t *Table) GetRowsCtx(ctx context.Context, projectedColumns []string, locations ...Location) (ResultSet, error) {
+	return t.QueryCtx(ctx, projectedColumns, nil, locations...)
+}
+
+// Same as GetRows, but only returns the rows that satisfy every one of the filters, in the order
+// of their locations. The filtered columns need not be among the projected columns.
+func (t *Table) Query(projectedColumns []string, filters []FilterClause, locations ...Location) (ResultSet, error) {
+	return t.QueryCtx(context.Background(), projectedColumns, filters, locations...)
+}
+
+// Same as Query, but periodically checks the context while reading and returns the context
+// error if it has been cancelled.
+func (t *Table) QueryCtx(ctx context.Context, projectedColumns []string, filters []FilterClause, locations ...Location) (ResultSet, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 	columnProj, err := t.store.Projection(projectedColumns...)
 	if err != nil {
 		return ResultSet{}, err
 	}
-	rows := make([][]Value, len(locations))
+	rowFilters, err := t.store.rowFilters(filters)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	rows, err := t.queryRows(ctx, columnProj, rowFilters, locations)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	return ResultSet{
+		Columns: t.store.FilterColumns(columnProj),
+		Rows:    rows,
+	}, nil
+}
+
+// Same as GetRows, but splits the locations between the given number of goroutines that index
+// them and read their rows concurrently, which can speed up queries over many scattered
+// locations. The rows are in the order of their locations, as with GetRows. A worker count
+// below one uses GOMAXPROCS workers.
+func (t *Table) GetRowsParallel(projectedColumns []string, workers int, locations ...Location) (ResultSet, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(1, min(workers, len(locations)))
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	columnProj, err := t.store.Projection(projectedColumns...)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	chunkSize := (len(locations) + workers - 1) / workers
+	chunks := make([][][]Value, workers)
+	errs := make([]error, workers)
+	var wait sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := min(w*chunkSize, len(locations))
+		end := min(start+chunkSize, len(locations))
+		wait.Add(1)
+		go func(w int, locs []Location) {
+			defer wait.Done()
+			chunks[w], errs[w] = t.queryRows(context.Background(), columnProj, nil, locs)
+		}(w, locations[start:end])
+	}
+	wait.Wait()
+
+	rows := make([][]Value, 0, len(locations))
+	for w := range chunks {
+		if errs[w] != nil {
+			return ResultSet{}, errs[w]
+		}
+		rows = append(rows, chunks[w]...)
+	}
+	return ResultSet{
+		Columns: t.store.FilterColumns(columnProj),
+		Rows:    rows,
+	}, nil
+}
+
+// Columns of a table resolved once by Prepare, for reuse across many reads of the same columns.
+type PreparedColumns struct {
+	table   *Table
+	proj    Projection
+	columns []Column
+}
+
+// Resolve the named columns once, so that repeated reads of them with GetRowsPrepared skip
+// looking up every column by name on each read.
+func (t *Table) Prepare(columns []string) (*PreparedColumns, error) {
+	proj, err := t.store.Projection(columns...)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedColumns{
+		table:   t,
+		proj:    proj,
+		columns: t.store.FilterColumns(proj),
+	}, nil
+}
+
+// Same as GetRows, for columns prepared on this table with Prepare. The Columns of every result
+// set are shared with the prepared columns and must not be modified.
+func (t *Table) GetRowsPrepared(prepared *PreparedColumns, locations ...Location) (ResultSet, error) {
+	if prepared.table != t {
+		return ResultSet{}, fmt.Errorf("pixidb: columns prepared for table '%s' cannot be read from table '%s'", prepared.table.Name(), t.Name())
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	rows, err := t.queryRows(context.Background(), prepared.proj, nil, locations)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	return ResultSet{
+		Columns: prepared.columns,
+		Rows:    rows,
+	}, nil
+}
+
+// Read the projected columns of the rows at the locations that satisfy every filter. Must be
+// called holding the lock.
+func (t *Table) queryRows(ctx context.Context, columnProj Projection, rowFilters []rowFilter, locations []Location) ([][]Value, error) {
+	rows := make([][]Value, 0, len(locations))
 	for i, loc := range locations {
+		if i%ContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
 		locIndex, err := t.Indexer.ToIndex(loc)
 		if err != nil {
-			return ResultSet{}, err
+			return nil, err
 		}
 		rawRow, err := t.store.GetRowAt(locIndex)
 		if err != nil {
-			return ResultSet{}, err
+			return nil, err
+		}
+		if matchesAll(rawRow, rowFilters) {
+			rows = append(rows, rawRow.Project(columnProj))
 		}
-		projRow := rawRow.Project(columnProj)
-		rows[i] = projRow
 	}
-	return ResultSet{
-		Columns: t.store.FilterColumns(columnProj),
-		Rows:    rows,
-	}, nil
+	return rows, nil
+}
+
+// Count the locations at which the column satisfies the predicate, reading only that column and
+// without building a result set. The predicate is evaluated on the given column, so its Column
+// may be left empty, but if set it must name the same column.
+func (t *Table) CountWhere(column string, pred FilterClause, locations ...Location) (int, error) {
+	if pred.Column == "" {
+		pred.Column = column
+	} else if pred.Column != column {
+		return 0, fmt.Errorf("pixidb: predicate on column '%s' cannot count column '%s'", pred.Column, column)
+	}
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	filters, err := t.store.rowFilters([]FilterClause{pred})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, loc := range locations {
+		locIndex, err := t.Indexer.ToIndex(loc)
+		if err != nil {
+			return 0, err
+		}
+		val, err := t.store.getColumnAt(locIndex, filters[0].column)
+		if err != nil {
+			return 0, err
+		}
+		if filters[0].matches(val) {
+			count++
+		}
+	}
+	return count, nil
 }
 
+// Write the values of the given columns into the row at each location, the values at each
+// position being written to the location at the same position. Returns a LocationCountError if
+// there are not as many locations as rows of values, and a BatchRowError wrapping a
+// ValueCountError if a row of values does not hold a value for each column, before any row is
+// written. Returns the number of rows written.
 func (t *Table) SetRows(columns []string, locations []Location, values [][]Value) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	columnProj, err := t.store.Projection(columns...)
 	if err != nil {
 		return 0, err
 	}
+	if len(locations) != len(values) {
+		return 0, NewLocationCountError(len(locations), len(values))
+	}
+	for i, row := range values {
+		if len(row) != len(columnProj) {
+			return 0, NewBatchRowError(i, NewValueCountError(len(row), len(columnProj)))
+		}
+	}
 	for i, loc := range locations {
 		rowInd, err := t.Indexer.ToIndex(loc)
 		if err != nil {
 			return i, err
 		}
-		rawRow, err := t.store.GetRowAt(rowInd)
+		err = t.store.ModifyRowAt(rowInd, func(rawRow Row) {
+			for vInd, c := range columnProj {
+				copy(rawRow[c.start:c.start+c.size], values[i][vInd])
+			}
+		})
 		if err != nil {
 			return i, err
 		}
+	}
+	return len(locations), nil
+}
 
+// Write the same values of the given columns into the row at every location, such as to flood
+// fill a region. Every location is indexed and every value checked against the size of its
+// column before any row is written, and the rows are written grouped by page. Returns the number
+// of rows written.
+func (t *Table) SetRowsUniform(columns []string, value []Value, locations ...Location) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	columnProj, err := t.store.Projection(columns...)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) != len(columnProj) {
+		return 0, NewValueCountError(len(value), len(columnProj))
+	}
+	for vInd, c := range columnProj {
+		if len(value[vInd]) != c.size {
+			return 0, NewValueSizeError(columns[vInd], len(value[vInd]), c.size)
+		}
+	}
+	indices := make([]int, len(locations))
+	for i, loc := range locations {
+		index, err := t.Indexer.ToIndex(loc)
+		if err != nil {
+			return 0, err
+		}
+		indices[i] = index
+	}
+	return t.store.modifyRowsAt(indices, func(_ int, rawRow Row) {
 		for vInd, c := range columnProj {
-			copy(rawRow[c.start:c.start+c.size], values[i][vInd])
+			copy(rawRow[c.start:c.start+c.size], value[vInd])
 		}
-		err = t.store.SetRowAt(rowInd, rawRow)
+	})
+}
+
+// Write the default value of every column into the rows at the locations, such as to clear a
+// region for recomputation. Every location is indexed before any row is reset, so a location the
+// table does not support leaves all rows unchanged.
+func (t *Table) ResetRegion(locations ...Location) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	indices := make([]int, len(locations))
+	for i, loc := range locations {
+		index, err := t.Indexer.ToIndex(loc)
 		if err != nil {
-			return i, err
+			return err
 		}
+		indices[i] = index
 	}
-	return len(locations), nil
+	for _, index := range indices {
+		if err := t.store.ResetRowAt(index); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (t *Table) SetValue(column string, location Location, value Value) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	rowInd, err := t.Indexer.ToIndex(location)
 	if err != nil {
 		return err
@@ -228,6 +669,314 @@ func (t *Table) SetValue(column string, location Location, value Value) error {
 	return t.store.SetValueAt(column, rowInd, value)
 }
 
-func (t *Table) Checkpoint() error {
+// Read the value of a single column at the location, without building a result set.
+func (t *Table) GetValue(column string, location Location) (Value, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	rowInd, err := t.Indexer.ToIndex(location)
+	if err != nil {
+		return nil, err
+	}
+	return t.store.GetColumnValueAt(column, rowInd)
+}
+
+// Read a column at each point of a track, such as the ground track of a satellite, returning the
+// values in the order of the points. The points are all indexed first and then read grouped by
+// page, rather than one at a time. Points outside the table do not fail the call, and instead get
+// the no-data value of the column, or nil if the column has none.
+func (t *Table) SampleTrack(column string, points []SphericalLocation) ([]Value, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	columnProj, err := t.store.Projection(column)
+	if err != nil {
+		return nil, err
+	}
+	noData := t.store.FilterColumns(columnProj)[0].NoData
+
+	indices := make([]int, 0, len(points))
+	inside := make([]int, 0, len(points))
+	for i, point := range points {
+		index, err := t.Indexer.ToIndex(point)
+		var boundsErr LocationOutOfBoundsError
+		if errors.As(err, &boundsErr) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		indices = append(indices, index)
+		inside = append(inside, i)
+	}
+	found, err := t.store.getColumnsAt(indices, columnProj[0])
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make([]Value, len(points))
+	for i, point := range inside {
+		vals[point] = found[i]
+	}
+	for i := range vals {
+		if vals[i] == nil {
+			// each point outside gets its own copy, so changing one leaves the others alone
+			vals[i] = slices.Clone(noData)
+		}
+	}
+	return vals, nil
+}
+
+// Sample a column at an arbitrary location by bilinearly interpolating between the four
+// pixels surrounding it, decoding each value as a float64. Only indexers that lay their pixels
+// out on a regular projected grid support interpolation; other indexers return a
+// LocationNotSupportedError, and NearestIndex can be used with them instead.
+func (t *Table) SampleBilinear(column string, loc SphericalLocation) (float64, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.sampleBilinear(column, loc)
+}
+
+// Bilinearly sample the column at the location. The caller must hold the table lock.
+func (t *Table) sampleBilinear(column string, loc SphericalLocation) (float64, error) {
+	gridded, ok := t.Indexer.(griddedIndexer)
+	if !ok {
+		return 0, NewLocationNotSupportedError(t.Indexer.Name(), loc)
+	}
+	columnProj, err := t.store.Projection(column)
+	if err != nil {
+		return 0, err
+	}
+	columnType := t.store.FilterColumns(columnProj)[0].Type
+	xPix, yPix, err := gridded.fractionalPixel(loc)
+	if err != nil {
+		return 0, err
+	}
+
+	grid := gridded.pixelGrid()
+	x0 := min(int(xPix), max(grid.Width-2, 0))
+	y0 := min(int(yPix), max(grid.Height-2, 0))
+	x1 := min(x0+1, grid.Width-1)
+	y1 := min(y0+1, grid.Height-1)
+	corners := [4]GridLocation{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}}
+	var values [4]float64
+	for i, corner := range corners {
+		index, err := grid.ToIndex(corner)
+		if err != nil {
+			return 0, err
+		}
+		row, err := t.store.GetRowAt(index)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = columnType.DecodeFloat64(row.Project(columnProj)[0])
+	}
+
+	fx := xPix - float64(x0)
+	fy := yPix - float64(y0)
+	bottom := values[0]*(1-fx) + values[1]*fx
+	top := values[2]*(1-fx) + values[3]*fx
+	return bottom*(1-fy) + top*fy, nil
+}
+
+// Aggregate the decoded values of the column over the pixels with centers in the region. Values
+// matching the no-data sentinel of the column and NaN values are not counted. Indexers without
+// locations on the sphere return the error of their ToLocation.
+func (t *Table) ZonalStats(column string, region Region) (Stats, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.closed {
+		return Stats{}, ErrClosed
+	}
+
+	covered := make([]bool, t.Indexer.Size())
+	for i := range covered {
+		loc, err := t.Indexer.ToLocation(i)
+		if err != nil {
+			return Stats{}, err
+		}
+		covered[i] = region.Contains(loc)
+	}
+	proj, err := t.store.Projection(column)
+	if err != nil {
+		return Stats{}, err
+	}
+	col := t.store.FilterColumns(proj)[0]
+	acc := newStatsAccumulator()
+	err = t.store.forEachValue(proj[0], func(index int, val Value) {
+		if !covered[index] || col.IsNoData(val) {
+			return
+		}
+		if f := col.Type.DecodeFloat64(val); !math.IsNaN(f) {
+			acc.add(f)
+		}
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	return acc.result(), nil
+}
+
+// Rewrite a flat HEALPix table so its rows are ordered by the given numbering scheme, moving
+// the row of each pixel to the index of the same pixel under the new scheme and updating the
+// indexer to match. The whole table is held in memory during the rewrite. Errors with an
+// IndexerNotSupportedError for tables with any other indexer, and does nothing if the table
+// already uses the scheme. If saving the new indexer fails, the rows are moved back.
+func (t *Table) ReindexHealpix(newScheme healpix.HealpixScheme) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	oldIndexer, ok := t.Indexer.(FlatHealpixIndexer)
+	if !ok {
+		return NewIndexerNotSupportedError(t.IndexerName, "healpix reindexing")
+	}
+	if oldIndexer.Scheme == newScheme {
+		return nil
+	}
+
+	oldRows := make([]Row, 0, t.store.Rows)
+	err := t.store.ScanRows(func(index int, row Row) error {
+		oldRows = append(oldRows, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the old index of the pixel at each new index
+	source := make([]int, len(oldRows))
+	for nest := range source {
+		ring := healpixNestToRing(oldIndexer.Order, nest)
+		if newScheme == healpix.RingScheme {
+			source[ring] = nest
+		} else {
+			source[nest] = ring
+		}
+	}
+	err = t.store.modifyEachRow(func(index int, row Row) error {
+		copy(row, oldRows[source[index]])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	t.Indexer = NewFlatHealpixIndexer(oldIndexer.Order, newScheme)
+	if err := t.saveTableMetadata(); err != nil {
+		t.Indexer = oldIndexer
+		t.store.modifyEachRow(func(index int, row Row) error {
+			copy(row, oldRows[index])
+			return nil
+		})
+		return err
+	}
+	return nil
+}
+
+// Checkpoint the table and copy its data and metadata files into a new table directory at the
+// given path, which can then be opened with OpenTable. The copied files are named after the
+// directory, like those of any other table. The caller must hold the table lock so that no writes
+// interleave with the copy.
+func (t *Table) copyTo(path string) error {
+	if _, err := t.store.Checkpoint(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	for _, ext := range []string{DataFileExt, MetadataFileExt, TableFileExt} {
+		srcPath := filepath.Join(t.store.path, t.store.Name+ext)
+		if err := copyFile(srcPath, filepath.Join(path, name+ext)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move the table into a new directory at the given path, renaming its files to match the name
+// the path gives the table. If a rename fails, the renames that succeeded are undone. The caller
+// must hold the table lock so that no reads or writes happen during the move.
+func (t *Table) moveTo(path string) error {
+	name := filepath.Base(path)
+	if _, err := os.Stat(path); err == nil {
+		return NewTableExistsError(name)
+	}
+
+	oldPath, oldName := t.store.path, t.store.Name
+	renamed := make([]string, 0, 3)
+	undo := func() {
+		for _, ext := range renamed {
+			os.Rename(filepath.Join(oldPath, name+ext), filepath.Join(oldPath, oldName+ext))
+		}
+	}
+	for _, ext := range []string{DataFileExt, MetadataFileExt, TableFileExt} {
+		if err := os.Rename(filepath.Join(oldPath, oldName+ext), filepath.Join(oldPath, name+ext)); err != nil {
+			undo()
+			return err
+		}
+		renamed = append(renamed, ext)
+	}
+	if err := os.Rename(oldPath, path); err != nil {
+		undo()
+		return err
+	}
+
+	t.store.Name = name
+	t.store.path = path
+	t.store.file.Relocate(filepath.Join(path, name+DataFileExt))
+	return nil
+}
+
+func copyFile(srcPath string, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	if _, err := io.Copy(dest, src); err != nil {
+		return err
+	}
+	return dest.Close()
+}
+
+// Flush the table to disk and close it, after which reads and writes return ErrClosed. Closing
+// an already closed table does nothing.
+func (t *Table) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if err := t.store.Close(); err != nil {
+		return err
+	}
+	t.closed = true
+	return nil
+}
+
+// Write every dirty page of the table to its data file, returning the number of pages written.
+func (t *Table) Checkpoint() (int, error) {
 	return t.store.Checkpoint()
 }
+
+// Same as Checkpoint, but stops early with the context error if the context is cancelled.
+func (t *Table) CheckpointCtx(ctx context.Context) (int, error) {
+	return t.store.CheckpointCtx(ctx)
+}
+
+// Same as Checkpoint, but always syncs to the disk regardless of the sync mode, so that every
+// write to the table is durable once it returns.
+func (t *Table) CheckpointDurable() (int, error) {
+	return t.store.CheckpointDurable()
+}
+
+// Change when the table syncs written pages to the disk, see SyncMode.
+func (t *Table) SetSyncMode(mode SyncMode) {
+	t.store.SetSyncMode(mode)
+}
+
+// Change how many pages sequential scans of the table read ahead, see Pagemaster.SetPrefetchDepth.
+func (t *Table) SetPrefetchDepth(depth int) {
+	t.store.SetPrefetchDepth(depth)
+}
@@ -1,11 +1,16 @@
 package pixidb
 
 import (
+	"context"
+	"errors"
 	"math"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/owlpinetech/flatsphere"
 	"github.com/owlpinetech/healpix"
@@ -28,6 +33,7 @@ func TestTableOpen(t *testing.T) {
 		{"mercatortagless", NewMercatorCutoffIndexer(math.Pi/4, -math.Pi/4, 10, 10, true), map[string]string{}, flatsphere.NewMercator()},
 		{"cyleqtags", NewCylindricalEquirectangularIndexer(0, 10, 10, true), map[string]string{"one": "fish", "two": "fish"}, flatsphere.NewCylindricalEqualArea(0)},
 		{"healpixtagged", NewFlatHealpixIndexer(2, healpix.NestScheme), map[string]string{"hello": "there"}, flatsphere.NewHEALPixStandard()},
+		{"stereographic", NewStereographicIndexer(false, -math.Pi/3, 10, 10, true), map[string]string{}, flatsphere.NewStereographic()},
 	}
 
 	for _, tc := range testCases {
@@ -39,6 +45,9 @@ func TestTableOpen(t *testing.T) {
 			for k, v := range tc.metadata {
 				orig.SetMetadata(k, v)
 			}
+			if err := orig.Close(); err != nil {
+				t.Fatal(err)
+			}
 
 			tbl, err := OpenTable(filepath.Join(dir, tc.name))
 			if err != nil {
@@ -268,11 +277,11 @@ func TestTableSetAllPersist(t *testing.T) {
 		}
 	}
 
-	if err = tbl.Checkpoint(); err != nil {
+	if _, err := tbl.Checkpoint(); err != nil {
 		t.Fatal(err)
 	}
 
-	opened, err := OpenTable(filepath.Join(dir, "querytbl"))
+	opened, err := OpenTableReadOnly(filepath.Join(dir, "querytbl"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -288,3 +297,1001 @@ func TestTableSetAllPersist(t *testing.T) {
 		}
 	}
 }
+
+func TestTableGetRowsCancel(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_get_rows_cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "canceltbl"), NewProjectionlessIndexer(100, 100, true), NewColumnInt32("col1", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := make([]Location, tbl.store.Rows)
+	for i := range locations {
+		locations[i] = IndexLocation(i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tbl.GetRowsCtx(ctx, []string{"col1"}, locations...); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled query to return context.Canceled, got %v", err)
+	}
+
+	midway := &countdownContext{Context: context.Background(), remaining: 3}
+	if _, err := tbl.GetRowsCtx(midway, []string{"col1"}, locations...); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected query cancelled midway to return context.Canceled, got %v", err)
+	}
+
+	res, err := tbl.GetRowsCtx(context.Background(), []string{"col1"}, locations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != len(locations) {
+		t.Errorf("expected %d rows from uncancelled query, got %d", len(locations), len(res.Rows))
+	}
+}
+
+func TestTableBoundaryModePersist(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_boundary_persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mercator := NewMercatorCutoffIndexer(math.Pi/3, -math.Pi/4, 20, 10, true)
+	mercator.Boundary = BoundaryClamp
+	cylindrical := NewCylindricalEquirectangularIndexer(math.Pi/6, 20, 10, false)
+	cylindrical.Boundary = BoundaryClamp
+
+	testCases := []struct {
+		name    string
+		indexer LocationIndexer
+	}{
+		{"mercator", mercator},
+		{"cylindrical", cylindrical},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			created, err := NewTable(filepath.Join(dir, tc.name), tc.indexer, NewColumnInt32("col1", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := created.Close(); err != nil {
+				t.Fatal(err)
+			}
+			opened, err := OpenTable(filepath.Join(dir, tc.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(opened.Indexer, tc.indexer) {
+				t.Errorf("expected reopened indexer %+v, got %+v", tc.indexer, opened.Indexer)
+			}
+
+			// clamping beyond the pole and the antimeridian still works after reopening
+			for _, loc := range []Location{
+				SphericalLocation{math.Pi / 2, math.Pi},
+				ProjectedLocation{math.Pi + 0.1, math.Pi},
+				SphericalLocation{-math.Pi / 2, -math.Pi},
+			} {
+				expect, err := tc.indexer.ToIndex(loc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkInd(t, opened.Indexer, loc, expect)
+			}
+		})
+	}
+}
+
+func TestTableMultiResHealpixReopen(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_multires_reopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	order2, order4 := healpix.HealpixOrder(2), healpix.HealpixOrder(4)
+	pixels := []healpix.UniquePixel{}
+	for n := 0; n < 20; n++ {
+		pixels = append(pixels, healpix.NestPixel(3*n).ToUniquePixel(order4), healpix.NestPixel(100+n).ToUniquePixel(order2))
+	}
+
+	tbl, err := NewTable(filepath.Join(dir, "multires"), NewMultiResHealpixIndexer(pixels...), NewColumnInt64("col1", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pixels {
+		if _, err := tbl.SetRows([]string{"col1"}, []Location{UniqueLocation(p)}, [][]Value{{NewInt64Value(int64(p))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tbl.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := OpenTableReadOnly(filepath.Join(dir, "multires"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Indexer.Size() != len(pixels) {
+		t.Errorf("expected %d pixels after reopen, got %d", len(pixels), opened.Indexer.Size())
+	}
+	for _, p := range pixels {
+		checkInd(t, opened.Indexer, UniqueLocation(p), mustIndex(t, tbl.Indexer, UniqueLocation(p)))
+		res, err := opened.GetRows([]string{"col1"}, UniqueLocation(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Rows[0][0].AsInt64() != int64(p) {
+			t.Errorf("expected pixel %d to hold its own id after reopen, got %d", p, res.Rows[0][0].AsInt64())
+		}
+	}
+}
+
+func mustIndex(t *testing.T, indexer LocationIndexer, loc Location) int {
+	ind, err := indexer.ToIndex(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ind
+}
+
+func TestTableSampleBilinear(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_sample_bilinear")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// ten degree pixels, so that fractional pixel positions follow directly from the degrees
+	indexer := NewCylindricalEquirectangularIndexer(0, 37, 19, true)
+	tbl, err := NewTable(filepath.Join(dir, "ramp"), indexer, NewColumnFloat64("ramp", 0), NewColumnInt16("steps", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 37; x++ {
+		for y := 0; y < 19; y++ {
+			loc := GridLocation{x, y}
+			if err := tbl.SetValue("ramp", loc, NewFloat64Value(2*float64(x)+3*float64(y))); err != nil {
+				t.Fatal(err)
+			}
+			if err := tbl.SetValue("steps", loc, NewInt16Value(int16(x-y))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	degrees := math.Pi / 180
+	testCases := []struct {
+		lat   float64
+		lon   float64
+		ramp  float64
+		steps float64
+	}{
+		{-90, -180, 0, 0},
+		{90, 180, 2*36 + 3*18, 36 - 18},
+		{0, 0, 2*18 + 3*9, 18 - 9},
+		{5, 5, 2*18.5 + 3*9.5, 18.5 - 9.5},
+		{-42.5, 117.5, 2*29.75 + 3*4.75, 29.75 - 4.75},
+		{89, -179, 2*0.1 + 3*17.9, 0.1 - 17.9},
+	}
+	for _, tc := range testCases {
+		loc := SphericalLocation{tc.lat * degrees, tc.lon * degrees}
+		ramp, err := tbl.SampleBilinear("ramp", loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(ramp-tc.ramp) > 1e-9 {
+			t.Errorf("expected ramp %f at (%f, %f), got %f", tc.ramp, tc.lat, tc.lon, ramp)
+		}
+		steps, err := tbl.SampleBilinear("steps", loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(steps-tc.steps) > 1e-9 {
+			t.Errorf("expected steps %f at (%f, %f), got %f", tc.steps, tc.lat, tc.lon, steps)
+		}
+	}
+
+	if _, err := tbl.SampleBilinear("missing", SphericalLocation{}); err == nil {
+		t.Errorf("expected error sampling a missing column")
+	}
+
+	mercator, err := NewTable(filepath.Join(dir, "mercator"), NewMercatorCutoffIndexer(math.Pi/4, -math.Pi/4, 10, 10, true), NewColumnFloat32("value", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mercator.SampleBilinear("value", SphericalLocation{math.Pi / 3, 0}); err == nil {
+		t.Errorf("expected error sampling beyond the mercator cutoff")
+	}
+
+	healpixTbl, err := NewTable(filepath.Join(dir, "healpix"), NewFlatHealpixIndexer(2, healpix.NestScheme), NewColumnFloat32("value", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notSupported *LocationNotSupportedError
+	if _, err := healpixTbl.SampleBilinear("value", SphericalLocation{}); !errors.As(err, &notSupported) {
+		t.Errorf("expected healpix sampling to be unsupported, got %v", err)
+	}
+}
+
+func TestTableTypedMetadata(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_typed_metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "typed")
+	tbl, err := NewTable(path, NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := time.Date(2019, time.November, 2, 8, 15, 30, 250000000, time.UTC)
+	if err := tbl.SetMetadataInt("scenes", -4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadataTime("acquired", acquired); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadataFloat("scale", 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadataBool("calibrated", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetMetadata("label", "not a number"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if scenes, err := reopened.GetMetadataInt("scenes"); err != nil || scenes != -4096 {
+		t.Errorf("expected scenes -4096, got %d (%v)", scenes, err)
+	}
+	if when, err := reopened.GetMetadataTime("acquired"); err != nil || !when.Equal(acquired) {
+		t.Errorf("expected acquired %v, got %v (%v)", acquired, when, err)
+	}
+	if scale, err := reopened.GetMetadataFloat("scale"); err != nil || scale != 0.1 {
+		t.Errorf("expected scale 0.1, got %v (%v)", scale, err)
+	}
+	if calibrated, err := reopened.GetMetadataBool("calibrated"); err != nil || !calibrated {
+		t.Errorf("expected calibrated true, got %v (%v)", calibrated, err)
+	}
+	if _, err := reopened.GetMetadataTime(CreatedAt); err != nil {
+		t.Errorf("expected created-at to parse as a time, got %v", err)
+	}
+
+	var metaErr MetadataError
+	if _, err := reopened.GetMetadataInt("label"); !errors.As(err, &metaErr) || metaErr.Key != "label" {
+		t.Errorf("expected metadata error for label, got %v", err)
+	}
+	if _, err := reopened.GetMetadataTime("missing"); !errors.Is(err, ErrMetadataNotFound) {
+		t.Errorf("expected metadata not found error, got %v", err)
+	}
+}
+
+func TestNewTableCleanup(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a directory in the place of the table file makes saving the table metadata fail
+	path := filepath.Join(dir, "blocked")
+	if err := os.MkdirAll(filepath.Join(path, "blocked"+TableFileExt), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTable(path, NewProjectionlessIndexer(10, 10, true), NewColumnInt32("value", 0)); err == nil {
+		t.Fatal("expected error saving table metadata")
+	}
+	for _, ext := range []string{DataFileExt, MetadataFileExt} {
+		if _, err := os.Stat(filepath.Join(path, "blocked"+ext)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s file removed after failure, got %v", ext, err)
+		}
+	}
+}
+
+func TestTableStorageBytes(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_storage_bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	testCases := []struct {
+		name    string
+		indexer LocationIndexer
+		columns []Column
+	}{
+		{"small", NewProjectionlessIndexer(10, 10, true), []Column{NewColumnInt8("a", 0)}},
+		{"paged", NewProjectionlessIndexer(300, 100, true), []Column{NewColumnFloat64("b", 0), NewColumnInt32("c", 0)}},
+	}
+
+	total := int64(0)
+	for _, tc := range testCases {
+		if err := db.Create(tc.name, tc.indexer, tc.columns...); err != nil {
+			t.Fatal(err)
+		}
+		tbl, err := db.Table(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tbl.RowCount() != tc.indexer.Size() {
+			t.Errorf("%s: expected %d rows, got %d", tc.name, tc.indexer.Size(), tbl.RowCount())
+		}
+
+		rowSize := 0
+		for _, c := range tc.columns {
+			rowSize += c.Size()
+		}
+		pages := tc.indexer.Size()/(DefaultPageSize()/rowSize) + 1
+		expect := int64(pages * (DefaultPageSize() + ChecksumSize))
+		for _, ext := range []string{MetadataFileExt, TableFileExt} {
+			info, err := os.Stat(filepath.Join(dir, tc.name, tc.name+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect += info.Size()
+		}
+
+		size, err := tbl.StorageBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != expect {
+			t.Errorf("%s: expected %d bytes for %d pages and metadata, got %d", tc.name, expect, pages, size)
+		}
+		total += size
+	}
+
+	dbTotal, err := db.TotalBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbTotal != total {
+		t.Errorf("expected database total of %d bytes, got %d", total, dbTotal)
+	}
+}
+
+func TestTableGetRowsPrepared(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_prepared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "prepared"), NewProjectionlessIndexer(50, 40, true),
+		NewColumnInt32("a", 0), NewColumnFloat64("b", 0), NewColumnUint8("c", 9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	other, err := NewTable(filepath.Join(dir, "other"), NewProjectionlessIndexer(5, 5, true), NewColumnInt32("a", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	locations := make([]Location, 0, 2000)
+	values := make([][]Value, 0, 2000)
+	for i := 0; i < 2000; i += 3 {
+		locations = append(locations, IndexLocation(i))
+		values = append(values, []Value{NewInt32Value(int32(-i)), NewFloat64Value(float64(i) / 8)})
+	}
+	if _, err := tbl.SetRows([]string{"a", "b"}, locations, values); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []string{"c", "b", "a", "b"}
+	prepared, err := tbl.Prepare(columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, locs := range [][]Location{locations, {IndexLocation(1), IndexLocation(1999)}, {}} {
+		direct, err := tbl.GetRows(columns, locs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := tbl.GetRowsPrepared(prepared, locs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result, direct) {
+			t.Errorf("expected prepared result to match direct result over %d locations", len(locs))
+		}
+	}
+
+	if _, err := tbl.Prepare([]string{"a", "missing"}); err == nil {
+		t.Errorf("expected error preparing a missing column")
+	}
+	if _, err := other.GetRowsPrepared(prepared, IndexLocation(0)); err == nil {
+		t.Errorf("expected error reading columns prepared for another table")
+	}
+}
+
+func benchmarkTable(b *testing.B) (*Table, []string, []Location, func()) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+	columns := make([]Column, 12)
+	names := make([]string, len(columns))
+	for i := range columns {
+		names[i] = "column" + strconv.Itoa(i)
+		columns[i] = NewColumnInt32(names[i], int32(i))
+	}
+	tbl, err := NewTable(filepath.Join(dir, "bench"), NewProjectionlessIndexer(100, 100, true), columns...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	locations := []Location{IndexLocation(7), IndexLocation(4000), IndexLocation(9999)}
+	return tbl, names[2:10], locations, func() {
+		tbl.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func BenchmarkTableGetRows(b *testing.B) {
+	tbl, columns, locations, cleanup := benchmarkTable(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tbl.GetRows(columns, locations...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTableGetRowsPrepared(b *testing.B) {
+	tbl, columns, locations, cleanup := benchmarkTable(b)
+	defer cleanup()
+	prepared, err := tbl.Prepare(columns)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tbl.GetRowsPrepared(prepared, locations...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTableGetValue(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_get_value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "points"), NewCylindricalEquirectangularIndexer(0, 60, 30, true),
+		NewColumnInt16("height", 0), NewColumnFloat32("rain", 0.5), NewColumnUint8("class", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	for i := 0; i < tbl.RowCount(); i += 7 {
+		if err := tbl.SetValue("height", IndexLocation(i), NewInt16Value(int16(i-900))); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.SetValue("rain", IndexLocation(i), NewFloat32Value(float32(i)/3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locations := []Location{
+		IndexLocation(0), IndexLocation(7), IndexLocation(8), IndexLocation(1799),
+		SphericalLocation{Latitude: 0.3, Longitude: -2.1}, SphericalLocation{Latitude: -1.2, Longitude: 3},
+	}
+	for _, column := range []string{"height", "rain", "class"} {
+		for _, loc := range locations {
+			val, err := tbl.GetValue(column, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tbl.GetRows([]string{column}, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(val, result.Rows[0][0]) {
+				t.Errorf("expected %s at %v to be %v, got %v", column, loc, result.Rows[0][0], val)
+			}
+		}
+	}
+
+	var notFound *ColumnNotFoundError
+	if _, err := tbl.GetValue("missing", IndexLocation(0)); !errors.As(err, &notFound) {
+		t.Errorf("expected column not found error, got %v", err)
+	}
+	if _, err := tbl.GetValue("height", IndexLocation(1800)); !errors.As(err, &IndexOutOfRangeError{}) {
+		t.Errorf("expected index out of range error, got %v", err)
+	}
+}
+
+func TestTableGetRowsParallel(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_parallel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "parallel"), NewCylindricalEquirectangularIndexer(0, 400, 200, true),
+		NewColumnInt32("id", 0), NewColumnFloat64("level", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	ids := make([]Location, tbl.RowCount())
+	values := make([][]Value, tbl.RowCount())
+	for i := range ids {
+		ids[i] = IndexLocation(i)
+		values[i] = []Value{NewInt32Value(int32(i)), NewFloat64Value(float64(i) / 16)}
+	}
+	if _, err := tbl.SetRows([]string{"id", "level"}, ids, values); err != nil {
+		t.Fatal(err)
+	}
+
+	locations := scatteredLocations(5000)
+	direct, err := tbl.GetRows([]string{"level", "id"}, locations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 8, 10000} {
+		result, err := tbl.GetRowsParallel([]string{"level", "id"}, workers, locations...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result, direct) {
+			t.Errorf("expected parallel result with %d workers to match sequential result", workers)
+		}
+	}
+
+	empty, err := tbl.GetRowsParallel([]string{"id"}, 4)
+	if err != nil || len(empty.Rows) != 0 {
+		t.Errorf("expected no rows without locations, got %d (%v)", len(empty.Rows), err)
+	}
+	bad := append(slices.Clone(locations[:100]), IndexLocation(tbl.RowCount()))
+	if _, err := tbl.GetRowsParallel([]string{"id"}, 4, bad...); !errors.As(err, &IndexOutOfRangeError{}) {
+		t.Errorf("expected index out of range error, got %v", err)
+	}
+}
+
+// Locations spread pseudo-randomly over the whole sphere.
+func scatteredLocations(count int) []Location {
+	locations := make([]Location, count)
+	for i := range locations {
+		lat := math.Mod(float64(i)*0.618034, 1)*math.Pi - math.Pi/2
+		lon := math.Mod(float64(i)*0.414214, 1)*2*math.Pi - math.Pi
+		locations[i] = SphericalLocation{Latitude: lat, Longitude: lon}
+	}
+	return locations
+}
+
+func benchmarkScatteredQuery(b *testing.B, workers int) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_benchmark_scattered")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tbl, err := NewTable(filepath.Join(dir, "scattered"), NewCylindricalEquirectangularIndexer(0, 1000, 500, true),
+		NewColumnInt32("id", 0), NewColumnFloat64("level", 0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tbl.Close()
+	locations := scatteredLocations(20000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if workers == 1 {
+			_, err = tbl.GetRows([]string{"id", "level"}, locations...)
+		} else {
+			_, err = tbl.GetRowsParallel([]string{"id", "level"}, workers, locations...)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTableScatteredSequential(b *testing.B) {
+	benchmarkScatteredQuery(b, 1)
+}
+
+func BenchmarkTableScatteredParallel(b *testing.B) {
+	benchmarkScatteredQuery(b, 0)
+}
+
+func TestTableReindexHealpix(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_reindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	order := healpix.HealpixOrder(2)
+	path := filepath.Join(dir, "reindex")
+	tbl, err := NewTable(path, NewFlatHealpixIndexer(order, healpix.NestScheme), NewColumnInt32("pixel", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tag every row with its nested pixel number
+	for i := 0; i < tbl.RowCount(); i++ {
+		if err := tbl.SetValue("pixel", IndexLocation(i), NewInt32Value(int32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	column := func(tbl *Table) []int32 {
+		t.Helper()
+		values := make([]int32, tbl.RowCount())
+		for i := range values {
+			val, err := tbl.GetValue("pixel", IndexLocation(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			values[i] = val.AsInt32()
+		}
+		return values
+	}
+
+	if err := tbl.ReindexHealpix(healpix.RingScheme); err != nil {
+		t.Fatal(err)
+	}
+	// nested pixel 55 is ring pixel 23, and nested pixel 66 on the fifth base face is ring pixel 135
+	ring := column(tbl)
+	if ring[23] != 55 || ring[135] != 66 {
+		t.Errorf("expected ring rows 23 and 135 to hold nested pixels 55 and 66, got %d and %d", ring[23], ring[135])
+	}
+	sorted := slices.Clone(ring)
+	slices.Sort(sorted)
+	for i, v := range sorted {
+		if v != int32(i) {
+			t.Fatalf("expected every nested pixel to appear once after reindexing, got %v", ring)
+		}
+	}
+	if err := tbl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if indexer := reopened.Indexer.(FlatHealpixIndexer); indexer.Scheme != healpix.RingScheme {
+		t.Errorf("expected the saved indexer to use the ring scheme, got %v", indexer.Scheme)
+	}
+	if !slices.Equal(column(reopened), ring) {
+		t.Error("expected the reindexed rows to be saved")
+	}
+
+	// converting back restores the original order
+	if err := reopened.ReindexHealpix(healpix.NestScheme); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range column(reopened) {
+		if v != int32(i) {
+			t.Fatalf("expected row %d to hold nested pixel %d after converting back, got %d", i, i, v)
+		}
+	}
+
+	other, err := NewTable(filepath.Join(dir, "grid"), NewProjectionlessIndexer(4, 4, true), NewColumnInt32("pixel", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := other.ReindexHealpix(healpix.RingScheme); !errors.As(err, &IndexerNotSupportedError{}) {
+		t.Errorf("expected an IndexerNotSupportedError, got %v", err)
+	}
+}
+
+func TestTableSampleTrack(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_sample_track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexer := NewMercatorCutoffIndexer(math.Pi/4, -math.Pi/4, 200, 100, true)
+	tbl, err := NewTable(filepath.Join(dir, "track"), indexer,
+		NewColumnInt32("id", 0).WithNoData(NewInt32Value(-1)), NewColumnInt16("plain", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < indexer.Size(); i++ {
+		if err := tbl.store.SetValueAt("id", i, NewInt32Value(int32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a track crossing the table from south east to north west, leaving it past the cutoffs
+	points := []SphericalLocation{
+		{Latitude: 0.7, Longitude: -3},
+		{Latitude: -1.2, Longitude: 2},
+		{Latitude: -0.5, Longitude: 2.5},
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 1.3, Longitude: -1},
+		{Latitude: 0.2, Longitude: -1.5},
+	}
+	outside := []bool{false, true, false, false, true, false}
+
+	ids, err := tbl.SampleTrack("id", points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := tbl.SampleTrack("plain", points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(points) || len(plain) != len(points) {
+		t.Fatalf("expected %d values, got %d and %d", len(points), len(ids), len(plain))
+	}
+	for i, point := range points {
+		if outside[i] {
+			if ids[i].AsInt32() != -1 {
+				t.Errorf("point %d: expected no-data id outside the table, got %v", i, ids[i])
+			}
+			if plain[i] != nil {
+				t.Errorf("point %d: expected nil outside the table for a column without no-data, got %v", i, plain[i])
+			}
+			continue
+		}
+		index, err := indexer.ToIndex(point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[i].AsInt32() != int32(index) {
+			t.Errorf("point %d: expected id %d, got %d", i, index, ids[i].AsInt32())
+		}
+		if plain[i].AsInt16() != 5 {
+			t.Errorf("point %d: expected default 5, got %d", i, plain[i].AsInt16())
+		}
+	}
+
+	// the no-data values of points outside do not share their bytes
+	ids[1][0] = 7
+	if ids[4].AsInt32() != -1 {
+		t.Errorf("expected changing one no-data value to leave the others alone, got %d", ids[4].AsInt32())
+	}
+
+	var notFound *ColumnNotFoundError
+	if _, err := tbl.SampleTrack("missing", points); !errors.As(err, &notFound) {
+		t.Errorf("expected a column not found error, got %v", err)
+	}
+}
+
+func TestTableResetRegion(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_reset_region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "tiles"), NewProjectionlessIndexer(40, 40, true),
+		NewColumnInt16("height", -9999), NewColumnFloat32("rain", 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := []Location{GridLocation{0, 0}, GridLocation{1, 0}, GridLocation{5, 5}, GridLocation{39, 39}, GridLocation{20, 10}}
+	for i, loc := range set {
+		if _, err := tbl.SetRows([]string{"height", "rain"}, []Location{loc}, [][]Value{{NewInt16Value(int16(i + 1)), NewFloat32Value(float32(i))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tbl.ResetRegion(set[1], set[3], GridLocation{7, 7}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := tbl.GetRows([]string{"height", "rain"}, set...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range result.Rows {
+		height, rain := int16(i+1), float32(i)
+		if i == 1 || i == 3 {
+			height, rain = -9999, 0.5
+		}
+		if row[0].AsInt16() != height || row[1].AsFloat32() != rain {
+			t.Errorf("location %v: expected %d and %v, got %d and %v", set[i], height, rain, row[0].AsInt16(), row[1].AsFloat32())
+		}
+	}
+
+	// a location outside the table resets nothing
+	if err := tbl.ResetRegion(set[0], GridLocation{40, 0}); err == nil {
+		t.Errorf("expected an error for a location outside the table")
+	}
+	if val, err := tbl.GetValue("height", set[0]); err != nil || val.AsInt16() != 1 {
+		t.Errorf("expected the first location untouched by the failed reset, got %v, %v", val, err)
+	}
+}
+
+func TestTableSetRowsUniform(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_set_rows_uniform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "tiles"), NewProjectionlessIndexer(40, 40, true),
+		NewColumnInt16("height", -9999), NewColumnFloat32("rain", 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inRegion := func(x, y int) bool { return x >= 10 && x < 30 && y >= 5 && y < 25 }
+	region := []Location{}
+	all := []Location{}
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if inRegion(x, y) {
+				region = append(region, GridLocation{x, y})
+			}
+			all = append(all, GridLocation{x, y})
+		}
+	}
+
+	// flood a region spanning several pages with one height, leaving the rain alone
+	n, err := tbl.SetRowsUniform([]string{"height"}, []Value{NewInt16Value(7)}, region...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(region) {
+		t.Errorf("expected to set %d rows, set %d", len(region), n)
+	}
+	checkFlood := func() {
+		t.Helper()
+		result, err := tbl.GetRows([]string{"height", "rain"}, all...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range result.Rows {
+			loc := all[i].(GridLocation)
+			height := int16(-9999)
+			if inRegion(loc.X, loc.Y) {
+				height = 7
+			}
+			if row[0].AsInt16() != height || row[1].AsFloat32() != 0.5 {
+				t.Errorf("location %v: expected %d and 0.5, got %d and %v", loc, height, row[0].AsInt16(), row[1].AsFloat32())
+			}
+		}
+	}
+	checkFlood()
+
+	// nothing is written when any value or location is wrong
+	if _, err := tbl.SetRowsUniform([]string{"height", "rain"}, []Value{NewInt16Value(1)}, region...); !errors.As(err, &ValueCountError{}) {
+		t.Errorf("expected a value count error, got %v", err)
+	}
+	if _, err := tbl.SetRowsUniform([]string{"height"}, []Value{NewInt32Value(1)}, region...); !errors.As(err, &ValueSizeError{}) {
+		t.Errorf("expected a value size error, got %v", err)
+	}
+	if n, err := tbl.SetRowsUniform([]string{"height"}, []Value{NewInt16Value(1)}, region[0], GridLocation{40, 0}); err == nil || n != 0 {
+		t.Errorf("expected an error for a location outside the table and no rows set, got %d, %v", n, err)
+	}
+	checkFlood()
+}
+
+func TestTableSetRowsLengths(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_set_rows_lengths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "tiles"), NewProjectionlessIndexer(10, 10, true),
+		NewColumnInt16("height", -9999), NewColumnFloat32("rain", 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations := []Location{GridLocation{0, 0}, GridLocation{3, 4}, GridLocation{9, 9}}
+
+	// too few rows of values
+	n, err := tbl.SetRows([]string{"height", "rain"}, locations, [][]Value{
+		{NewInt16Value(1), NewFloat32Value(1)},
+		{NewInt16Value(2), NewFloat32Value(2)},
+	})
+	var countErr LocationCountError
+	if !errors.As(err, &countErr) || countErr.Locations != 3 || countErr.Values != 2 || n != 0 {
+		t.Errorf("expected a location count error for 3 locations and 2 rows, got %d, %v", n, err)
+	}
+
+	// a row of values missing a column
+	n, err = tbl.SetRows([]string{"height", "rain"}, locations, [][]Value{
+		{NewInt16Value(1), NewFloat32Value(1)},
+		{NewInt16Value(2), NewFloat32Value(2)},
+		{NewInt16Value(3)},
+	})
+	var rowErr BatchRowError
+	var valueErr ValueCountError
+	if !errors.As(err, &rowErr) || rowErr.Row != 2 || !errors.As(err, &valueErr) || valueErr.Values != 1 || valueErr.Columns != 2 || n != 0 {
+		t.Errorf("expected a value count error for row 2 of the batch, got %d, %v", n, err)
+	}
+
+	result, err := tbl.GetRows([]string{"height", "rain"}, locations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range result.Rows {
+		if row[0].AsInt16() != -9999 || row[1].AsFloat32() != 0.5 {
+			t.Errorf("location %v: expected no modification, got %d and %v", locations[i], row[0].AsInt16(), row[1].AsFloat32())
+		}
+	}
+}
+
+func TestTableMetadataKeys(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_metadata_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "keys"), NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	if keys := tbl.MetadataKeys(); !slices.Equal(keys, []string{CreatedAt, ProjectionKey}) {
+		t.Errorf("expected the built-in keys of a new table, got %v", keys)
+	}
+	for _, key := range []string{"units", "source", "band"} {
+		if err := tbl.SetMetadata(key, key+" value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := []string{"band", CreatedAt, ProjectionKey, "source", "units"}
+	if keys := tbl.MetadataKeys(); !slices.Equal(keys, expect) {
+		t.Errorf("expected keys %v, got %v", expect, keys)
+	}
+
+	all := tbl.AllMetadata()
+	all["units"] = "changed"
+	if units, _ := tbl.GetMetadata("units"); units != "units value" {
+		t.Errorf("expected the copy of the metadata to be independent of the table, got %q", units)
+	}
+}
+
+func TestTableRowCountLocked(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "pixidb_table_row_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tbl, err := NewTable(filepath.Join(dir, "count"), NewProjectionlessIndexer(4, 4, true), NewColumnInt32("value", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+
+	// the rows of the store only change under the write lock of the table
+	done := make(chan error, 1)
+	go func() {
+		tbl.lock.Lock()
+		defer tbl.lock.Unlock()
+		done <- tbl.store.Grow(32)
+	}()
+	if count := tbl.RowCount(); count != 16 && count != 32 {
+		t.Errorf("expected 16 or 32 rows, got %d", count)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if count := tbl.RowCount(); count != 32 {
+		t.Errorf("expected 32 rows after growing, got %d", count)
+	}
+}
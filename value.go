@@ -1,8 +1,11 @@
 package pixidb
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math"
+	"time"
 )
 
 type Row []byte
@@ -15,6 +18,8 @@ func (r Row) Project(proj Projection) []Value {
 	return vals
 }
 
+// The big endian encoded bytes of a single value of a column, decoded with the AsXxx method for
+// the type of the column. Result sets hold values of this type, one per column of each row.
 type Value []byte
 
 func NewInt8Value(val int8) Value {
@@ -69,6 +74,84 @@ func NewFloat64Value(val float64) Value {
 	return NewUint64Value(math.Float64bits(val))
 }
 
+// Encodes a complex number as its real part followed by its imaginary part.
+func NewComplex64Value(val complex64) Value {
+	return append(NewFloat32Value(real(val)), NewFloat32Value(imag(val))...)
+}
+
+// Encodes a complex number as its real part followed by its imaginary part.
+func NewComplex128Value(val complex128) Value {
+	return append(NewFloat64Value(real(val)), NewFloat64Value(imag(val))...)
+}
+
+// Encodes the instant of a time as nanoseconds since the Unix epoch, which covers the years
+// 1678 through 2262.
+func NewTimeValue(val time.Time) Value {
+	return NewInt64Value(val.UnixNano())
+}
+
+// Encodes a standard Go value as a value of the given column type, returning a ValueTypeError
+// if the Go type of the value does not match the column type.
+func NewValue(ctype ColumnType, val any) (Value, error) {
+	switch ctype {
+	case ColumnTypeInt8:
+		if v, ok := val.(int8); ok {
+			return NewInt8Value(v), nil
+		}
+	case ColumnTypeUint8:
+		if v, ok := val.(uint8); ok {
+			return NewUint8Value(v), nil
+		}
+	case ColumnTypeInt16:
+		if v, ok := val.(int16); ok {
+			return NewInt16Value(v), nil
+		}
+	case ColumnTypeUint16:
+		if v, ok := val.(uint16); ok {
+			return NewUint16Value(v), nil
+		}
+	case ColumnTypeInt32:
+		if v, ok := val.(int32); ok {
+			return NewInt32Value(v), nil
+		}
+	case ColumnTypeUint32:
+		if v, ok := val.(uint32); ok {
+			return NewUint32Value(v), nil
+		}
+	case ColumnTypeInt64:
+		if v, ok := val.(int64); ok {
+			return NewInt64Value(v), nil
+		}
+	case ColumnTypeUint64:
+		if v, ok := val.(uint64); ok {
+			return NewUint64Value(v), nil
+		}
+	case ColumnTypeFloat32:
+		if v, ok := val.(float32); ok {
+			return NewFloat32Value(v), nil
+		}
+	case ColumnTypeFloat64:
+		if v, ok := val.(float64); ok {
+			return NewFloat64Value(v), nil
+		}
+	case ColumnTypeTimestamp:
+		if v, ok := val.(time.Time); ok {
+			return NewTimeValue(v), nil
+		}
+	case ColumnTypeComplex64:
+		if v, ok := val.(complex64); ok {
+			return NewComplex64Value(v), nil
+		}
+	case ColumnTypeComplex128:
+		if v, ok := val.(complex128); ok {
+			return NewComplex128Value(v), nil
+		}
+	default:
+		panic("pixidb: invalid column type specification")
+	}
+	return nil, NewValueTypeError(ctype.goType(), fmt.Sprintf("%T", val))
+}
+
 func (v Value) AsInt8() int8 {
 	return int8(v[0])
 }
@@ -108,3 +191,61 @@ func (v Value) AsFloat32() float32 {
 func (v Value) AsFloat64() float64 {
 	return math.Float64frombits(binary.BigEndian.Uint64(v))
 }
+
+func (v Value) AsComplex64() complex64 {
+	return complex(v[:4].AsFloat32(), v[4:].AsFloat32())
+}
+
+func (v Value) AsComplex128() complex128 {
+	return complex(v[:8].AsFloat64(), v[8:].AsFloat64())
+}
+
+// Decodes a timestamp into the instant it represents, in UTC.
+func (v Value) AsTime() time.Time {
+	return time.Unix(0, v.AsInt64()).UTC()
+}
+
+// The encoded bytes of the value, sharing its memory, so changing them changes the value.
+func (v Value) Bytes() []byte {
+	return v
+}
+
+// The number of encoded bytes of the value, which is the size of its column.
+func (v Value) Len() int {
+	return len(v)
+}
+
+// Reports whether two values have exactly the same bytes, so values of different lengths are
+// never equal. Use EqualAs to compare values by what they represent in a column.
+func (v Value) Equal(other Value) bool {
+	return bytes.Equal(v, other)
+}
+
+// Reports whether two values represent the same value of the given column type. Only the
+// leading bytes of the column size are compared, and a value shorter than that is never equal.
+// Floating point values compare numerically, so +0 equals -0, except that any NaN equals any
+// other NaN, keeping equality reflexive for NaN used as a no data marker.
+func (v Value) EqualAs(ctype ColumnType, other Value) bool {
+	size := ctype.Size()
+	if len(v) < size || len(other) < size {
+		return false
+	}
+	switch ctype {
+	case ColumnTypeFloat32:
+		return floatsEqual(float64(v.AsFloat32()), float64(other.AsFloat32()))
+	case ColumnTypeFloat64:
+		return floatsEqual(v.AsFloat64(), other.AsFloat64())
+	case ColumnTypeComplex64:
+		return floatsEqual(float64(v[:4].AsFloat32()), float64(other[:4].AsFloat32())) &&
+			floatsEqual(float64(v[4:8].AsFloat32()), float64(other[4:8].AsFloat32()))
+	case ColumnTypeComplex128:
+		return floatsEqual(v[:8].AsFloat64(), other[:8].AsFloat64()) &&
+			floatsEqual(v[8:16].AsFloat64(), other[8:16].AsFloat64())
+	default:
+		return bytes.Equal(v[:size], other[:size])
+	}
+}
+
+func floatsEqual(a, b float64) bool {
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
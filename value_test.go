@@ -1,8 +1,11 @@
 package pixidb
 
 import (
+	"errors"
 	"math"
+	"slices"
 	"testing"
+	"time"
 )
 
 func FuzzInt8Ctor(f *testing.F) {
@@ -149,3 +152,185 @@ func FuzzFloat64Ctor(f *testing.F) {
 		}
 	})
 }
+
+func FuzzComplex64Ctor(f *testing.F) {
+	f.Add(float32(0.0), float32(0.0))
+	f.Add(float32(1.0), float32(-1.0))
+	f.Add(float32(math.Pi), float32(math.E))
+	f.Add(float32(-math.Pi), float32(math.MaxFloat32))
+	f.Fuzz(func(t *testing.T, re float32, im float32) {
+		val := complex(re, im)
+		enc := NewComplex64Value(val)
+		dec := enc.AsComplex64()
+		if val != dec {
+			t.Errorf("expected %v after encode/decode, got %v", val, dec)
+		}
+	})
+}
+
+func FuzzComplex128Ctor(f *testing.F) {
+	f.Add(float64(0.0), float64(0.0))
+	f.Add(float64(1.0), float64(-1.0))
+	f.Add(float64(math.Pi), float64(math.E))
+	f.Add(float64(-math.Pi), float64(math.SmallestNonzeroFloat64))
+	f.Fuzz(func(t *testing.T, re float64, im float64) {
+		val := complex(re, im)
+		enc := NewComplex128Value(val)
+		dec := enc.AsComplex128()
+		if val != dec {
+			t.Errorf("expected %v after encode/decode, got %v", val, dec)
+		}
+	})
+}
+
+func TestNewValue(t *testing.T) {
+	testCases := []struct {
+		name   string
+		ctype  ColumnType
+		val    any
+		expect Value
+	}{
+		{"int8", ColumnTypeInt8, int8(-3), NewInt8Value(-3)},
+		{"uint8", ColumnTypeUint8, uint8(200), NewUint8Value(200)},
+		{"int16", ColumnTypeInt16, int16(-300), NewInt16Value(-300)},
+		{"uint16", ColumnTypeUint16, uint16(60000), NewUint16Value(60000)},
+		{"int32", ColumnTypeInt32, int32(-70000), NewInt32Value(-70000)},
+		{"uint32", ColumnTypeUint32, uint32(4000000000), NewUint32Value(4000000000)},
+		{"int64", ColumnTypeInt64, int64(math.MinInt64), NewInt64Value(math.MinInt64)},
+		{"uint64", ColumnTypeUint64, uint64(math.MaxUint64), NewUint64Value(math.MaxUint64)},
+		{"float32", ColumnTypeFloat32, float32(math.Pi), NewFloat32Value(math.Pi)},
+		{"float64", ColumnTypeFloat64, -math.E, NewFloat64Value(-math.E)},
+		{"timestamp", ColumnTypeTimestamp, time.Unix(0, 1500), NewInt64Value(1500)},
+		{"complex64", ColumnTypeComplex64, complex64(1 - 2i), NewComplex64Value(1 - 2i)},
+		{"complex128", ColumnTypeComplex128, 3 + 4i, NewComplex128Value(3 + 4i)},
+	}
+
+	for _, tc := range testCases {
+		val, err := NewValue(tc.ctype, tc.val)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !slices.Equal(val, tc.expect) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expect, val)
+		}
+	}
+
+	_, err := NewValue(ColumnTypeInt32, 5)
+	var typeErr ValueTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected value type error, got %v", err)
+	}
+	if typeErr.Expected != "int32" || typeErr.Actual != "int" {
+		t.Errorf("expected int32 and int in error, got %s and %s", typeErr.Expected, typeErr.Actual)
+	}
+}
+
+func TestTimeValue(t *testing.T) {
+	testCases := []struct {
+		name string
+		val  time.Time
+		text string
+	}{
+		{"epoch", time.Unix(0, 0), "1970-01-01T00:00:00Z"},
+		{"known date", time.Date(2021, time.March, 14, 15, 9, 26, 535897932, time.UTC), "2021-03-14T15:09:26.535897932Z"},
+		{"offset zone", time.Date(2000, time.January, 1, 9, 30, 0, 0, time.FixedZone("UTC+9", 9*3600)), "2000-01-01T00:30:00Z"},
+		{"before epoch", time.Date(1969, time.July, 20, 20, 17, 40, 123, time.UTC), "1969-07-20T20:17:40.000000123Z"},
+	}
+
+	for _, tc := range testCases {
+		enc := NewTimeValue(tc.val)
+		if len(enc) != ColumnTypeTimestamp.Size() {
+			t.Errorf("%s: expected %d bytes, got %d", tc.name, ColumnTypeTimestamp.Size(), len(enc))
+		}
+		if dec := enc.AsTime(); !dec.Equal(tc.val) {
+			t.Errorf("%s: expected %v after encode/decode, got %v", tc.name, tc.val, dec)
+		}
+		if text := ColumnTypeTimestamp.FormatValue(enc); text != tc.text {
+			t.Errorf("%s: expected formatted %s, got %s", tc.name, tc.text, text)
+		}
+		parsed, err := ColumnTypeTimestamp.ParseValue(tc.text)
+		if err != nil {
+			t.Errorf("%s: unexpected parse error %v", tc.name, err)
+		} else if !slices.Equal(parsed, enc) {
+			t.Errorf("%s: expected parsed %v, got %v", tc.name, enc, parsed)
+		}
+	}
+
+	column := NewColumnTime("acquired", time.Unix(1, 0))
+	if column.Size() != 8 || column.Default.AsTime().Unix() != 1 {
+		t.Errorf("expected 8 byte column defaulting to 1s after epoch, got %d bytes and %v", column.Size(), column.Default.AsTime())
+	}
+}
+
+func TestValueEqual(t *testing.T) {
+	nan := math.NaN()
+	otherNaN := math.Float64frombits(math.Float64bits(nan) ^ 2)
+	testCases := []struct {
+		name  string
+		ctype ColumnType
+		a, b  Value
+		exact bool
+		typed bool
+	}{
+		{"same int", ColumnTypeInt32, NewInt32Value(-5), NewInt32Value(-5), true, true},
+		{"different int", ColumnTypeInt32, NewInt32Value(-5), NewInt32Value(5), false, false},
+		{"trailing bytes", ColumnTypeInt16, append(NewInt16Value(300), 9), NewInt16Value(300), false, true},
+		{"too short", ColumnTypeInt32, NewInt16Value(1), NewInt32Value(1), false, false},
+		{"signed zero 32", ColumnTypeFloat32, NewFloat32Value(0), NewFloat32Value(float32(math.Copysign(0, -1))), false, true},
+		{"signed zero 64", ColumnTypeFloat64, NewFloat64Value(0), NewFloat64Value(math.Copysign(0, -1)), false, true},
+		{"nan 32", ColumnTypeFloat32, NewFloat32Value(float32(nan)), NewFloat32Value(float32(nan)), true, true},
+		{"nan payloads", ColumnTypeFloat64, NewFloat64Value(nan), NewFloat64Value(otherNaN), false, true},
+		{"nan and number", ColumnTypeFloat64, NewFloat64Value(nan), NewFloat64Value(1), false, false},
+		{"complex signed zero", ColumnTypeComplex64, NewComplex64Value(complex(1, 0)), NewComplex64Value(complex(1, float32(math.Copysign(0, -1)))), false, true},
+		{"complex nan", ColumnTypeComplex128, NewComplex128Value(complex(nan, 2)), NewComplex128Value(complex(otherNaN, 2)), false, true},
+		{"complex differs", ColumnTypeComplex128, NewComplex128Value(complex(1, 2)), NewComplex128Value(complex(1, 3)), false, false},
+		{"timestamp", ColumnTypeTimestamp, NewTimeValue(time.Unix(5, 0)), NewTimeValue(time.Unix(5, 0)), true, true},
+	}
+
+	for _, tc := range testCases {
+		if eq := tc.a.Equal(tc.b); eq != tc.exact {
+			t.Errorf("%s: expected Equal %t, got %t", tc.name, tc.exact, eq)
+		}
+		if eq := tc.a.EqualAs(tc.ctype, tc.b); eq != tc.typed {
+			t.Errorf("%s: expected EqualAs %t, got %t", tc.name, tc.typed, eq)
+		}
+		if eq := tc.b.EqualAs(tc.ctype, tc.a); eq != tc.typed {
+			t.Errorf("%s: expected reversed EqualAs %t, got %t", tc.name, tc.typed, eq)
+		}
+	}
+}
+
+func TestValueBytes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		val   Value
+		ctype ColumnType
+	}{
+		{"int8", NewInt8Value(-3), ColumnTypeInt8},
+		{"uint16", NewUint16Value(513), ColumnTypeUint16},
+		{"float64", NewFloat64Value(2.5), ColumnTypeFloat64},
+		{"complex128", NewComplex128Value(complex(1, -1)), ColumnTypeComplex128},
+		{"timestamp", NewTimeValue(time.Unix(7, 0)), ColumnTypeTimestamp},
+	}
+
+	for _, tc := range testCases {
+		if tc.val.Len() != tc.ctype.Size() {
+			t.Errorf("%s: expected length %d, got %d", tc.name, tc.ctype.Size(), tc.val.Len())
+		}
+		raw := tc.val.Bytes()
+		if len(raw) != tc.val.Len() || !tc.val.Equal(Value(raw)) {
+			t.Errorf("%s: expected the bytes %v, got %v", tc.name, []byte(tc.val), raw)
+		}
+	}
+
+	// the bytes are those of the value, not a copy
+	val := NewInt16Value(1)
+	val.Bytes()[1] = 2
+	if val.AsInt16() != 2 {
+		t.Errorf("expected writing the bytes to change the value, got %d", val.AsInt16())
+	}
+	if (Value{}).Len() != 0 || len(Value(nil).Bytes()) != 0 {
+		t.Errorf("expected an empty value to have no bytes")
+	}
+}